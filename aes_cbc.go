@@ -0,0 +1,141 @@
+package crypto
+
+// xlCrypto_go/aes_cbc.go
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+)
+
+// AES/CBC/PKCS7 encryption as exercised in aes_cbc_test.go.  A random
+// IV is generated for each message and sent in clear before the
+// ciphertext, so that the encrypted form of a message is IV||ciphertext.
+// The receiver splits off the IV, decrypts the rest, and then strips
+// the padding.  PKCS7 padding is used unless another PaddingI scheme
+// is chosen with the *WithPadding functions.
+
+// An AESCBCCipher encrypts and decrypts any number of messages under
+// the same key.
+type AESCBCCipher struct {
+	engine  cipher.Block
+	padding PaddingI
+}
+
+// Create a cipher using the key, which must be 16, 24, or 32 bytes
+// long, selecting AES-128, AES-192, or AES-256.
+func NewAESCBCCipher(key []byte) (c *AESCBCCipher, err error) {
+	return NewAESCBCCipherWithPadding(key, PKCS7Scheme)
+}
+
+// Create a cipher which pads messages using the scheme given.
+func NewAESCBCCipherWithPadding(key []byte, padding PaddingI) (
+	c *AESCBCCipher, err error) {
+
+	switch len(key) {
+	case 16, 24, 32:
+		if padding == nil {
+			err = NilPaddingScheme
+		} else {
+			var engine cipher.Block
+			engine, err = aes.NewCipher(key)
+			if err == nil {
+				c = &AESCBCCipher{engine: engine, padding: padding}
+			}
+		}
+	default:
+		err = BadAESKeySize
+	}
+	return
+}
+
+// Return the padding scheme the cipher uses.
+func (c *AESCBCCipher) Padding() PaddingI {
+	return c.padding
+}
+
+// Pad and encrypt the message, returning IV||ciphertext.  The message
+// may be nil or empty, in which case the ciphertext is a single block
+// of padding.
+func (c *AESCBCCipher) Encrypt(msg []byte) (blob []byte, err error) {
+	padding, err := c.padding.Padding(len(msg), aes.BlockSize)
+	if err == nil {
+		blob = make([]byte, aes.BlockSize+len(msg)+len(padding))
+		_, err = io.ReadFull(rand.Reader, blob[:aes.BlockSize])
+	}
+	if err == nil {
+		iv := blob[:aes.BlockSize]
+		body := blob[aes.BlockSize:]
+		copy(body, msg)
+		copy(body[len(msg):], padding)
+		encrypter := cipher.NewCBCEncrypter(c.engine, iv)
+		encrypter.CryptBlocks(body, body) // dest <- src
+	} else {
+		blob = nil
+	}
+	return
+}
+
+// Decrypt IV||ciphertext and strip the padding, returning the message.
+// Any fault in the padding is reported with a single error,
+// IncorrectPKCS7Padding for PKCS7 and IncorrectPadding otherwise.
+func (c *AESCBCCipher) Decrypt(blob []byte) (msg []byte, err error) {
+	if blob == nil {
+		err = NilData
+	} else if len(blob) < 2*aes.BlockSize {
+		// we need an IV and at least one block of padding
+		err = CiphertextTooShort
+	} else if len(blob)%aes.BlockSize != 0 {
+		err = UnalignedCiphertext
+	} else {
+		iv := blob[:aes.BlockSize]
+		plaintext := make([]byte, len(blob)-aes.BlockSize)
+		decrypter := cipher.NewCBCDecrypter(c.engine, iv)
+		decrypter.CryptBlocks(plaintext, blob[aes.BlockSize:])
+		msg, err = c.padding.Unpad(plaintext, aes.BlockSize)
+	}
+	return
+}
+
+// Encrypt a single message under the key, returning IV||ciphertext.
+func AESCBCEncrypt(key, msg []byte) (blob []byte, err error) {
+	c, err := NewAESCBCCipher(key)
+	if err == nil {
+		blob, err = c.Encrypt(msg)
+	}
+	return
+}
+
+// Decrypt IV||ciphertext produced by AESCBCEncrypt.
+func AESCBCDecrypt(key, blob []byte) (msg []byte, err error) {
+	c, err := NewAESCBCCipher(key)
+	if err == nil {
+		msg, err = c.Decrypt(blob)
+	}
+	return
+}
+
+// Encrypt a single message under the key, padding it with the scheme
+// given.
+func AESCBCEncryptWithPadding(key, msg []byte, padding PaddingI) (
+	blob []byte, err error) {
+
+	c, err := NewAESCBCCipherWithPadding(key, padding)
+	if err == nil {
+		blob, err = c.Encrypt(msg)
+	}
+	return
+}
+
+// Decrypt IV||ciphertext produced by AESCBCEncryptWithPadding with the
+// same scheme.
+func AESCBCDecryptWithPadding(key, blob []byte, padding PaddingI) (
+	msg []byte, err error) {
+
+	c, err := NewAESCBCCipherWithPadding(key, padding)
+	if err == nil {
+		msg, err = c.Decrypt(blob)
+	}
+	return
+}
@@ -76,3 +76,66 @@ func (s *XLSuite) TestAESCipher(c *C) {
 	}
 
 }
+
+func (s *XLSuite) TestAESCBCEncryptDecrypt(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	for _, keyLen := range []int{16, 24, 32} {
+		key := make([]byte, keyLen)
+		rng.NextBytes(key)
+		cbc, err := NewAESCBCCipher(key)
+		c.Assert(err, IsNil)
+
+		for _, size := range []int{0, 1, 15, 16, 17, rng.Intn(2 * 1024)} {
+			msg := make([]byte, size)
+			rng.NextBytes(msg)
+
+			blob, err := cbc.Encrypt(msg)
+			c.Assert(err, IsNil)
+			// IV plus the padded message
+			expectedLen := aes.BlockSize + (size/aes.BlockSize+1)*aes.BlockSize
+			c.Assert(len(blob), Equals, expectedLen)
+
+			reply, err := cbc.Decrypt(blob)
+			c.Assert(err, IsNil)
+			c.Assert(bytes.Equal(reply, msg), Equals, true)
+
+			// the one-shot functions interoperate with the cipher
+			reply, err = AESCBCDecrypt(key, blob)
+			c.Assert(err, IsNil)
+			c.Assert(bytes.Equal(reply, msg), Equals, true)
+
+			blob2, err := AESCBCEncrypt(key, msg)
+			c.Assert(err, IsNil)
+			// a fresh IV each time
+			c.Assert(bytes.Equal(blob[:aes.BlockSize], blob2[:aes.BlockSize]),
+				Equals, false)
+		}
+	}
+}
+
+func (s *XLSuite) TestAESCBCErrors(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	_, err := NewAESCBCCipher(make([]byte, 15))
+	c.Assert(err, Equals, BadAESKeySize)
+	_, err = AESCBCEncrypt(nil, []byte("abc"))
+	c.Assert(err, Equals, BadAESKeySize)
+
+	key := s.makeAESKey(rng)
+	_, err = AESCBCDecrypt(key, nil)
+	c.Assert(err, Equals, NilData)
+	_, err = AESCBCDecrypt(key, make([]byte, aes.BlockSize))
+	c.Assert(err, Equals, CiphertextTooShort)
+	_, err = AESCBCDecrypt(key, make([]byte, 2*aes.BlockSize+1))
+	c.Assert(err, Equals, UnalignedCiphertext)
+
+	// a 15-byte message gets a single padding byte, 0x01; flipping bits
+	// in the IV flips the same bits in the first plaintext block, so
+	// this turns the padding byte into 0x00, which is never valid
+	blob, err := AESCBCEncrypt(key, make([]byte, aes.BlockSize-1))
+	c.Assert(err, IsNil)
+	blob[aes.BlockSize-1] ^= 0x01
+	_, err = AESCBCDecrypt(key, blob)
+	c.Assert(err, Equals, IncorrectPKCS7Padding)
+}
@@ -0,0 +1,145 @@
+package crypto
+
+// xlCrypto_go/aes_gcm.go
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+)
+
+// AES/GCM authenticated encryption with additional data.
+//
+// The sealed form of a message is self-describing.  Version 1, the
+// only version currently defined, is laid out as
+//
+//     version      1 byte, AES_GCM_VERSION
+//     nonce       12 bytes, AES_GCM_NONCE_SIZE
+//     ciphertext   same length as the plaintext
+//     tag         16 bytes, AES_GCM_TAG_SIZE
+//
+// The version byte is authenticated along with any additional data
+// supplied by the caller, so that it cannot be altered undetected.
+// The additional data itself is not included in the output; the
+// receiver must supply the same additional data to open the message.
+//
+// Unless the caller supplies one, a random nonce is generated for each
+// message.  With random nonces no more than 2^32 messages should be
+// sealed under the same key.  Callers supplying their own nonces must
+// never use the same nonce twice with the same key.
+
+const (
+	AES_GCM_VERSION    = 1
+	AES_GCM_NONCE_SIZE = 12
+	AES_GCM_TAG_SIZE   = 16
+	AES_GCM_OVERHEAD   = 1 + AES_GCM_NONCE_SIZE + AES_GCM_TAG_SIZE
+)
+
+// An AESGCMCipher seals and opens any number of messages under the
+// same key.
+type AESGCMCipher struct {
+	aead cipher.AEAD
+}
+
+// Create a cipher using the key, which must be 16, 24, or 32 bytes
+// long, selecting AES-128, AES-192, or AES-256.
+func NewAESGCMCipher(key []byte) (c *AESGCMCipher, err error) {
+	switch len(key) {
+	case 16, 24, 32:
+		var (
+			engine cipher.Block
+			aead   cipher.AEAD
+		)
+		engine, err = aes.NewCipher(key)
+		if err == nil {
+			aead, err = cipher.NewGCM(engine)
+		}
+		if err == nil {
+			c = &AESGCMCipher{aead: aead}
+		}
+	default:
+		err = BadAESKeySize
+	}
+	return
+}
+
+// Encrypt and authenticate the plaintext and authenticate the
+// additional data, which may be nil, using a random nonce.  Returns
+// the message in sealed form.
+func (c *AESGCMCipher) Seal(plaintext, aad []byte) (sealed []byte, err error) {
+	nonce := make([]byte, AES_GCM_NONCE_SIZE)
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err == nil {
+		sealed, err = c.SealWithNonce(nonce, plaintext, aad)
+	}
+	return
+}
+
+// As Seal(), but using the nonce supplied, which must be
+// AES_GCM_NONCE_SIZE bytes long and must never be reused with the
+// same key.
+func (c *AESGCMCipher) SealWithNonce(nonce, plaintext, aad []byte) (
+	sealed []byte, err error) {
+
+	if len(nonce) != AES_GCM_NONCE_SIZE {
+		err = BadNonceSize
+	} else {
+		sealed = make([]byte, 1+AES_GCM_NONCE_SIZE,
+			len(plaintext)+AES_GCM_OVERHEAD)
+		sealed[0] = AES_GCM_VERSION
+		copy(sealed[1:], nonce)
+		sealed = c.aead.Seal(sealed, nonce, plaintext,
+			gcmAdditionalData(sealed[0], aad))
+	}
+	return
+}
+
+// Check and decrypt a sealed message, returning the plaintext.  The
+// additional data must be the same as was used in sealing.
+func (c *AESGCMCipher) Open(sealed, aad []byte) (plaintext []byte, err error) {
+	if sealed == nil {
+		err = NilData
+	} else if len(sealed) < AES_GCM_OVERHEAD {
+		err = CiphertextTooShort
+	} else if sealed[0] != AES_GCM_VERSION {
+		err = UnsupportedAEADVersion
+	} else {
+		nonce := sealed[1 : 1+AES_GCM_NONCE_SIZE]
+		body := sealed[1+AES_GCM_NONCE_SIZE:]
+		plaintext, err = c.aead.Open(nil, nonce, body,
+			gcmAdditionalData(sealed[0], aad))
+		if err != nil {
+			plaintext = nil
+			err = AEADAuthFailure
+		}
+	}
+	return
+}
+
+// The data authenticated but not encrypted: the version byte followed
+// by the caller's additional data.
+func gcmAdditionalData(version byte, aad []byte) []byte {
+	data := make([]byte, 1+len(aad))
+	data[0] = version
+	copy(data[1:], aad)
+	return data
+}
+
+// Seal a single message under the key using a random nonce.
+func AESGCMSeal(key, plaintext, aad []byte) (sealed []byte, err error) {
+	c, err := NewAESGCMCipher(key)
+	if err == nil {
+		sealed, err = c.Seal(plaintext, aad)
+	}
+	return
+}
+
+// Open a message sealed by AESGCMSeal or an AESGCMCipher.
+func AESGCMOpen(key, sealed, aad []byte) (plaintext []byte, err error) {
+	c, err := NewAESGCMCipher(key)
+	if err == nil {
+		plaintext, err = c.Open(sealed, aad)
+	}
+	return
+}
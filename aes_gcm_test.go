@@ -0,0 +1,107 @@
+package crypto
+
+// xlCrypto_go/aes_gcm_test.go
+
+import (
+	"bytes"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+)
+
+func (s *XLSuite) TestAESGCMSealOpen(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	for _, keyLen := range []int{16, 32} {
+		key := make([]byte, keyLen)
+		rng.NextBytes(key)
+		gcm, err := NewAESGCMCipher(key)
+		c.Assert(err, IsNil)
+
+		for _, size := range []int{0, 1, 16, rng.Intn(2 * 1024)} {
+			msg := make([]byte, size)
+			rng.NextBytes(msg)
+			aad := make([]byte, rng.Intn(64))
+			rng.NextBytes(aad)
+
+			sealed, err := gcm.Seal(msg, aad)
+			c.Assert(err, IsNil)
+			c.Assert(len(sealed), Equals, size+AES_GCM_OVERHEAD)
+			c.Assert(sealed[0], Equals, byte(AES_GCM_VERSION))
+
+			reply, err := gcm.Open(sealed, aad)
+			c.Assert(err, IsNil)
+			c.Assert(bytes.Equal(reply, msg), Equals, true)
+
+			reply, err = AESGCMOpen(key, sealed, aad)
+			c.Assert(err, IsNil)
+			c.Assert(bytes.Equal(reply, msg), Equals, true)
+
+			// different additional data fails
+			_, err = gcm.Open(sealed, append(aad, 0))
+			c.Assert(err, Equals, AEADAuthFailure)
+
+			// a fresh nonce each time
+			sealed2, err := AESGCMSeal(key, msg, aad)
+			c.Assert(err, IsNil)
+			c.Assert(bytes.Equal(sealed[1:1+AES_GCM_NONCE_SIZE],
+				sealed2[1:1+AES_GCM_NONCE_SIZE]), Equals, false)
+		}
+	}
+}
+
+func (s *XLSuite) TestAESGCMWithNonce(c *C) {
+	rng := xr.MakeSimpleRNG()
+	key := make([]byte, 32)
+	rng.NextBytes(key)
+	nonce := make([]byte, AES_GCM_NONCE_SIZE)
+	rng.NextBytes(nonce)
+	msg := []byte("a message with a caller-supplied nonce")
+
+	gcm, err := NewAESGCMCipher(key)
+	c.Assert(err, IsNil)
+	sealed, err := gcm.SealWithNonce(nonce, msg, nil)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(sealed[1:1+AES_GCM_NONCE_SIZE], nonce), Equals, true)
+
+	// sealing is deterministic given the nonce
+	sealed2, err := gcm.SealWithNonce(nonce, msg, nil)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(sealed, sealed2), Equals, true)
+
+	reply, err := gcm.Open(sealed, nil)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(reply, msg), Equals, true)
+
+	_, err = gcm.SealWithNonce(nonce[1:], msg, nil)
+	c.Assert(err, Equals, BadNonceSize)
+}
+
+func (s *XLSuite) TestAESGCMErrors(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	_, err := NewAESGCMCipher(make([]byte, 20))
+	c.Assert(err, Equals, BadAESKeySize)
+
+	key := make([]byte, 16)
+	rng.NextBytes(key)
+	_, err = AESGCMOpen(key, nil, nil)
+	c.Assert(err, Equals, NilData)
+	_, err = AESGCMOpen(key, make([]byte, AES_GCM_OVERHEAD-1), nil)
+	c.Assert(err, Equals, CiphertextTooShort)
+
+	sealed, err := AESGCMSeal(key, []byte("hello"), nil)
+	c.Assert(err, IsNil)
+
+	// tampering with any byte is detected
+	for i := 1; i < len(sealed); i++ {
+		bad := make([]byte, len(sealed))
+		copy(bad, sealed)
+		bad[i] ^= 0x80
+		_, err = AESGCMOpen(key, bad, nil)
+		c.Assert(err, Equals, AEADAuthFailure)
+	}
+	// an unknown version is rejected
+	sealed[0] = 2
+	_, err = AESGCMOpen(key, sealed, nil)
+	c.Assert(err, Equals, UnsupportedAEADVersion)
+}
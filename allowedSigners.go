@@ -0,0 +1,245 @@
+package crypto
+
+// xlCrypto_go/allowedSigners.go
+
+import (
+	cr "crypto"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// OpenSSH allowed_signers files, as described in the ALLOWED SIGNERS
+// section of ssh-keygen(1), which say whose SSHSIG signatures are to be
+// trusted.  Each line is
+//
+//     principals [options] keytype base64-key [comment]
+//
+// where principals is a comma-separated list of patterns, which may use
+// the wildcards * and ? and be negated with a leading '!', and may be
+// enclosed in double quotes.  The options are
+//
+//     cert-authority           the key is a CA; signatures by keys it has
+//                              certified are trusted for the principals
+//                              in the certificates
+//     namespaces="list"        patterns for the namespaces accepted
+//     valid-after="time"       times in the form YYYYMMDD[HHMM[SS]][Z],
+//     valid-before="time"      local unless followed by Z
+//
+// Principals and namespaces, unlike host names, match case-sensitively.
+
+type AllowedSigner struct {
+	LineNo        int
+	Principals    []string // patterns
+	CertAuthority bool
+	Namespaces    []string  // patterns; nil if any namespace is accepted
+	ValidAfter    time.Time // zero if not limited
+	ValidBefore   time.Time // zero if not limited
+	Key           cr.PublicKey
+	Comment       string
+	Err           error // why the line is ignored; nil if it is not
+}
+
+type AllowedSigners struct {
+	Entries []*AllowedSigner
+}
+
+// Parse the contents of an allowed_signers file.  Lines which cannot
+// be parsed are recorded with the reason in Err and otherwise ignored,
+// as ssh-keygen ignores them.
+func ParseAllowedSigners(data []byte) *AllowedSigners {
+	as := &AllowedSigners{}
+	for i, line := range strings.Split(string(data), "\n") {
+		text := strings.TrimSpace(line)
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		ent := parseAllowedSignerLine(text)
+		ent.LineNo = i + 1
+		as.Entries = append(as.Entries, ent)
+	}
+	return as
+}
+
+// Read and parse an allowed_signers file.
+func ReadAllowedSigners(path string) (as *AllowedSigners, err error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		as = ParseAllowedSigners(data)
+	}
+	return
+}
+
+func parseAllowedSignerLine(text string) (ent *AllowedSigner) {
+	ent = &AllowedSigner{}
+	var principals string
+	if text[0] == '"' {
+		end := strings.IndexByte(text[1:], '"')
+		if end == -1 {
+			ent.Err = IllFormedAllowedSigner
+			return
+		}
+		principals, text = text[1:end+1], text[end+2:]
+	} else if end := strings.IndexAny(text, " \t"); end != -1 {
+		principals, text = text[:end], text[end:]
+	} else {
+		ent.Err = IllFormedAllowedSigner
+		return
+	}
+	text = strings.TrimLeft(text, " \t")
+	if principals == "" || text == "" {
+		ent.Err = IllFormedAllowedSigner
+		return
+	}
+	ent.Principals = strings.Split(principals, ",")
+
+	key, comment, err := parseKeyFields(text)
+	if err != nil && err != UnsupportedKeyType {
+		options, rest, found := splitKeyOptions([]byte(text))
+		if found {
+			key, comment, err = parseKeyFields(string(rest))
+			for i := 0; err == nil && i < len(options); i++ {
+				err = ent.parseOption(options[i])
+			}
+		}
+	}
+	if err == IllFormedAuthorizedKey || err == BadKeyOption {
+		err = IllFormedAllowedSigner
+	}
+	ent.Key, ent.Comment, ent.Err = key, comment, err
+	return
+}
+
+func (ent *AllowedSigner) parseOption(option string) (err error) {
+	name, value, hasValue := strings.Cut(option, "=")
+	if hasValue {
+		value, err = dequoteOption(value)
+	}
+	if err == nil {
+		switch strings.ToLower(name) {
+		case "cert-authority":
+			if hasValue {
+				err = IllFormedAllowedSigner
+			}
+			ent.CertAuthority = true
+		case "namespaces":
+			ent.Namespaces = strings.Split(value, ",")
+		case "valid-after":
+			ent.ValidAfter, err = parseExpiryTime(value)
+		case "valid-before":
+			ent.ValidBefore, err = parseExpiryTime(value)
+		default:
+			err = IllFormedAllowedSigner
+		}
+	}
+	return
+}
+
+// MATCHING /////////////////////////////////////////////////////////
+
+// Whether the entry trusts the signature for the identity and namespace
+// at the time given.  The signature itself is not checked.
+func (ent *AllowedSigner) Permits(sig *SSHSignature, identity, namespace string,
+	when time.Time) bool {
+
+	return ent.trusts(sig, identity, when) && (ent.Namespaces == nil ||
+		matchCasePatternList(namespace, ent.Namespaces) > 0)
+}
+
+// Whether the entry trusts the signature's key for the identity at the
+// time given, whatever the namespace.
+func (ent *AllowedSigner) trusts(sig *SSHSignature, identity string,
+	when time.Time) bool {
+
+	if ent.Err != nil || ent.Key == nil ||
+		matchCasePatternList(identity, ent.Principals) <= 0 ||
+		(!ent.ValidAfter.IsZero() && when.Before(ent.ValidAfter)) ||
+		(!ent.ValidBefore.IsZero() && when.After(ent.ValidBefore)) {
+
+		return false
+	}
+	if ent.CertAuthority {
+		return sig.Certificate != nil && sig.Certificate.Validate(
+			[]cr.PublicKey{ent.Key}, SSH_USER_CERT, identity, when) == nil
+	}
+	blob, err := sshKeyBlob(sig.PublicKey)
+	return err == nil && sig.Certificate == nil && sameKey(ent.Key, blob)
+}
+
+// Match the string against a list of patterns as MatchPatternList does,
+// but without folding case.
+func matchCasePatternList(s string, patterns []string) (result int) {
+	if s == "" {
+		return
+	}
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		if negated {
+			p = p[1:]
+		}
+		if MatchPattern(s, p) {
+			if negated {
+				return -1
+			}
+			result = 1
+		}
+	}
+	return
+}
+
+// Check the signature over the message read from r, as ssh-keygen -Y
+// verify does: it must have been made for the namespace given, and an
+// entry in the allowed signers must trust its key for the identity at
+// the time given.  The entry is returned.
+func (sig *SSHSignature) VerifyAllowed(r io.Reader, namespace, identity string,
+	signers *AllowedSigners, when time.Time) (
+	signer *AllowedSigner, err error) {
+
+	if signers == nil {
+		err = NotAnAllowedSigner
+	} else {
+		for _, ent := range signers.Entries {
+			if ent.Permits(sig, identity, namespace, when) {
+				signer = ent
+				break
+			}
+		}
+		if signer == nil {
+			err = NotAnAllowedSigner
+		}
+	}
+	if err == nil {
+		if err = sig.Verify(r, namespace); err != nil {
+			signer = nil
+		}
+	}
+	return
+}
+
+// Return the principals for which the allowed signers trust the
+// signature's key at the time given, as ssh-keygen -Y find-principals
+// does.  For a key listed as such these are the patterns on its line;
+// for a certificate, those of its principals which the line of a CA
+// which signed it allows.  Namespaces are not considered, and the
+// signature itself is not checked.
+func (sig *SSHSignature) FindPrincipals(signers *AllowedSigners,
+	when time.Time) (principals []string) {
+
+	for _, ent := range signers.Entries {
+		if sig.Certificate == nil {
+			for _, p := range ent.Principals {
+				if !strings.HasPrefix(p, "!") && ent.trusts(sig, p, when) {
+					principals = append(principals, p)
+				}
+			}
+		} else if ent.CertAuthority {
+			for _, p := range sig.Certificate.cert.ValidPrincipals {
+				if ent.trusts(sig, p, when) {
+					principals = append(principals, p)
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,357 @@
+package crypto
+
+// xlCrypto_go/authKeyOpts.go
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options on authorized_keys lines, as described in the AUTHORIZED_KEYS
+// FILE FORMAT section of sshd(8).  ParseAuthorizedKey returns the
+// options as raw strings such as `from="10.0.0.0/8"`;
+// ParseAuthorizedKeyOptions interprets them as sshd does:
+//
+//   - option names are not case-sensitive
+//   - values must be quoted; within the quotes \" stands for a quote
+//   - an unrecognized or malformed option makes the whole line unusable
+//   - command, from, and principals may appear only once
+//   - if expiry-time appears more than once the earliest time applies
+//   - restrict turns off forwarding, pty allocation, and ~/.ssh/rc,
+//     any of which can then be turned back on individually
+//
+// Evaluate then answers whether a key may be used from a given source
+// at a given time, and with which forced command.
+
+type AuthorizedKeyOptions struct {
+	// source patterns from the from= option, possibly negated with '!'
+	From []string
+	// forced command, and whether there is one: command="" forces
+	// an empty command
+	Command    string
+	HasCommand bool
+	// NAME=value settings from environment= options, in order
+	Environment []string
+	// the key may not be used after this time; zero if no limit
+	ExpiryTime time.Time
+
+	Restrict      bool
+	CertAuthority bool
+	Principals    []string
+	PermitOpen    []string
+	PermitListen  []string
+	Tunnel        int // -1 if not specified
+
+	NoPty, Pty                         bool
+	NoPortForwarding, PortForwarding   bool
+	NoAgentForwarding, AgentForwarding bool
+	NoX11Forwarding, X11Forwarding     bool
+	NoUserRC, UserRC                   bool
+	NoTouchRequired, VerifyRequired    bool
+}
+
+// Interpret the options returned by ParseAuthorizedKey.  Expiry times
+// without a Z suffix are in the local time zone.
+func ParseAuthorizedKeyOptions(options []string) (
+	opts *AuthorizedKeyOptions, err error) {
+
+	opts = &AuthorizedKeyOptions{Tunnel: -1}
+	for i := 0; err == nil && i < len(options); i++ {
+		err = opts.parseOption(options[i])
+	}
+	if err != nil {
+		opts = nil
+	}
+	return
+}
+
+func (o *AuthorizedKeyOptions) parseOption(option string) (err error) {
+	name, value, hasValue := strings.Cut(option, "=")
+	name = strings.ToLower(name)
+	if hasValue {
+		value, err = dequoteOption(value)
+		if err != nil {
+			return
+		}
+	}
+	flags := map[string]*bool{
+		"restrict":            &o.Restrict,
+		"cert-authority":      &o.CertAuthority,
+		"no-pty":              &o.NoPty,
+		"pty":                 &o.Pty,
+		"no-port-forwarding":  &o.NoPortForwarding,
+		"port-forwarding":     &o.PortForwarding,
+		"no-agent-forwarding": &o.NoAgentForwarding,
+		"agent-forwarding":    &o.AgentForwarding,
+		"no-x11-forwarding":   &o.NoX11Forwarding,
+		"x11-forwarding":      &o.X11Forwarding,
+		"no-user-rc":          &o.NoUserRC,
+		"user-rc":             &o.UserRC,
+		"no-touch-required":   &o.NoTouchRequired,
+		"verify-required":     &o.VerifyRequired,
+	}
+	if flag, isFlag := flags[name]; isFlag {
+		if hasValue {
+			err = BadKeyOption
+		} else {
+			*flag = true
+		}
+		return
+	}
+	if !hasValue {
+		return BadKeyOption
+	}
+	switch name {
+	case "command":
+		if o.HasCommand {
+			err = DuplicateKeyOption
+		} else {
+			o.Command, o.HasCommand = value, true
+		}
+	case "from":
+		if o.From != nil {
+			err = DuplicateKeyOption
+		} else if value == "" {
+			err = BadKeyOption
+		} else {
+			o.From = strings.Split(value, ",")
+		}
+	case "principals":
+		if o.Principals != nil {
+			err = DuplicateKeyOption
+		} else {
+			o.Principals = strings.Split(value, ",")
+		}
+	case "environment":
+		// sshd requires a name made of letters, digits, and underscores
+		envName, _, ok := strings.Cut(value, "=")
+		if !ok || envName == "" || strings.TrimLeft(envName,
+			"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+
+			err = BadKeyOption
+		} else {
+			o.Environment = append(o.Environment, value)
+		}
+	case "expiry-time":
+		var t time.Time
+		if t, err = parseExpiryTime(value); err == nil {
+			if o.ExpiryTime.IsZero() || t.Before(o.ExpiryTime) {
+				o.ExpiryTime = t
+			}
+		}
+	case "permitopen":
+		o.PermitOpen = append(o.PermitOpen, value)
+	case "permitlisten":
+		o.PermitListen = append(o.PermitListen, value)
+	case "tunnel":
+		var n int
+		if n, err = strconv.Atoi(value); err != nil || n < 0 {
+			err = BadKeyOption
+		} else {
+			o.Tunnel = n
+		}
+	default:
+		err = BadKeyOption
+	}
+	return
+}
+
+// Strip the quotes from an option value, replacing \" with ".  A value
+// may not break the line.
+func dequoteOption(value string) (out string, err error) {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' ||
+		breaksLine(value) {
+
+		err = BadKeyOption
+	} else {
+		out = strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+	}
+	return
+}
+
+// Whether the text would end an authorized_keys line or is otherwise
+// unfit for one.
+func breaksLine(text string) bool {
+	return strings.ContainsAny(text, "\r\n\x00")
+}
+
+// Parse an expiry-time: YYYYMMDD or YYYYMMDDHHMM[SS], optionally
+// followed by Z to indicate UTC.
+func parseExpiryTime(value string) (t time.Time, err error) {
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+		value = value[:len(value)-1]
+	}
+	var layout string
+	switch len(value) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		err = BadKeyOption
+	}
+	if err == nil {
+		if t, err = time.ParseInLocation(layout, value, loc); err != nil {
+			err = BadKeyOption
+		}
+	}
+	return
+}
+
+// POLICY ///////////////////////////////////////////////////////////
+
+// Decide whether the key may be used by a client connecting from
+// remoteIP, whose name is remoteHost (which may be empty if the
+// address does not resolve), at the time given.  If it may, return
+// the forced command, if any.  Otherwise the error is KeyExpired or
+// SourceNotPermitted.
+func (o *AuthorizedKeyOptions) Evaluate(remoteHost string, remoteIP net.IP,
+	when time.Time) (command string, err error) {
+
+	if !o.ExpiryTime.IsZero() && when.After(o.ExpiryTime) {
+		err = KeyExpired
+	} else if o.From != nil && !MatchHostAndIP(remoteHost, remoteIP, o.From) {
+		err = SourceNotPermitted
+	} else {
+		command = o.Command
+	}
+	return
+}
+
+// Whether a pty may be allocated.
+func (o *AuthorizedKeyOptions) PermitsPty() bool {
+	return !o.NoPty && (!o.Restrict || o.Pty)
+}
+
+func (o *AuthorizedKeyOptions) PermitsPortForwarding() bool {
+	return !o.NoPortForwarding && (!o.Restrict || o.PortForwarding)
+}
+
+func (o *AuthorizedKeyOptions) PermitsAgentForwarding() bool {
+	return !o.NoAgentForwarding && (!o.Restrict || o.AgentForwarding)
+}
+
+func (o *AuthorizedKeyOptions) PermitsX11Forwarding() bool {
+	return !o.NoX11Forwarding && (!o.Restrict || o.X11Forwarding)
+}
+
+// Whether ~/.ssh/rc is run.
+func (o *AuthorizedKeyOptions) PermitsUserRC() bool {
+	return !o.NoUserRC && (!o.Restrict || o.UserRC)
+}
+
+// PATTERN MATCHING /////////////////////////////////////////////////
+
+// Match a client against a list of from= patterns, as sshd's
+// match_host_and_ip() does.  Each pattern is a hostname or address
+// pattern using the wildcards * and ?, or a CIDR address/masklen, and
+// may be negated with a leading '!'.  A match on any negated pattern
+// denies access; otherwise a match on any pattern grants it.  An
+// ill-formed CIDR pattern also denies access.  If host is empty the
+// address is matched against the hostname patterns as well, as sshd
+// does when UseDNS is off.
+func MatchHostAndIP(host string, ip net.IP, patterns []string) bool {
+	var ipMatch int
+	if host == "" && ip != nil {
+		host = ip.String()
+	}
+	if ip != nil {
+		ipMatch = matchAddrList(ip, patterns)
+		if ipMatch < 0 {
+			return false
+		}
+	}
+	hostMatch := MatchPatternList(strings.ToLower(host), patterns)
+	return hostMatch >= 0 && (hostMatch > 0 || ipMatch > 0)
+}
+
+// Match the string against a list of patterns, returning 1 if it
+// matches a pattern, -1 if it matches a negated pattern, and 0 if it
+// matches none of them.  Negated matches take precedence.
+func MatchPatternList(s string, patterns []string) (result int) {
+	if s == "" {
+		return
+	}
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		if negated {
+			p = p[1:]
+		}
+		if MatchPattern(s, strings.ToLower(p)) {
+			if negated {
+				return -1
+			}
+			result = 1
+		}
+	}
+	return
+}
+
+// Match the address against those patterns which are addresses, CIDR
+// ranges, or address wildcards, ignoring hostname patterns.  Returns
+// as MatchPatternList, or -2 if a CIDR pattern is ill-formed.
+func matchAddrList(ip net.IP, patterns []string) (result int) {
+	addr := ip.String()
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		if negated {
+			p = p[1:]
+		}
+		var matched bool
+		if strings.Contains(p, "/") {
+			netIP, ipNet, err := net.ParseCIDR(p)
+			if err != nil || !netIP.Equal(netIP.Mask(ipNet.Mask)) {
+				// bad syntax, or host bits are set
+				return -2
+			}
+			matched = ipNet.Contains(ip)
+		} else if strings.Trim(p, "0123456789abcdefABCDEF.:*?") == "" {
+			matched = MatchPattern(addr, strings.ToLower(p))
+		} else {
+			continue // a hostname pattern
+		}
+		if matched {
+			if negated {
+				return -1
+			}
+			result = 1
+		}
+	}
+	return
+}
+
+// Match the string against a shell-style pattern in which '*' matches
+// any run of characters and '?' any single character.
+func MatchPattern(s, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if MatchPattern(s[i:], pattern) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		s, pattern = s[1:], pattern[1:]
+	}
+	return len(s) == 0
+}
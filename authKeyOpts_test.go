@@ -0,0 +1,137 @@
+package crypto
+
+// xlCrypto_go/authKeyOpts_test.go
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	. "gopkg.in/check.v1"
+	"net"
+	"strings"
+	"time"
+)
+
+// Build an authorized_keys line with the options given and parse the
+// options back out of it.
+func (s *XLSuite) parseOptionsLine(c *C, options string) (
+	*AuthorizedKeyOptions, error) {
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	disk, err := PubKeyToDisk(pub)
+	c.Assert(err, IsNil)
+	line := options + " " + strings.TrimSpace(string(disk)) + " me@example\n"
+	_, comment, rawOpts, _, ok := ParseAuthorizedKey([]byte(line))
+	c.Assert(ok, Equals, true)
+	c.Assert(comment, Equals, "me@example")
+	return ParseAuthorizedKeyOptions(rawOpts)
+}
+
+func (s *XLSuite) TestAuthorizedKeyOptions(c *C) {
+	opts, err := s.parseOptionsLine(c,
+		`from="10.0.0.0/8,!10.1.2.3,*.example.com",command="echo \"hi\"",`+
+			`environment="FOO=bar",No-Pty,expiry-time="20300101Z",`+
+			`expiry-time="202901011200Z",permitopen="localhost:80"`)
+	c.Assert(err, IsNil)
+	c.Assert(opts.From, DeepEquals,
+		[]string{"10.0.0.0/8", "!10.1.2.3", "*.example.com"})
+	c.Assert(opts.Command, Equals, `echo "hi"`)
+	c.Assert(opts.HasCommand, Equals, true)
+	c.Assert(opts.Environment, DeepEquals, []string{"FOO=bar"})
+	c.Assert(opts.NoPty, Equals, true)
+	c.Assert(opts.PermitsPty(), Equals, false)
+	c.Assert(opts.PermitsPortForwarding(), Equals, true)
+	c.Assert(opts.PermitOpen, DeepEquals, []string{"localhost:80"})
+	c.Assert(opts.Tunnel, Equals, -1)
+	// the earlier of the two expiry times
+	c.Assert(opts.ExpiryTime.Equal(
+		time.Date(2029, 1, 1, 12, 0, 0, 0, time.UTC)), Equals, true)
+
+	// restrict turns everything off; pty turns it back on
+	opts, err = s.parseOptionsLine(c, "restrict,pty")
+	c.Assert(err, IsNil)
+	c.Assert(opts.PermitsPty(), Equals, true)
+	c.Assert(opts.PermitsPortForwarding(), Equals, false)
+	c.Assert(opts.PermitsAgentForwarding(), Equals, false)
+	c.Assert(opts.PermitsX11Forwarding(), Equals, false)
+	c.Assert(opts.PermitsUserRC(), Equals, false)
+
+	// lines sshd would reject
+	for _, bad := range []string{
+		`no-such-option`,
+		`command=unquoted`,
+		`no-pty="x"`,
+		`environment="=x"`,
+		`environment="A-B=x"`,
+		`expiry-time="2030"`,
+		`tunnel="-1"`,
+	} {
+		_, err = s.parseOptionsLine(c, bad)
+		c.Assert(err, Equals, BadKeyOption)
+	}
+	_, err = s.parseOptionsLine(c, `command="a",command="b"`)
+	c.Assert(err, Equals, DuplicateKeyOption)
+	_, err = s.parseOptionsLine(c, `command="",command="b"`)
+	c.Assert(err, Equals, DuplicateKeyOption)
+}
+
+func (s *XLSuite) TestEvaluateAuthorizedKey(c *C) {
+	opts, err := ParseAuthorizedKeyOptions([]string{
+		`from="10.0.0.0/8,!10.1.2.3,*.example.com,192.168.1.?"`,
+		`command="/usr/bin/rsync --server"`,
+		`expiry-time="20300101Z"`,
+	})
+	c.Assert(err, IsNil)
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cmd, err := opts.Evaluate("", net.ParseIP("10.9.8.7"), now)
+	c.Assert(err, IsNil)
+	c.Assert(cmd, Equals, "/usr/bin/rsync --server")
+
+	// negation wins, whether by address or by name
+	_, err = opts.Evaluate("", net.ParseIP("10.1.2.3"), now)
+	c.Assert(err, Equals, SourceNotPermitted)
+	_, err = opts.Evaluate("Bastion.Example.COM", net.ParseIP("10.1.2.3"), now)
+	c.Assert(err, Equals, SourceNotPermitted)
+
+	// hostname and address wildcards
+	_, err = opts.Evaluate("bastion.example.com", net.ParseIP("172.16.0.1"), now)
+	c.Assert(err, IsNil)
+	_, err = opts.Evaluate("", net.ParseIP("192.168.1.9"), now)
+	c.Assert(err, IsNil)
+	_, err = opts.Evaluate("", net.ParseIP("192.168.1.10"), now)
+	c.Assert(err, Equals, SourceNotPermitted)
+	_, err = opts.Evaluate("example.org", net.ParseIP("172.16.0.1"), now)
+	c.Assert(err, Equals, SourceNotPermitted)
+
+	// expiry
+	_, err = opts.Evaluate("", net.ParseIP("10.9.8.7"),
+		time.Date(2030, 1, 1, 0, 0, 1, 0, time.UTC))
+	c.Assert(err, Equals, KeyExpired)
+
+	// an ill-formed CIDR pattern denies access
+	c.Assert(MatchHostAndIP("", net.ParseIP("10.0.0.1"),
+		[]string{"10.0.0.1/8"}), Equals, false)
+	c.Assert(MatchHostAndIP("", net.ParseIP("10.0.0.1"),
+		[]string{"10.0.0.0/8"}), Equals, true)
+	c.Assert(MatchHostAndIP("", net.ParseIP("2001:db8::1"),
+		[]string{"2001:db8::/32"}), Equals, true)
+
+	// no options: always usable, no forced command
+	opts, err = ParseAuthorizedKeyOptions(nil)
+	c.Assert(err, IsNil)
+	cmd, err = opts.Evaluate("", net.ParseIP("1.2.3.4"), now)
+	c.Assert(err, IsNil)
+	c.Assert(cmd, Equals, "")
+}
+
+func (s *XLSuite) TestMatchPattern(c *C) {
+	c.Assert(MatchPattern("host.example.com", "*.example.com"), Equals, true)
+	c.Assert(MatchPattern("example.com", "*.example.com"), Equals, false)
+	c.Assert(MatchPattern("a1", "a?"), Equals, true)
+	c.Assert(MatchPattern("a", "a?"), Equals, false)
+	c.Assert(MatchPattern("anything", "*"), Equals, true)
+	c.Assert(MatchPatternList("b", []string{"a", "b"}), Equals, 1)
+	c.Assert(MatchPatternList("b", []string{"*", "!b"}), Equals, -1)
+	c.Assert(MatchPatternList("c", []string{"a", "!b"}), Equals, 0)
+}
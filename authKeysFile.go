@@ -0,0 +1,341 @@
+package crypto
+
+// xlCrypto_go/authKeysFile.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"encoding/base64"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// An authorized_keys file held in memory as a list of entries, one per
+// line, so that it can be edited and written back.  Comments, blank
+// lines, and lines which cannot be parsed are kept as they are; lines
+// which are not changed are written back exactly as they were read.
+
+type AuthorizedKeyEntry struct {
+	LineNo     int          // 1-based line number; 0 if added since reading
+	Key        cr.PublicKey // nil for comments, blank lines, and bad lines
+	RawOptions []string     // options as written on the line
+	Options    *AuthorizedKeyOptions
+	Comment    string
+	Err        error // why sshd would ignore the line; nil if it would not
+	line       string
+}
+
+// Whether the entry is a key which sshd would accept.
+func (ent *AuthorizedKeyEntry) IsValid() bool {
+	return ent.Key != nil && ent.Err == nil
+}
+
+// Return the line as it would be written to the file.
+func (ent *AuthorizedKeyEntry) String() string {
+	return ent.line
+}
+
+type AuthorizedKeysFile struct {
+	Entries []*AuthorizedKeyEntry
+}
+
+// Parse the contents of an authorized_keys file.  This never fails:
+// lines which cannot be parsed are recorded with the reason in Err.
+func ParseAuthorizedKeysFile(data []byte) *AuthorizedKeysFile {
+	f := &AuthorizedKeysFile{}
+	text := strings.TrimSuffix(string(data), "\n")
+	if text != "" {
+		for i, line := range strings.Split(text, "\n") {
+			ent := parseAuthorizedKeysLine(line)
+			ent.LineNo = i + 1
+			f.Entries = append(f.Entries, ent)
+		}
+	}
+	return f
+}
+
+// Read and parse an authorized_keys file.
+func ReadAuthorizedKeysFile(path string) (f *AuthorizedKeysFile, err error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		f = ParseAuthorizedKeysFile(data)
+	}
+	return
+}
+
+// Parse one line.  As sshd does, first try the line as a bare key and
+// then as options followed by a key.
+func parseAuthorizedKeysLine(line string) (ent *AuthorizedKeyEntry) {
+	ent = &AuthorizedKeyEntry{line: line}
+	text := strings.TrimSpace(line)
+	if text == "" || text[0] == '#' {
+		return
+	}
+	key, comment, err := parseKeyFields(text)
+	if err != nil && err != UnsupportedKeyType {
+		options, rest, found := splitKeyOptions([]byte(text))
+		if found {
+			key, comment, err = parseKeyFields(string(rest))
+			if err == nil {
+				ent.RawOptions = options
+				ent.Options, err = ParseAuthorizedKeyOptions(options)
+			}
+		}
+	}
+	ent.Key, ent.Comment, ent.Err = key, comment, err
+	return
+}
+
+// Parse "type base64-blob [comment]".  The type must match the type
+// within the blob.
+func parseKeyFields(text string) (
+	key cr.PublicKey, comment string, err error) {
+
+	var (
+		blob, algo []byte
+		ok         bool
+	)
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		err = IllFormedAuthorizedKey
+	} else if blob, err = base64.StdEncoding.DecodeString(fields[1]); err != nil {
+		err = IllFormedAuthorizedKey
+	} else if algo, _, ok = ParseLenHeadedString(blob); !ok ||
+		string(algo) != fields[0] {
+
+		err = IllFormedAuthorizedKey
+	} else if key, _, ok = ParseSSHPublicKey(blob); !ok {
+		// a key of a type we do not handle, or an ill-formed one
+		err = UnsupportedKeyType
+	} else {
+		// the comment is whatever follows the blob
+		rest := strings.TrimSpace(strings.TrimSpace(text)[len(fields[0]):])
+		comment = strings.TrimSpace(rest[len(fields[1]):])
+	}
+	return
+}
+
+// Return the entries holding keys which sshd would accept.
+func (f *AuthorizedKeysFile) Keys() (keys []*AuthorizedKeyEntry) {
+	for _, ent := range f.Entries {
+		if ent.IsValid() {
+			keys = append(keys, ent)
+		}
+	}
+	return
+}
+
+// Return the first valid entry for the key, or nil if there is none.
+// sshd tries each entry for a key in turn, so if options such as from=
+// or expiry-time= refuse the key on this entry a later one may still
+// accept it; FindAll returns them all and Authorize applies them.
+func (f *AuthorizedKeysFile) Find(key cr.PublicKey) *AuthorizedKeyEntry {
+	if ents := f.FindAll(key); len(ents) > 0 {
+		return ents[0]
+	}
+	return nil
+}
+
+// Return the valid entries for the key in the order sshd tries them.
+func (f *AuthorizedKeysFile) FindAll(key cr.PublicKey) (
+	ents []*AuthorizedKeyEntry) {
+
+	blob, err := sshKeyBlob(key)
+	if err == nil {
+		for _, ent := range f.Entries {
+			if ent.IsValid() && sameKey(ent.Key, blob) {
+				ents = append(ents, ent)
+			}
+		}
+	}
+	return
+}
+
+// Decide, as sshd does, whether the key may be used by a client
+// connecting from remoteIP, named remoteHost, at the time given.  The
+// entries for the key are tried in order, skipping cert-authority
+// entries, and the first whose options accept the key is returned
+// with its forced command, if any.  If none does the error is that
+// given by the last entry tried, or KeyNotAuthorized if there is no
+// entry for the key.
+func (f *AuthorizedKeysFile) Authorize(key cr.PublicKey, remoteHost string,
+	remoteIP net.IP, when time.Time) (
+	ent *AuthorizedKeyEntry, command string, err error) {
+
+	err = KeyNotAuthorized
+	for _, e := range f.FindAll(key) {
+		if e.Options == nil {
+			ent, command, err = e, "", nil
+		} else if !e.Options.CertAuthority {
+			command, err = e.Options.Evaluate(remoteHost, remoteIP, when)
+			if err == nil {
+				ent = e
+			}
+		}
+		if ent != nil {
+			break
+		}
+	}
+	return
+}
+
+// Add a key with the options and comment given, unless the file
+// already holds a valid entry for it.  Returns the entry for the key
+// and whether it was added.  Neither the options nor the comment may
+// contain a CR, LF, or NUL, any of which would let them add lines to
+// the file.
+func (f *AuthorizedKeysFile) Add(key cr.PublicKey, options []string,
+	comment string) (ent *AuthorizedKeyEntry, added bool, err error) {
+
+	var (
+		opts *AuthorizedKeyOptions
+		line []byte
+	)
+	if key == nil {
+		err = NilPublicKey
+	} else if breaksLine(comment) {
+		err = BadKeyComment
+	}
+	for i := 0; err == nil && i < len(options); i++ {
+		if breaksLine(options[i]) {
+			err = BadKeyOption
+		}
+	}
+	if err != nil {
+		return
+	}
+	if ent = f.Find(key); ent == nil {
+		opts, err = ParseAuthorizedKeyOptions(options)
+		if err == nil {
+			line, err = PubKeyToDisk(key)
+		}
+		if err == nil {
+			text := strings.TrimSpace(string(line))
+			if len(options) > 0 {
+				text = strings.Join(options, ",") + " " + text
+			}
+			if comment != "" {
+				text += " " + comment
+			}
+			ent = &AuthorizedKeyEntry{
+				Key:        key,
+				RawOptions: options,
+				Options:    opts,
+				Comment:    comment,
+				line:       text,
+			}
+			f.Entries = append(f.Entries, ent)
+			added = true
+		}
+	}
+	return
+}
+
+// Remove every entry for the key, returning the number removed.
+func (f *AuthorizedKeysFile) Remove(key cr.PublicKey) (count int) {
+	blob, err := sshKeyBlob(key)
+	if err == nil {
+		count = f.filter(func(ent *AuthorizedKeyEntry) bool {
+			return ent.Key == nil || !sameKey(ent.Key, blob)
+		})
+	}
+	return
+}
+
+// Remove valid entries which repeat an earlier entry's key and
+// options exactly, returning the number of entries removed.  Entries
+// for the same key with different options are kept, as sshd falls
+// through to them when an earlier entry's options refuse the key, so
+// this does not change which keys are accepted or how.
+func (f *AuthorizedKeysFile) Dedup() (count int) {
+	seen := make(map[string]bool)
+	return f.filter(func(ent *AuthorizedKeyEntry) bool {
+		if !ent.IsValid() {
+			return true
+		}
+		blob, err := sshKeyBlob(ent.Key)
+		if err != nil {
+			return true
+		}
+		// options cannot contain a newline
+		id := string(blob) + "\n" + strings.Join(ent.RawOptions, "\n")
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+		return true
+	})
+}
+
+// Keep the entries for which keep returns true, returning the number
+// dropped.
+func (f *AuthorizedKeysFile) filter(keep func(*AuthorizedKeyEntry) bool) (
+	count int) {
+
+	var kept []*AuthorizedKeyEntry
+	for _, ent := range f.Entries {
+		if keep(ent) {
+			kept = append(kept, ent)
+		} else {
+			count++
+		}
+	}
+	f.Entries = kept
+	return
+}
+
+// Serialize the file, one entry per line.
+func (f *AuthorizedKeysFile) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, ent := range f.Entries {
+		buf.WriteString(ent.line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// Write the file atomically with mode 0600, replacing any existing
+// file at the path.
+func (f *AuthorizedKeysFile) WriteFile(path string) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".authorized_keys.")
+	if err == nil {
+		_, err = tmp.Write(f.Bytes())
+		if err == nil {
+			err = tmp.Chmod(0600)
+		}
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}
+	return
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Return the key in SSH wire format, used for comparing keys.
+func sshKeyBlob(key cr.PublicKey) (blob []byte, err error) {
+	if key == nil {
+		err = NilPublicKey
+	} else {
+		var sshKey ssh.PublicKey
+		if sshKey, err = ssh.NewPublicKey(key); err == nil {
+			blob = sshKey.Marshal()
+		}
+	}
+	return
+}
+
+func sameKey(key cr.PublicKey, blob []byte) bool {
+	b, err := sshKeyBlob(key)
+	return err == nil && bytes.Equal(b, blob)
+}
@@ -0,0 +1,165 @@
+package crypto
+
+// xlCrypto_go/authKeysFile_test.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	. "gopkg.in/check.v1"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func (s *XLSuite) makeDiskKey(c *C, key cr.PublicKey) string {
+	disk, err := PubKeyToDisk(key)
+	c.Assert(err, IsNil)
+	return strings.TrimSpace(string(disk))
+}
+
+func (s *XLSuite) TestAuthorizedKeysFile(c *C) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	ecPub := &ecPriv.PublicKey
+	edLine := s.makeDiskKey(c, edPub)
+	ecLine := s.makeDiskKey(c, ecPub)
+
+	lines := []string{
+		"# managed by hand",
+		"",
+		edLine + " alice@laptop",
+		`from="10.0.0.0/8",no-pty ` + ecLine + " bob@bastion",
+		"ssh-rsa not-base64 broken",
+		`bogus-option ` + ecLine + " carol",
+		"ssh-dss AAAAB3NzaC1kc3MAAACBAP== old dsa key",
+		"  " + edLine + "   alice again  ",
+	}
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	f := ParseAuthorizedKeysFile(data)
+	c.Assert(len(f.Entries), Equals, len(lines))
+	c.Assert(f.Bytes(), DeepEquals, data)
+
+	ents := f.Entries
+	c.Assert(ents[0].Key, IsNil)
+	c.Assert(ents[0].Err, IsNil)
+	c.Assert(ents[1].Key, IsNil)
+	c.Assert(ents[2].LineNo, Equals, 3)
+	c.Assert(ents[2].Comment, Equals, "alice@laptop")
+	c.Assert(ents[2].IsValid(), Equals, true)
+	c.Assert(ents[3].RawOptions, DeepEquals,
+		[]string{`from="10.0.0.0/8"`, "no-pty"})
+	c.Assert(ents[3].Options.NoPty, Equals, true)
+	c.Assert(ents[3].Key.(*ecdsa.PublicKey).Equal(ecPub), Equals, true)
+	c.Assert(ents[4].Err, Equals, IllFormedAuthorizedKey)
+	c.Assert(ents[5].Err, Equals, BadKeyOption)
+	c.Assert(ents[5].Key, NotNil)
+	c.Assert(ents[5].IsValid(), Equals, false)
+	c.Assert(ents[6].Err, Equals, UnsupportedKeyType)
+	c.Assert(ents[7].Comment, Equals, "alice again")
+	// leading whitespace doesn't shift the comment
+	_, comment, err := parseKeyFields("\t  " + edLine + "  alice  ")
+	c.Assert(err, IsNil)
+	c.Assert(comment, Equals, "alice")
+
+	c.Assert(len(f.Keys()), Equals, 3)
+	c.Assert(f.Find(edPub), Equals, ents[2])
+	c.Assert(f.Find(ecPub), Equals, ents[3])
+
+	// dedup drops the second alice line only: its options are the same
+	c.Assert(f.Dedup(), Equals, 1)
+	c.Assert(len(f.Entries), Equals, len(lines)-1)
+
+	// adding a key already present is a no-op
+	ent, added, err := f.Add(edPub, nil, "dup")
+	c.Assert(err, IsNil)
+	c.Assert(added, Equals, false)
+	c.Assert(ent, Equals, ents[2])
+
+	newPub, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	_, _, err = f.Add(newPub, []string{"no-such-option"}, "")
+	c.Assert(err, Equals, BadKeyOption)
+
+	// nothing given may start a new line
+	extra := "\n" + s.makeDiskKey(c, newPub)
+	_, _, err = f.Add(newPub, nil, "dave"+extra)
+	c.Assert(err, Equals, BadKeyComment)
+	_, _, err = f.Add(newPub, nil, "dave\r")
+	c.Assert(err, Equals, BadKeyComment)
+	_, _, err = f.Add(newPub, []string{`command="uptime"` + extra}, "")
+	c.Assert(err, Equals, BadKeyOption)
+	_, _, err = f.Add(newPub, []string{"restrict\x00"}, "")
+	c.Assert(err, Equals, BadKeyOption)
+	_, err = ParseAuthorizedKeyOptions([]string{"command=\"a\nb\""})
+	c.Assert(err, Equals, BadKeyOption)
+	c.Assert(f.Find(newPub), IsNil)
+	ent, added, err = f.Add(newPub, []string{"restrict", `command="uptime"`},
+		"dave@ci")
+	c.Assert(err, IsNil)
+	c.Assert(added, Equals, true)
+	c.Assert(ent.LineNo, Equals, 0)
+	c.Assert(ent.String(), Equals,
+		`restrict,command="uptime" `+s.makeDiskKey(c, newPub)+" dave@ci")
+
+	// removing the ECDSA key takes out the bad-option line as well
+	c.Assert(f.Remove(ecPub), Equals, 2)
+
+	// alice's first entry has no options, so accepts her from anywhere
+	now := time.Now()
+	ent, command, err := f.Authorize(edPub, "", net.ParseIP("10.1.2.3"), now)
+	c.Assert(err, IsNil)
+	c.Assert(ent, Equals, ents[2])
+	c.Assert(command, Equals, "")
+	_, _, err = f.Authorize(ecPub, "", net.ParseIP("10.1.2.3"), now)
+	c.Assert(err, Equals, KeyNotAuthorized)
+
+	// sshd falls through to a later entry for the key, so entries with
+	// different options survive dedup
+	fromPub, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	fromLine := s.makeDiskKey(c, fromPub)
+	f3 := ParseAuthorizedKeysFile([]byte(
+		`from="192.168.1.*" ` + fromLine + " home\n" +
+			`from="192.168.1.*" ` + fromLine + " home again\n" +
+			`from="10.0.0.0/8",command="backup" ` + fromLine + " office\n"))
+	c.Assert(f3.Dedup(), Equals, 1)
+	fromEnts := f3.FindAll(fromPub)
+	c.Assert(fromEnts, HasLen, 2)
+	c.Assert(fromEnts[0].Comment, Equals, "home")
+	c.Assert(fromEnts[1].Comment, Equals, "office")
+	c.Assert(f3.Find(fromPub), Equals, fromEnts[0])
+
+	ent, command, err = f3.Authorize(fromPub, "", net.ParseIP("10.1.2.3"), now)
+	c.Assert(err, IsNil)
+	c.Assert(ent, Equals, fromEnts[1])
+	c.Assert(command, Equals, "backup")
+	ent, command, err = f3.Authorize(fromPub, "",
+		net.ParseIP("192.168.1.7"), now)
+	c.Assert(err, IsNil)
+	c.Assert(ent, Equals, fromEnts[0])
+	c.Assert(command, Equals, "")
+	ent, _, err = f3.Authorize(fromPub, "", net.ParseIP("172.16.0.1"), now)
+	c.Assert(err, Equals, SourceNotPermitted)
+	c.Assert(ent, IsNil)
+
+	// write it out and read it back
+	path := filepath.Join(c.MkDir(), "authorized_keys")
+	c.Assert(f.WriteFile(path), IsNil)
+	info, err := os.Stat(path)
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode().Perm(), Equals, os.FileMode(0600))
+	f2, err := ReadAuthorizedKeysFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(f2.Bytes(), DeepEquals, f.Bytes())
+	c.Assert(len(f2.Keys()), Equals, 2)
+	c.Assert(f2.Find(newPub).Options.Command, Equals, "uptime")
+	c.Assert(f2.Entries[0].String(), Equals, "# managed by hand")
+	c.Assert(f2.Entries[3].String(), Equals, "ssh-rsa not-base64 broken")
+}
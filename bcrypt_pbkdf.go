@@ -0,0 +1,96 @@
+package crypto
+
+// xlCrypto_go/bcrypt_pbkdf.go
+
+import (
+	"crypto/sha512"
+	"golang.org/x/crypto/blowfish"
+)
+
+// bcrypt_pbkdf(3) from OpenBSD, the key derivation function used to
+// protect OpenSSH private keys.  This is PBKDF2 with SHA512 hashing
+// replaced by a bcrypt-like function, and with the output bytes of the
+// blocks interleaved rather than concatenated.
+
+const (
+	BCRYPT_PBKDF_BLOCK = 32
+	MAX_BCRYPT_ROUNDS  = 1 << 11
+)
+
+var bcryptMagic = []byte("OxychromaticBlowfishSwatDynamite")
+
+// Derive a key of keyLen bytes from the passphrase and salt.
+func bcryptPBKDF(passphrase, salt []byte, rounds, keyLen int) (
+	key []byte, err error) {
+
+	if len(passphrase) == 0 {
+		err = NilData
+	} else if rounds < 1 || rounds > MAX_BCRYPT_ROUNDS ||
+		len(salt) == 0 || keyLen < 1 || keyLen > 1024 {
+
+		err = BadKDFParams
+	}
+	if err != nil {
+		return
+	}
+	nBlocks := (keyLen + BCRYPT_PBKDF_BLOCK - 1) / BCRYPT_PBKDF_BLOCK
+	key = make([]byte, nBlocks*BCRYPT_PBKDF_BLOCK)
+
+	h := sha512.New()
+	h.Write(passphrase)
+	shaPass := h.Sum(nil)
+
+	shaSalt := make([]byte, 0, sha512.Size)
+	count := make([]byte, 4)
+	tmp := make([]byte, BCRYPT_PBKDF_BLOCK)
+	out := make([]byte, BCRYPT_PBKDF_BLOCK)
+	for block := 1; block <= nBlocks; block++ {
+		count[0] = byte(block >> 24)
+		count[1] = byte(block >> 16)
+		count[2] = byte(block >> 8)
+		count[3] = byte(block)
+		h.Reset()
+		h.Write(salt)
+		h.Write(count)
+		bcryptHash(tmp, shaPass, h.Sum(shaSalt))
+		copy(out, tmp)
+
+		for i := 2; i <= rounds; i++ {
+			h.Reset()
+			h.Write(tmp)
+			bcryptHash(tmp, shaPass, h.Sum(shaSalt))
+			for j := range out {
+				out[j] ^= tmp[j]
+			}
+		}
+		// interleave the output
+		for i, b := range out {
+			key[i*nBlocks+block-1] = b
+		}
+	}
+	key = key[:keyLen]
+	return
+}
+
+func bcryptHash(out, shaPass, shaSalt []byte) {
+	c, err := blowfish.NewSaltedCipher(shaPass, shaSalt)
+	if err != nil {
+		// impossible: shaPass is never empty
+		panic(err)
+	}
+	for i := 0; i < 64; i++ {
+		blowfish.ExpandKey(shaSalt, c)
+		blowfish.ExpandKey(shaPass, c)
+	}
+	copy(out, bcryptMagic)
+	for i := 0; i < BCRYPT_PBKDF_BLOCK; i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(out[i:i+8], out[i:i+8])
+		}
+	}
+	// the words are little-endian
+	for i := 0; i < BCRYPT_PBKDF_BLOCK; i += 4 {
+		out[i], out[i+1], out[i+2], out[i+3] =
+			out[i+3], out[i+2], out[i+1], out[i]
+	}
+}
@@ -5,6 +5,7 @@ package crypto
 import (
 	"bufio"
 	//"bytes"
+	cr "crypto"
 	"crypto/sha1"
 	"fmt"
 	xu "github.com/jddixon/xlUtil_go"
@@ -111,7 +112,18 @@ func (bl *BuildList) Size() (size uint) {
  * signature but expecting the timestamp to have been set.
  */
 func (bl *BuildList) HashBody() (hash []byte, err error) {
-	d := sha1.New()
+	return bl.HashBodyWith(cr.SHA1)
+}
+
+/**
+ * As HashBody, but using the digest specified, which must be one
+ * of SIG_DIGESTS.
+ */
+func (bl *BuildList) HashBodyWith(h cr.Hash) (hash []byte, err error) {
+	if err = CheckSigDigest(h); err != nil {
+		return
+	}
+	d := h.New()
 
 	// title ----------------------------------------------
 	d.Write([]byte(bl.Title))
@@ -10,10 +10,31 @@ var (
 	EmptyHash            = e.New("empty hash slice parameter")
 	EmptyPath            = e.New("empty path parameter")
 	IllFormedContentLine = e.New("content line not correctly formed")
+	IllFormedDigSigLine  = e.New("digital signature line not correctly formed")
 	ListAlreadySigned    = e.New("list has already been signed")
 	ListNotSigned        = e.New("list has not been signed")
 	NdxOutOfRange        = e.New("list index out of range")
 	NilPrivateKey        = e.New("private key parameter must not be nil")
+	NilKeyStore          = e.New("keystore parameter must not be nil")
 	NilPublicKey         = e.New("public key parameter must not be nil")
 	NilTitle             = e.New("buildList title may not be empty")
+	WrongSigningKey      = e.New("signing key does not match list's public key")
 )
+
+// Returned by SignedBList.Verify when the signature does not verify.
+// The key is identified by its fingerprints as ssh-keygen -l shows
+// them, so that operators can recognize which key signed the list.
+type SigVerificationError struct {
+	Fingerprint    string // SHA256, as ssh-keygen shows by default
+	FingerprintMD5 string // legacy MD5, as ssh-keygen -E md5 shows
+	Err            error
+}
+
+func (e *SigVerificationError) Error() string {
+	return e.Err.Error() + " for key " + e.Fingerprint +
+		" (" + e.FingerprintMD5 + ")"
+}
+
+func (e *SigVerificationError) Unwrap() error {
+	return e.Err
+}
@@ -6,12 +6,15 @@ import (
 	"bufio"
 	"bytes"
 	"crypto"
-	"crypto/rand"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha1"
+	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	xc "github.com/jddixon/xlCrypto_go"
+	"github.com/jddixon/xlCrypto_go/keystore"
 	xu "github.com/jddixon/xlUtil_go"
 	"io"
 	"strings"
@@ -28,22 +31,39 @@ var _ = fmt.Print
  *
  * The hash for a serialized SignedBList, its title key, is the 20-byte
  * BuildList hash, an SHA1-based function of the SignedBList's title and
- * RSA public key.
+ * public key.
  *
  * The digital signature in the last line is calculated from the
- * SHA1 digest of the header lines (public key, title, and timestamp
- * lines, each CRLF-terminated) and the content lines.
+ * digest of the header lines (public key, title, and timestamp
+ * lines, each CRLF-terminated) and the content lines.  Sign() uses
+ * SHA256; another of xc.SIG_DIGESTS may be chosen with SignWithOpts().
+ * Unless the digest is SHA1 the signature line begins with the name of
+ * the digest ("SHA-256", "SHA3-512", etc) followed by a space.  Lists
+ * signed over SHA1, which have no digest name, still verify.
+ *
+ * The public key may be an *rsa.PublicKey, an ed25519.PublicKey or
+ * an *ecdsa.PublicKey.  RSA lists are signed PKCS#1 v1.5, by default
+ * SHA256withRSA; with Ed25519 the digest is itself signed, and so is
+ * always a 512-bit digest, SHA-512 unless SHA3-512 is chosen; ECDSA
+ * signs the digest.
+ *
+ * RSA lists may instead be signed RSASSA-PSS by passing an
+ * *rsa.PSSOptions to SignWithOpts().  The signature line then begins
+ * with the scheme name, "PSS", and the digest name, always present,
+ * each followed by a space.
  */
 type SignedBList struct {
-	PubKey *rsa.PublicKey
-	DigSig []byte
+	PubKey     crypto.PublicKey
+	DigSig     []byte
+	DigestAlgo crypto.Hash // zero means SHA1, as in older lists
+	SigScheme  string      // empty or xc.SIG_SCHEME_PSS
 	xc.BuildList
 }
 
-func NewSignedBList(title string, pubkey *rsa.PublicKey) (
+func NewSignedBList(title string, pubkey crypto.PublicKey) (
 	sList *SignedBList, err error) {
 
-	if pubkey == nil {
+	if isNilKey(pubkey) {
 		err = NilPublicKey
 	} else if title == "" {
 		err = NilTitle
@@ -150,15 +170,38 @@ func (sl *SignedBList) SetDigSig(val []byte) {
 	copy(sl.DigSig, val)
 }
 
+/**
+ * Return the digest used in signing the list, SHA1 unless some other
+ * digest has been chosen.
+ */
+func (sl *SignedBList) GetDigestAlgo() crypto.Hash {
+	if sl.DigestAlgo == 0 {
+		return crypto.SHA1
+	}
+	return sl.DigestAlgo
+}
+
 /**
  * Set a timestamp and calculate a digital signature.  First
- * calculate the SHA1 hash of the pubKey, title, timestamp,
+ * calculate the SHA256 hash of the pubKey, title, timestamp,
  * and content lines, excluding the terminating CRLF in each
- * case, then encrypt that using the RSA private key supplied.
+ * case, then sign that using the private key supplied.
  *
- * @param key RSAKey whose secret materials are used to sign
+ * @param key RSA, Ed25519 or ECDSA private key used to sign
+ */
+func (sl *SignedBList) Sign(skPriv crypto.Signer) (err error) {
+	return sl.SignWithOpts(skPriv, crypto.SHA256)
+}
+
+/**
+ * As Sign(), but the digest is opts.HashFunc(), which must be one of
+ * xc.SIG_DIGESTS.  If opts is an *rsa.PSSOptions the list is signed
+ * RSASSA-PSS with the salt length given.  Ed25519 keys sign SHA-512
+ * in place of any shorter digest.  The digest and scheme are recorded
+ * in the list.
  */
-func (sl *SignedBList) Sign(skPriv *rsa.PrivateKey) (err error) {
+func (sl *SignedBList) SignWithOpts(skPriv crypto.Signer,
+	opts crypto.SignerOpts) (err error) {
 
 	var (
 		digSig, hash []byte
@@ -166,16 +209,25 @@ func (sl *SignedBList) Sign(skPriv *rsa.PrivateKey) (err error) {
 
 	if sl.DigSig != nil {
 		err = ListAlreadySigned
-	} else if skPriv == nil {
+	} else if isNilKey(skPriv) {
 		err = NilPrivateKey
+	} else if opts == nil {
+		err = xc.UnsupportedDigest
 	} else {
+		_, isEd := skPriv.Public().(ed25519.PublicKey)
+		_, isPSS := opts.(*rsa.PSSOptions)
+		if isEd && !isPSS && opts.HashFunc().Size() < xc.ED25519_DIGEST_LEN {
+			opts = crypto.SHA512
+		}
+		digestAlgo := opts.HashFunc()
 		sl.Timestamp = xu.Timestamp(time.Now().UnixNano())
-		hash, err = sl.HashBody()
+		hash, err = sl.HashBodyWith(digestAlgo)
 		if err == nil {
-			digSig, err = rsa.SignPKCS1v15(
-				rand.Reader, skPriv, crypto.SHA1, hash)
+			digSig, err = xc.SignDigestWithOpts(skPriv, opts, hash)
 			if err == nil {
 				sl.DigSig = digSig
+				sl.DigestAlgo = digestAlgo
+				sl.SigScheme = xc.SigSchemeName(opts)
 			}
 		}
 		if err != nil {
@@ -185,25 +237,118 @@ func (sl *SignedBList) Sign(skPriv *rsa.PrivateKey) (err error) {
 	return
 }
 
+/**
+ * As SignWithOpts(), but the private key is the one stored under the
+ * name in the keystore, decrypted with the passphrase if need be.  The
+ * key must match the list's public key, and its recorded usage must
+ * allow signing build lists or signing in general.
+ */
+func (sl *SignedBList) SignByName(ks *keystore.KeyStore, name string,
+	passphrase []byte, opts crypto.SignerOpts) (err error) {
+
+	var (
+		info   *keystore.KeyInfo
+		skPriv crypto.Signer
+	)
+	if ks == nil {
+		err = NilKeyStore
+	} else if info, err = ks.Info(name); err == nil &&
+		!info.Permits(keystore.USAGE_BUILD_LIST) &&
+		!info.Permits(keystore.USAGE_SIGN) {
+
+		err = keystore.UsageNotPermitted
+	}
+	if err == nil {
+		skPriv, err = ks.Signer(name, passphrase)
+	}
+	if err == nil {
+		pub, ok := skPriv.Public().(interface {
+			Equal(crypto.PublicKey) bool
+		})
+		if !ok || !pub.Equal(sl.PubKey) {
+			err = WrongSigningKey
+		} else {
+			err = sl.SignWithOpts(skPriv, opts)
+		}
+	}
+	return
+}
+
 /**
  * Verify that the BuildList agrees with its digital signature,
  * returning nil if it is correct and an appropriate error otherwise.
+ * If the signature itself does not verify, the error is a
+ * *SigVerificationError naming the key's fingerprints.  Lists signed
+ * over any of xc.SIG_DIGESTS, SHA1 included, are accepted; use
+ * VerifyWithDigests to refuse SHA1.
  */
 func (sl *SignedBList) Verify() (err error) {
+	return sl.VerifyWithDigests(xc.SIG_DIGESTS)
+}
+
+/**
+ * As Verify(), but the list must have been signed over one of the
+ * digests accepted, or the error is xc.DigestNotAccepted.  Passing
+ * xc.STRONG_SIG_DIGESTS refuses lists signed over SHA1.
+ */
+func (sl *SignedBList) VerifyWithDigests(accepted []crypto.Hash) (err error) {
 
-	var hash []byte
+	var (
+		hash []byte
+		opts crypto.SignerOpts
+	)
 
 	if sl.DigSig == nil {
 		err = ListNotSigned
 	} else {
-		hash, err = sl.HashBody()
+		digestAlgo := sl.GetDigestAlgo()
+		err = xc.CheckDigestAccepted(digestAlgo, accepted)
+		if err == nil {
+			opts, err = xc.VerifierOpts(sl.SigScheme, digestAlgo)
+		}
 		if err == nil {
-			err = rsa.VerifyPKCS1v15(sl.PubKey, crypto.SHA1, hash, sl.DigSig)
+			hash, err = sl.HashBodyWith(digestAlgo)
 		}
+		if err == nil {
+			err = xc.VerifyDigestWithOpts(sl.PubKey, opts, hash, sl.DigSig)
+			if err != nil {
+				err = sigVerificationError(sl.PubKey, err)
+			}
+		}
+	}
+	return
+}
+
+/**
+ * As Verify(), but the list's public key must also be the key of the
+ * first certificate in the chain, which with any intermediate CA
+ * certificates following it must lead to one of the trusted roots at
+ * the time given, or now if that is zero.  This lets lists be trusted
+ * through a PKI rather than by distributing bare keys.
+ */
+func (sl *SignedBList) VerifyWithCert(chain []*x509.Certificate,
+	roots *x509.CertPool, when time.Time) (err error) {
+
+	if len(chain) == 0 {
+		err = xc.NoCertificates
+	} else if !xc.CertCertifiesKey(chain[0], sl.PubKey) {
+		err = xc.KeyCertMismatch
+	} else if _, err = xc.VerifyCertChain(chain, roots, when); err == nil {
+		err = sl.Verify()
 	}
 	return
 }
 
+// Wrap the error so that it identifies the key by its fingerprints.
+func sigVerificationError(pubKey crypto.PublicKey, err error) error {
+	sha, shaErr := xc.SSHFingerprintSHA256(pubKey)
+	md5, md5Err := xc.SSHFingerprintMD5(pubKey)
+	if shaErr != nil || md5Err != nil {
+		return err
+	}
+	return &SigVerificationError{Fingerprint: sha, FingerprintMD5: md5, Err: err}
+}
+
 // DOCUMENT HASH ////////////////////////////////////////////////////
 
 /**
@@ -219,8 +364,8 @@ func (sl SignedBList) GetHash() []byte {
 
 	d := sha1.New()
 
-	// public key in PKCS1 format
-	pk, _ := xc.RSAPubKeyToWire(sl.PubKey)
+	// public key in PKIX format
+	pk, _ := xc.PubKeyToWire(sl.PubKey)
 	d.Write(pk)
 
 	d.Write([]byte(sl.Title))
@@ -231,13 +376,16 @@ func (sl SignedBList) GetHash() []byte {
 
 func (sList SignedBList) String() (s string, err error) {
 
-	if sList.PubKey == nil {
+	if isNilKey(sList.PubKey) {
 		err = NilPublicKey
 	} else {
 		title, timestamp, pubKey := sList.Strings()
-
-		// pubKey is newline-terminated
-		pubKey = pubKey[:len(pubKey)-1]
+		if pubKey == "" {
+			err = xc.UnsupportedKeyType
+		} else {
+			// pubKey is newline-terminated
+			pubKey = pubKey[:len(pubKey)-1]
+		}
 
 		ss := []string{title, timestamp, pubKey}
 		ss = append(ss, string(xc.CONTENT_START))
@@ -255,6 +403,13 @@ func (sList SignedBList) String() (s string, err error) {
 		if err == nil {
 			ss = append(ss, string(xc.CONTENT_END))
 			myDigSig := base64.StdEncoding.EncodeToString(sList.GetDigSig())
+			h := sList.GetDigestAlgo()
+			if h != crypto.SHA1 || sList.SigScheme != "" {
+				myDigSig = xc.DigestName(h) + " " + myDigSig
+			}
+			if sList.SigScheme != "" {
+				myDigSig = sList.SigScheme + " " + myDigSig
+			}
 			ss = append(ss, myDigSig)
 			s = strings.Join(ss, CRLF) + CRLF
 		}
@@ -267,8 +422,6 @@ func (sList SignedBList) String() (s string, err error) {
  * line terminators.
  *
  * If any error is encountered, this function silently returns an empty string.
- *
- * PANICS if sl.PubKey is nil.
  */
 func (sl *SignedBList) Strings() (title, timestamp, pk string) {
 
@@ -279,7 +432,7 @@ func (sl *SignedBList) Strings() (title, timestamp, pk string) {
 	timestamp = sl.Timestamp.String()
 
 	// public key to SSH format -----------------------
-	pkBytes, _ := xc.RSAPubKeyToDisk(sl.PubKey) // is newline-terminated
+	pkBytes, _ := xc.PubKeyToDisk(sl.PubKey) // is newline-terminated
 	pk = string(pkBytes)
 
 	return
@@ -297,7 +450,7 @@ func ParseSignedBList(in io.Reader) (sList *SignedBList, err error) {
 
 	var (
 		line   []byte
-		pubKey *rsa.PublicKey
+		pubKey crypto.PublicKey
 		title  string
 		t      xu.Timestamp // binary form
 	)
@@ -314,7 +467,7 @@ func ParseSignedBList(in io.Reader) (sList *SignedBList, err error) {
 				line, err = xc.NextLineWithoutCRLF(bin)
 				if err == nil {
 					line = append(line, 10) // NEWLINE
-					pubKey, err = xc.RSAPubKeyFromDisk(line)
+					pubKey, err = xc.PubKeyFromDisk(line)
 					if err == nil {
 						line, err = xc.NextLineWithoutCRLF(bin)
 						if err == nil {
@@ -341,11 +494,20 @@ func ParseSignedBList(in io.Reader) (sList *SignedBList, err error) {
 			err = ReadContents(bin, sList, true) // true = is signed
 			if err == nil {
 				// try to read the digital signature line
-				var digSig []byte
+				var (
+					digSig     []byte
+					digestAlgo crypto.Hash
+					sigScheme  string
+				)
 				line, err = xc.NextLineWithoutCRLF(bin)
 				if err == nil || err == io.EOF {
+					sigScheme, digestAlgo, line, err = parseDigSigLine(line)
+				}
+				if err == nil {
 					digSig, err = base64.StdEncoding.DecodeString(string(line))
 					if err == nil || err == io.EOF {
+						sList.DigestAlgo = digestAlgo
+						sList.SigScheme = sigScheme
 						sList.SetDigSig(digSig)
 						if err == io.EOF {
 							err = nil
@@ -359,6 +521,36 @@ func ParseSignedBList(in io.Reader) (sList *SignedBList, err error) {
 	return
 }
 
+// Split the digital signature line into the signature scheme, the
+// digest algorithm and the base64-encoded signature.  If there is no
+// digest name, the digest is SHA1.  If there is no scheme name, the
+// scheme is the key's default.
+func parseDigSigLine(line []byte) (
+	sigScheme string, digestAlgo crypto.Hash, sig []byte, err error) {
+
+	fields := bytes.Fields(line)
+	switch len(fields) {
+	case 0:
+		digestAlgo = crypto.SHA1
+	case 1:
+		digestAlgo, sig = crypto.SHA1, fields[0]
+	case 2:
+		digestAlgo, err = xc.ParseDigestName(string(fields[0]))
+		sig = fields[1]
+	case 3:
+		sigScheme = string(fields[0])
+		if sigScheme != xc.SIG_SCHEME_PSS {
+			err = xc.UnsupportedSigScheme
+		} else {
+			digestAlgo, err = xc.ParseDigestName(string(fields[1]))
+			sig = fields[2]
+		}
+	default:
+		err = IllFormedDigSigLine
+	}
+	return
+}
+
 // DOCUMENT HASH ////////////////////////////////////////////////////
 
 // Calculates and returns the document hash.
@@ -370,7 +562,7 @@ func (sl *SignedBList) calcDocHash() []byte {
 	d.Write([]byte(sl.Timestamp.String()))
 
 	// serialized public key, ignoring possible error
-	pk, _ := xc.RSAPubKeyToWire(sl.PubKey)
+	pk, _ := xc.PubKeyToWire(sl.PubKey)
 	d.Write(pk)
 
 	// content lines
@@ -382,3 +574,27 @@ func (sl *SignedBList) calcDocHash() []byte {
 	}
 	return d.Sum(nil)
 }
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Return whether a key parameter is missing, either because it is a
+// nil interface or because it holds a nil RSA, Ed25519 or ECDSA key.
+func isNilKey(key interface{}) bool {
+	switch k := key.(type) {
+	case nil:
+		return true
+	case *rsa.PublicKey:
+		return k == nil
+	case *rsa.PrivateKey:
+		return k == nil
+	case ed25519.PublicKey:
+		return k == nil
+	case ed25519.PrivateKey:
+		return k == nil
+	case *ecdsa.PublicKey:
+		return k == nil
+	case *ecdsa.PrivateKey:
+		return k == nil
+	}
+	return false
+}
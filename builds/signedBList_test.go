@@ -4,14 +4,23 @@ package builds
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	xr "github.com/jddixon/rnglib_go"
+	xc "github.com/jddixon/xlCrypto_go"
+	"github.com/jddixon/xlCrypto_go/keystore"
 	xu "github.com/jddixon/xlUtil_go"
 	. "gopkg.in/check.v1"
 	"strings"
+	"time"
 )
 
 var _ = fmt.Print
@@ -141,3 +150,379 @@ func (s *XLSuite) TestGeneratedSignedBList(c *C) {
 	c.Assert(myList.GetPath(1), Equals, "fileForHash1")
 
 }
+
+func (s *XLSuite) TestEd25519SignedBList(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	pubKey, key, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	myList, err := NewSignedBList("document 2", pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(myList.IsSigned(), Equals, false)
+
+	for i := 0; i < 3; i++ {
+		hash := make([]byte, xu.SHA1_BIN_LEN)
+		rng.NextBytes(hash)
+		err = myList.Add(hash, fmt.Sprintf("fileForHash%d", i))
+		c.Assert(err, IsNil)
+	}
+	// an Ed25519 signature over a shorter digest is refused
+	hash, err := myList.HashBodyWith(crypto.SHA1)
+	c.Assert(err, IsNil)
+	myList.DigSig = ed25519.Sign(key, hash)
+	err = myList.Verify()
+	c.Assert(err, NotNil)
+	c.Assert(errors.Is(err, xc.UnsupportedDigest), Equals, true)
+	myList.DigSig = nil
+
+	err = myList.Sign(key)
+	c.Assert(err, IsNil)
+	c.Assert(myList.IsSigned(), Equals, true)
+	c.Assert(myList.DigestAlgo, Equals, crypto.SHA512)
+	c.Assert(myList.Verify(), IsNil)
+
+	myDoc, err := myList.String()
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(myDoc, CRLF+"ssh-ed25519 "), Equals, true)
+	c.Assert(strings.Contains(myDoc, CRLF+xc.DigestName(crypto.SHA512)+" "),
+		Equals, true)
+
+	list2, err := ParseSignedBList(strings.NewReader(myDoc))
+	c.Assert(err, IsNil)
+	c.Assert(list2.Size(), Equals, uint(3))
+	c.Assert(list2.PubKey.(ed25519.PublicKey).Equal(pubKey), Equals, true)
+	c.Assert(list2.Verify(), IsNil)
+	str, err := list2.String()
+	c.Assert(err, IsNil)
+	c.Assert(str, Equals, myDoc)
+
+	// a bad signature is reported with the key's fingerprints
+	list2.DigSig[0] ^= 1
+	err = list2.Verify()
+	sigErr, ok := err.(*SigVerificationError)
+	c.Assert(ok, Equals, true)
+	c.Assert(sigErr.Unwrap(), Equals, xc.SigVerificationFailure)
+	fp, err := xc.SSHFingerprintSHA256(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(sigErr.Fingerprint, Equals, fp)
+	c.Assert(strings.HasPrefix(sigErr.FingerprintMD5, "MD5:"), Equals, true)
+	c.Assert(strings.Contains(sigErr.Error(), fp), Equals, true)
+
+	// a nil key is rejected
+	_, err = NewSignedBList("document 3", ed25519.PublicKey(nil))
+	c.Assert(err, Equals, NilPublicKey)
+	_, err = NewSignedBList("document 3", (*rsa.PublicKey)(nil))
+	c.Assert(err, Equals, NilPublicKey)
+	_, err = NewSignedBList("document 3", (*ecdsa.PublicKey)(nil))
+	c.Assert(err, Equals, NilPublicKey)
+	list3, err := NewSignedBList("document 3", pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(list3.Sign((*ecdsa.PrivateKey)(nil)), Equals, NilPrivateKey)
+	c.Assert(list3.Sign(ed25519.PrivateKey(nil)), Equals, NilPrivateKey)
+}
+
+func (s *XLSuite) TestECDSASignedBList(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	curves := []elliptic.Curve{elliptic.P256(), elliptic.P384()}
+	algos := []string{"ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384"}
+	for i, curve := range curves {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		c.Assert(err, IsNil)
+		myList, err := NewSignedBList("document 7", &key.PublicKey)
+		c.Assert(err, IsNil)
+		for j := 0; j < 3; j++ {
+			hash := make([]byte, xu.SHA1_BIN_LEN)
+			rng.NextBytes(hash)
+			err = myList.Add(hash, fmt.Sprintf("fileForHash%d", j))
+			c.Assert(err, IsNil)
+		}
+		err = myList.Sign(key)
+		c.Assert(err, IsNil)
+		c.Assert(myList.IsSigned(), Equals, true)
+		c.Assert(myList.Verify(), IsNil)
+
+		myDoc, err := myList.String()
+		c.Assert(err, IsNil)
+		c.Assert(strings.Contains(myDoc, CRLF+algos[i]+" "), Equals, true)
+
+		list2, err := ParseSignedBList(strings.NewReader(myDoc))
+		c.Assert(err, IsNil)
+		c.Assert(list2.Size(), Equals, uint(3))
+		c.Assert(list2.PubKey.(*ecdsa.PublicKey).Equal(&key.PublicKey),
+			Equals, true)
+		c.Assert(list2.GetDigestAlgo(), Equals, myList.GetDigestAlgo())
+		c.Assert(list2.Verify(), IsNil)
+		str, err := list2.String()
+		c.Assert(err, IsNil)
+		c.Assert(str, Equals, myDoc)
+
+		// a list signed by another key does not verify
+		other, err := ecdsa.GenerateKey(curve, rand.Reader)
+		c.Assert(err, IsNil)
+		list2.PubKey = &other.PublicKey
+		c.Assert(list2.Verify(), NotNil)
+	}
+}
+
+func (s *XLSuite) TestSignedBListByName(c *C) {
+	ks, err := keystore.Open(c.MkDir())
+	c.Assert(err, IsNil)
+	passphrase := []byte("build key")
+	_, err = ks.Generate("builder", keystore.ED25519,
+		[]string{keystore.USAGE_BUILD_LIST}, passphrase)
+	c.Assert(err, IsNil)
+	pubKey, err := ks.PublicKey("builder")
+	c.Assert(err, IsNil)
+
+	myList, err := NewSignedBList("document 4", pubKey)
+	c.Assert(err, IsNil)
+	err = myList.Add(make([]byte, xu.SHA1_BIN_LEN), "fileForHash0")
+	c.Assert(err, IsNil)
+
+	c.Assert(myList.SignByName(nil, "builder", passphrase, nil),
+		Equals, NilKeyStore)
+	c.Assert(myList.SignByName(ks, "missing", passphrase, nil),
+		Equals, keystore.KeyNotFound)
+	c.Assert(myList.SignByName(ks, "builder", nil, nil),
+		Equals, xc.PassphraseRequired)
+	c.Assert(myList.IsSigned(), Equals, false)
+
+	err = myList.SignByName(ks, "builder", passphrase, crypto.SHA512)
+	c.Assert(err, IsNil)
+	c.Assert(myList.IsSigned(), Equals, true)
+	c.Assert(myList.DigestAlgo, Equals, crypto.SHA512)
+	c.Assert(myList.Verify(), IsNil)
+
+	// the key must be the list's and be meant for signing
+	_, err = ks.Generate("other", keystore.ED25519, nil, nil)
+	c.Assert(err, IsNil)
+	_, err = ks.Generate("ssh", keystore.ED25519,
+		[]string{keystore.USAGE_SSH}, nil)
+	c.Assert(err, IsNil)
+	list2, err := NewSignedBList("document 5", pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(list2.SignByName(ks, "other", nil, nil), Equals, WrongSigningKey)
+	sshKey, err := ks.PublicKey("ssh")
+	c.Assert(err, IsNil)
+	list3, err := NewSignedBList("document 6", sshKey)
+	c.Assert(err, IsNil)
+	c.Assert(list3.SignByName(ks, "ssh", nil, nil),
+		Equals, keystore.UsageNotPermitted)
+}
+
+func (s *XLSuite) TestSignedBListWithCert(c *C) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	ca, err := xc.NewSelfSignedCert(caKey, &xc.CertOpts{
+		CommonName: "build CA", IsCA: true})
+	c.Assert(err, IsNil)
+	_, skPriv, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	cert, err := xc.NewCASignedCert(skPriv.Public(),
+		&xc.CertOpts{CommonName: "builder"}, ca, caKey)
+	c.Assert(err, IsNil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	chain := []*x509.Certificate{cert}
+
+	myList, err := NewSignedBList("document 7", skPriv.Public())
+	c.Assert(err, IsNil)
+	err = myList.Add(make([]byte, xu.SHA1_BIN_LEN), "fileForHash0")
+	c.Assert(err, IsNil)
+	c.Assert(myList.VerifyWithCert(chain, roots, time.Time{}),
+		Equals, ListNotSigned)
+	c.Assert(myList.Sign(skPriv), IsNil)
+	c.Assert(myList.VerifyWithCert(chain, roots, time.Time{}), IsNil)
+
+	// the certificate must chain to a trusted root, be in force, and
+	// be for the list's key
+	c.Assert(myList.VerifyWithCert(chain, x509.NewCertPool(), time.Time{}),
+		NotNil)
+	c.Assert(myList.VerifyWithCert(chain, roots,
+		time.Now().Add(2*xc.X509_DEFAULT_VALIDITY)), NotNil)
+	c.Assert(myList.VerifyWithCert([]*x509.Certificate{ca}, roots,
+		time.Time{}), Equals, xc.KeyCertMismatch)
+	c.Assert(myList.VerifyWithCert(nil, roots, time.Time{}),
+		Equals, xc.NoCertificates)
+
+	// a tampered signature fails however good the certificate
+	sig := myList.GetDigSig()
+	sig[0] ^= 1
+	myList.SetDigSig(sig)
+	c.Assert(myList.VerifyWithCert(chain, roots, time.Time{}), NotNil)
+}
+
+func (s *XLSuite) TestSignedBListCMS(c *C) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	ca, err := xc.NewSelfSignedCert(caKey, &xc.CertOpts{IsCA: true})
+	c.Assert(err, IsNil)
+	skPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	cert, err := xc.NewCASignedCert(&skPriv.PublicKey, nil, ca, caKey)
+	c.Assert(err, IsNil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	// a serialized list gets a detached .p7s signature
+	myList, err := NewSignedBList("document 8", &skPriv.PublicKey)
+	c.Assert(err, IsNil)
+	err = myList.Add(make([]byte, xu.SHA1_BIN_LEN), "fileForHash0")
+	c.Assert(err, IsNil)
+	c.Assert(myList.Sign(skPriv), IsNil)
+	doc, err := myList.String()
+	c.Assert(err, IsNil)
+	p7s, err := xc.CMSSignDetached(strings.NewReader(doc), cert, skPriv, nil)
+	c.Assert(err, IsNil)
+
+	cs, err := xc.ParseCMSSignature(p7s)
+	c.Assert(err, IsNil)
+	c.Assert(cs.VerifyDetached(strings.NewReader(doc), roots, time.Time{}),
+		IsNil)
+	c.Assert(cs.VerifyDetached(strings.NewReader(doc+"extra"), roots,
+		time.Time{}), Equals, xc.MessageDigestMismatch)
+}
+
+func (s *XLSuite) TestSignedBListDigests(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+
+	for _, h := range xc.SIG_DIGESTS {
+		myList, err := NewSignedBList("document 4", &key.PublicKey)
+		c.Assert(err, IsNil)
+		hash := make([]byte, xu.SHA1_BIN_LEN)
+		rng.NextBytes(hash)
+		c.Assert(myList.Add(hash, "fileForHash0"), IsNil)
+
+		err = myList.SignWithOpts(key, h)
+		c.Assert(err, IsNil)
+		c.Assert(myList.GetDigestAlgo(), Equals, h)
+		c.Assert(myList.Verify(), IsNil)
+
+		myDoc, err := myList.String()
+		c.Assert(err, IsNil)
+		lines := strings.Split(strings.TrimSuffix(myDoc, CRLF), CRLF)
+		sigLine := lines[len(lines)-1]
+		if h == crypto.SHA1 {
+			// legacy format: no digest name
+			c.Assert(strings.Contains(sigLine, " "), Equals, false)
+		} else {
+			c.Assert(strings.HasPrefix(sigLine, xc.DigestName(h)+" "),
+				Equals, true)
+		}
+
+		list2, err := ParseSignedBList(strings.NewReader(myDoc))
+		c.Assert(err, IsNil)
+		c.Assert(list2.GetDigestAlgo(), Equals, h)
+		c.Assert(list2.Verify(), IsNil)
+		str, err := list2.String()
+		c.Assert(err, IsNil)
+		c.Assert(str, Equals, myDoc)
+
+		// claiming a different digest makes verification fail
+		if h != crypto.SHA256 {
+			list2.DigestAlgo = crypto.SHA256
+			c.Assert(list2.Verify(), NotNil)
+		}
+	}
+
+	// an unknown digest name is rejected
+	myList, err := NewSignedBList("document 5", &key.PublicKey)
+	c.Assert(err, IsNil)
+	c.Assert(myList.SignWithOpts(key, crypto.MD5), Equals, xc.UnsupportedDigest)
+	c.Assert(myList.IsSigned(), Equals, false)
+	c.Assert(myList.SignWithOpts(key, crypto.SHA512), IsNil)
+	myDoc, err := myList.String()
+	c.Assert(err, IsNil)
+	badDoc := strings.Replace(myDoc, "SHA-512 ", "MD5 ", 1)
+	_, err = ParseSignedBList(strings.NewReader(badDoc))
+	c.Assert(err, Equals, xc.UnsupportedDigest)
+}
+
+func (s *XLSuite) TestSignDefaultsToSHA256(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	myList, err := NewSignedBList("document 6", &key.PublicKey)
+	c.Assert(err, IsNil)
+	c.Assert(myList.Add(make([]byte, xu.SHA1_BIN_LEN), "fileForHash0"), IsNil)
+
+	c.Assert(myList.Sign(key), IsNil)
+	c.Assert(myList.DigestAlgo, Equals, crypto.SHA256)
+	hash, err := myList.HashBodyWith(crypto.SHA256)
+	c.Assert(err, IsNil)
+	c.Assert(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash,
+		myList.DigSig), IsNil)
+
+	myDoc, err := myList.String()
+	c.Assert(err, IsNil)
+	lines := strings.Split(strings.TrimSuffix(myDoc, CRLF), CRLF)
+	c.Assert(strings.HasPrefix(lines[len(lines)-1], "SHA-256 "), Equals, true)
+	list2, err := ParseSignedBList(strings.NewReader(myDoc))
+	c.Assert(err, IsNil)
+	c.Assert(list2.GetDigestAlgo(), Equals, crypto.SHA256)
+	c.Assert(list2.Verify(), IsNil)
+	c.Assert(list2.VerifyWithDigests(xc.STRONG_SIG_DIGESTS), IsNil)
+
+	// lists signed over SHA1 still verify
+	hash, err = myList.HashBodyWith(crypto.SHA1)
+	c.Assert(err, IsNil)
+	list2.DigSig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, hash)
+	c.Assert(err, IsNil)
+	list2.DigestAlgo = 0
+	c.Assert(list2.Verify(), IsNil)
+	str, err := list2.String()
+	c.Assert(err, IsNil)
+	lines = strings.Split(strings.TrimSuffix(str, CRLF), CRLF)
+	c.Assert(strings.Contains(lines[len(lines)-1], " "), Equals, false)
+	list3, err := ParseSignedBList(strings.NewReader(str))
+	c.Assert(err, IsNil)
+	c.Assert(list3.GetDigestAlgo(), Equals, crypto.SHA1)
+	c.Assert(list3.Verify(), IsNil)
+
+	// unless only strong digests are accepted
+	c.Assert(list3.VerifyWithDigests(xc.STRONG_SIG_DIGESTS), Equals,
+		xc.DigestNotAccepted)
+}
+
+func (s *XLSuite) TestPSSSignedBList(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	myList, err := NewSignedBList("document 6", &key.PublicKey)
+	c.Assert(err, IsNil)
+	hash := make([]byte, xu.SHA1_BIN_LEN)
+	rng.NextBytes(hash)
+	c.Assert(myList.Add(hash, "fileForHash0"), IsNil)
+
+	opts := &rsa.PSSOptions{SaltLength: 16, Hash: crypto.SHA1}
+	c.Assert(myList.SignWithOpts(key, opts), IsNil)
+	c.Assert(myList.SigScheme, Equals, xc.SIG_SCHEME_PSS)
+	c.Assert(myList.Verify(), IsNil)
+
+	myDoc, err := myList.String()
+	c.Assert(err, IsNil)
+	lines := strings.Split(strings.TrimSuffix(myDoc, CRLF), CRLF)
+	c.Assert(strings.HasPrefix(lines[len(lines)-1], "PSS SHA-1 "), Equals, true)
+
+	list2, err := ParseSignedBList(strings.NewReader(myDoc))
+	c.Assert(err, IsNil)
+	c.Assert(list2.SigScheme, Equals, xc.SIG_SCHEME_PSS)
+	c.Assert(list2.GetDigestAlgo(), Equals, crypto.SHA1)
+	c.Assert(list2.Verify(), IsNil)
+	str, err := list2.String()
+	c.Assert(err, IsNil)
+	c.Assert(str, Equals, myDoc)
+
+	// dropping the scheme makes verification fail
+	list2.SigScheme = ""
+	c.Assert(list2.Verify(), NotNil)
+
+	// an unknown scheme is rejected
+	badDoc := strings.Replace(myDoc, "PSS SHA-1 ", "XYZ SHA-1 ", 1)
+	_, err = ParseSignedBList(strings.NewReader(badDoc))
+	c.Assert(err, Equals, xc.UnsupportedSigScheme)
+}
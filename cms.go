@@ -0,0 +1,597 @@
+package crypto
+
+// xlCrypto_go/cms.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"hash"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Detached CMS (RFC 5652) SignedData signatures, the .p7s files made by
+// openssl cms -sign -binary -outform DER and checked by openssl cms
+// -verify -binary -content.  The content, a file or a serialized
+// BuildList, is carried separately.  Each signer signs a set of signed
+// attributes holding the content type, the time of signing, and the
+// digest of the content, and its certificate is sent with the
+// signature, followed by any intermediate CA certificates.
+//
+// RSA keys sign PKCS#1 v1.5, ECDSA keys sign the attributes' digest,
+// and Ed25519 keys sign the attributes themselves, with SHA-512 as the
+// content digest, as RFC 8419 requires; OpenSSL 3.0 cannot check these,
+// so partners using it need RSA or ECDSA signers.  Digests are those of
+// SIG_DIGESTS.  Signatures are written as DER; parsing also accepts
+// PEM, with either of the block types OpenSSL uses.
+
+const (
+	CMS_PEM_TYPE   = "CMS"
+	PKCS7_PEM_TYPE = "PKCS7"
+)
+
+var (
+	oidData              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidAttrContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidEd25519       = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// The OIDs of the digests, and of the RSA and ECDSA signature
+// algorithms using them.
+var cmsDigestOIDs = []struct {
+	hash  cr.Hash
+	oid   asn1.ObjectIdentifier
+	rsa   asn1.ObjectIdentifier
+	ecdsa asn1.ObjectIdentifier
+}{
+	{cr.SHA1, asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26},
+		asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5},
+		asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}},
+	{cr.SHA256, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1},
+		asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11},
+		asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+	{cr.SHA512, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3},
+		asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13},
+		asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}},
+	{cr.SHA3_256, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 8},
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 14},
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 10}},
+	{cr.SHA3_512, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 10},
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 16},
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 12}},
+}
+
+// ASN.1 structures from RFC 5652.  Tagged and optional fields are
+// RawValues, built and taken apart by hand, as encoding/asn1 does not
+// marshal RawValues symmetrically with their tags.
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo cmsEncapContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsEncapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue // IssuerAndSerialNumber or [0] SKID
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue // SET OF
+}
+
+// A parsed detached signature.
+type CMSSignature struct {
+	Certificates []*x509.Certificate // all those sent
+	Signers      []*CMSSigner
+}
+
+// One signer of a CMSSignature.
+type CMSSigner struct {
+	Certificate     *x509.Certificate // nil if it was not sent
+	DigestAlgorithm cr.Hash
+	SigningTime     time.Time // zero if not given
+
+	contentType   asn1.ObjectIdentifier
+	messageDigest []byte
+	signedAttrs   []byte // DER, as signed
+	sigAlgo       asn1.ObjectIdentifier
+	signature     []byte
+}
+
+// Options for CMSSignDetached.
+type CMSSignOpts struct {
+	Hash          cr.Hash             // SHA-256 if zero; SHA-512 for Ed25519
+	SigningTime   time.Time           // now if zero
+	Intermediates []*x509.Certificate // sent after the signer's certificate
+}
+
+// SIGNING //////////////////////////////////////////////////////////
+
+// Sign the content read from r, returning a detached SignedData in DER,
+// the contents of a .p7s file.  The certificate must be for the key;
+// opts may be nil.
+func CMSSignDetached(r io.Reader, cert *x509.Certificate, key cr.Signer,
+	opts *CMSSignOpts) (p7s []byte, err error) {
+
+	var (
+		h                  = cr.SHA256
+		when               = time.Now()
+		intermediates      []*x509.Certificate
+		digestOID          asn1.ObjectIdentifier
+		sigAlgo            pkix.AlgorithmIdentifier
+		digest, attrs, sig []byte
+		sid, signedData    []byte
+	)
+	if opts != nil {
+		if opts.Hash != 0 {
+			h = opts.Hash
+		}
+		if !opts.SigningTime.IsZero() {
+			when = opts.SigningTime
+		}
+		intermediates = opts.Intermediates
+	}
+	if r == nil {
+		err = NilData
+	} else if cert == nil {
+		err = NilCertificate
+	} else if key == nil {
+		err = NilPrivateKey
+	} else if !CertCertifiesKey(cert, key.Public()) {
+		err = KeyCertMismatch
+	}
+	if err == nil {
+		if _, isEd := key.Public().(ed25519.PublicKey); isEd {
+			h = cr.SHA512
+		}
+		if digestOID, err = cmsDigestOID(h); err == nil {
+			sigAlgo, err = cmsSigAlgo(key.Public(), h)
+		}
+	}
+	if err == nil {
+		digest, err = cmsDigest(h, r)
+	}
+	if err == nil {
+		attrs, err = cmsSignedAttrs(digest, when)
+	}
+	if err == nil {
+		if _, isEd := key.Public().(ed25519.PublicKey); isEd {
+			sig, err = key.Sign(rand.Reader, attrs, cr.Hash(0))
+		} else if digest, err = DigestMessage(h, attrs); err == nil {
+			sig, err = SignDigestWithOpts(key, h, digest)
+		}
+	}
+	if err == nil {
+		sid, err = asn1.Marshal(cmsIssuerAndSerial{
+			Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+			SerialNumber: cert.SerialNumber,
+		})
+	}
+	if err == nil {
+		var certs []byte
+		for _, c := range append([]*x509.Certificate{cert}, intermediates...) {
+			if c == nil {
+				err = NilCertificate
+				break
+			}
+			certs = append(certs, c.Raw...)
+		}
+		digestAlgo := pkix.AlgorithmIdentifier{Algorithm: digestOID}
+		if err == nil {
+			signedData, err = asn1.Marshal(cmsSignedData{
+				Version:          1,
+				DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgo},
+				EncapContentInfo: cmsEncapContentInfo{EContentType: oidData},
+				Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific,
+					Tag: 0, IsCompound: true, Bytes: certs},
+				SignerInfos: []cmsSignerInfo{{
+					Version:         1,
+					SID:             asn1.RawValue{FullBytes: sid},
+					DigestAlgorithm: digestAlgo,
+					SignedAttrs: asn1.RawValue{Class: asn1.ClassContextSpecific,
+						Tag: 0, IsCompound: true, Bytes: attrs[cmsHeaderLen(attrs):]},
+					SignatureAlgorithm: sigAlgo,
+					Signature:          sig,
+				}},
+			})
+		}
+	}
+	if err == nil {
+		p7s, err = asn1.Marshal(cmsContentInfo{
+			ContentType: oidSignedData,
+			Content: asn1.RawValue{Class: asn1.ClassContextSpecific,
+				Tag: 0, IsCompound: true, Bytes: signedData},
+		})
+	}
+	return
+}
+
+// Return the signed attributes as a DER SET, the form in which they are
+// signed.  A SET OF is sorted by the attributes' encodings.
+func cmsSignedAttrs(digest []byte, when time.Time) (attrs []byte, err error) {
+	var (
+		values [3][]byte
+		encs   [][]byte
+	)
+	values[0], err = asn1.Marshal(oidData)
+	if err == nil {
+		values[1], err = asn1.Marshal(when.UTC().Truncate(time.Second))
+	}
+	if err == nil {
+		values[2], err = asn1.Marshal(digest)
+	}
+	for i, oid := range []asn1.ObjectIdentifier{
+		oidAttrContentType, oidAttrSigningTime, oidAttrMessageDigest} {
+
+		if err != nil {
+			break
+		}
+		var enc []byte
+		enc, err = asn1.Marshal(cmsAttribute{Type: oid,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal,
+				Tag: asn1.TagSet, IsCompound: true, Bytes: values[i]}})
+		encs = append(encs, enc)
+	}
+	if err == nil {
+		sort.Slice(encs, func(i, j int) bool {
+			return bytes.Compare(encs[i], encs[j]) < 0
+		})
+		attrs, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal,
+			Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(encs, nil)})
+	}
+	return
+}
+
+// Return the length of the tag and length octets of a DER element.
+func cmsHeaderLen(der []byte) int {
+	if der[1] < 0x80 {
+		return 2
+	}
+	return 2 + int(der[1]&0x7f)
+}
+
+// Return the signature algorithm identifier for the key and digest.
+func cmsSigAlgo(pubKey cr.PublicKey, h cr.Hash) (
+	algo pkix.AlgorithmIdentifier, err error) {
+
+	switch pubKey.(type) {
+	case *rsa.PublicKey:
+		algo.Algorithm = oidRSAEncryption
+		algo.Parameters = asn1.NullRawValue
+	case *ecdsa.PublicKey:
+		err = UnsupportedDigest
+		for _, d := range cmsDigestOIDs {
+			if d.hash == h {
+				algo.Algorithm, err = d.ecdsa, nil
+			}
+		}
+	case ed25519.PublicKey:
+		algo.Algorithm = oidEd25519
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Whether the signature algorithm may be used with the key and the
+// signer's digest.  An RSA or ECDSA algorithm naming a digest must name
+// that one; rsaEncryption names none.
+func cmsSigAlgoAllowed(pubKey cr.PublicKey, oid asn1.ObjectIdentifier,
+	h cr.Hash) bool {
+
+	switch pubKey.(type) {
+	case *rsa.PublicKey:
+		if oid.Equal(oidRSAEncryption) {
+			return true
+		}
+		for _, d := range cmsDigestOIDs {
+			if oid.Equal(d.rsa) {
+				return d.hash == h
+			}
+		}
+	case *ecdsa.PublicKey:
+		for _, d := range cmsDigestOIDs {
+			if oid.Equal(d.ecdsa) {
+				return d.hash == h
+			}
+		}
+	case ed25519.PublicKey:
+		return oid.Equal(oidEd25519)
+	}
+	return false
+}
+
+func cmsDigestOID(h cr.Hash) (oid asn1.ObjectIdentifier, err error) {
+	if err = CheckSigDigest(h); err == nil {
+		err = UnsupportedDigest
+		for _, d := range cmsDigestOIDs {
+			if d.hash == h {
+				oid, err = d.oid, nil
+			}
+		}
+	}
+	return
+}
+
+func cmsDigestFromOID(oid asn1.ObjectIdentifier) (h cr.Hash, err error) {
+	err = UnsupportedDigest
+	for _, d := range cmsDigestOIDs {
+		if oid.Equal(d.oid) {
+			h, err = d.hash, CheckSigDigest(d.hash)
+		}
+	}
+	return
+}
+
+// Hash the content read from r.
+func cmsDigest(h cr.Hash, r io.Reader) (digest []byte, err error) {
+	if err = CheckSigDigest(h); err == nil {
+		d := h.New()
+		if _, err = io.Copy(d, r); err == nil {
+			digest = d.Sum(nil)
+		}
+	}
+	return
+}
+
+// PARSING //////////////////////////////////////////////////////////
+
+// Parse a detached SignedData, in DER or PEM.
+func ParseCMSSignature(data []byte) (cs *CMSSignature, err error) {
+	var (
+		ci cmsContentInfo
+		sd cmsSignedData
+	)
+	if data == nil {
+		err = NilData
+	} else if block, _ := pem.Decode(data); block != nil {
+		if block.Type == CMS_PEM_TYPE || block.Type == PKCS7_PEM_TYPE {
+			data = block.Bytes
+		} else {
+			err = IllFormedCMS
+		}
+	}
+	if err == nil {
+		if unmarshalAll(data, &ci) != nil || !ci.ContentType.Equal(oidSignedData) ||
+			ci.Content.Class != asn1.ClassContextSpecific || ci.Content.Tag != 0 ||
+			unmarshalAll(ci.Content.Bytes, &sd) != nil || len(sd.SignerInfos) == 0 {
+
+			err = IllFormedCMS
+		} else if len(sd.EncapContentInfo.EContent.FullBytes) != 0 {
+			err = NotDetachedCMS
+		}
+	}
+	if err == nil {
+		cs = &CMSSignature{}
+		if len(sd.Certificates.Bytes) > 0 {
+			cs.Certificates, err = x509.ParseCertificates(sd.Certificates.Bytes)
+		}
+	}
+	for i := 0; err == nil && i < len(sd.SignerInfos); i++ {
+		var signer *CMSSigner
+		if signer, err = cs.parseSigner(&sd.SignerInfos[i]); err == nil {
+			cs.Signers = append(cs.Signers, signer)
+		}
+	}
+	if err != nil {
+		cs = nil
+	}
+	return
+}
+
+func (cs *CMSSignature) parseSigner(si *cmsSignerInfo) (
+	signer *CMSSigner, err error) {
+
+	signer = &CMSSigner{
+		sigAlgo:   si.SignatureAlgorithm.Algorithm,
+		signature: si.Signature,
+	}
+	signer.DigestAlgorithm, err = cmsDigestFromOID(si.DigestAlgorithm.Algorithm)
+	if err == nil {
+		signer.Certificate, err = cs.findCert(si.SID)
+	}
+	if err == nil && len(si.SignedAttrs.FullBytes) > 0 {
+		// the attributes are signed with the SET tag, not the implicit [0]
+		signer.signedAttrs = append([]byte{}, si.SignedAttrs.FullBytes...)
+		signer.signedAttrs[0] = asn1.TagSet | 0x20
+		err = signer.parseAttrs(si.SignedAttrs.Bytes)
+	}
+	return
+}
+
+// Find the certificate named by the signer identifier, if it was sent.
+func (cs *CMSSignature) findCert(sid asn1.RawValue) (
+	cert *x509.Certificate, err error) {
+
+	var ias cmsIssuerAndSerial
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range cs.Certificates {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				cert = c
+			}
+		}
+	} else if unmarshalAll(sid.FullBytes, &ias) == nil && ias.SerialNumber != nil {
+		for _, c := range cs.Certificates {
+			if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) &&
+				c.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+				cert = c
+			}
+		}
+	} else {
+		err = IllFormedCMS
+	}
+	return
+}
+
+// Parse the signed attributes the library uses, ignoring any others.
+func (signer *CMSSigner) parseAttrs(data []byte) (err error) {
+	seen := make(map[string]bool)
+	for err == nil && len(data) > 0 {
+		var (
+			attr   cmsAttribute
+			target interface{}
+		)
+		if data, err = asn1.Unmarshal(data, &attr); err != nil {
+			err = IllFormedCMS
+			break
+		}
+		switch {
+		case attr.Type.Equal(oidAttrContentType):
+			target = &signer.contentType
+		case attr.Type.Equal(oidAttrMessageDigest):
+			target = &signer.messageDigest
+		case attr.Type.Equal(oidAttrSigningTime):
+			target = &signer.SigningTime
+		default:
+			continue
+		}
+		// each of these may appear only once and with a single value
+		// (RFC 5652 section 11)
+		name := attr.Type.String()
+		if rest, e := asn1.Unmarshal(attr.Values.Bytes, target); e != nil ||
+			len(rest) > 0 || seen[name] {
+
+			err = IllFormedCMS
+		}
+		seen[name] = true
+	}
+	return
+}
+
+// VERIFICATION /////////////////////////////////////////////////////
+
+// Check the signature over the content read from r.  Every signer's
+// signature must be good, its signed attributes must include the
+// content type and the digest of the content, and its certificate,
+// with the others sent, must lead to one of the roots at the time
+// given, or now if that is zero.  A signer's SigningTime is asserted by
+// the signer alone; callers who trust it may pass it as the time.  A
+// signature without signers is ill-formed.  Signers may use any of
+// SIG_DIGESTS, SHA1 included; use VerifyDetachedWithDigests to refuse
+// SHA1.
+func (cs *CMSSignature) VerifyDetached(r io.Reader, roots *x509.CertPool,
+	when time.Time) (err error) {
+
+	return cs.VerifyDetachedWithDigests(r, roots, when, SIG_DIGESTS)
+}
+
+// As VerifyDetached, but every signer must use one of the digests
+// accepted, or the error is DigestNotAccepted.  Passing
+// STRONG_SIG_DIGESTS refuses SHA1 signatures.
+func (cs *CMSSignature) VerifyDetachedWithDigests(r io.Reader,
+	roots *x509.CertPool, when time.Time, accepted []cr.Hash) (err error) {
+
+	digesters := make(map[cr.Hash]hash.Hash)
+	var writers []io.Writer
+	if cs == nil || r == nil {
+		err = NilData
+	} else if roots == nil {
+		err = NilCertPool
+	} else if len(cs.Signers) == 0 {
+		err = IllFormedCMS
+	}
+	for i := 0; err == nil && i < len(cs.Signers); i++ {
+		h := cs.Signers[i].DigestAlgorithm
+		err = CheckDigestAccepted(h, accepted)
+		if err == nil && digesters[h] == nil {
+			digesters[h] = h.New()
+			writers = append(writers, digesters[h])
+		}
+	}
+	if err == nil {
+		_, err = io.Copy(io.MultiWriter(writers...), r)
+	}
+	for i := 0; err == nil && i < len(cs.Signers); i++ {
+		signer := cs.Signers[i]
+		digest := digesters[signer.DigestAlgorithm].Sum(nil)
+		if err = signer.verify(digest); err == nil {
+			chain := []*x509.Certificate{signer.Certificate}
+			for _, c := range cs.Certificates {
+				if c != signer.Certificate {
+					chain = append(chain, c)
+				}
+			}
+			_, err = VerifyCertChain(chain, roots, when)
+		}
+	}
+	return
+}
+
+// Check the signer's attributes against the content's digest and its
+// signature over the attributes.
+func (signer *CMSSigner) verify(contentDigest []byte) (err error) {
+	var pubKey cr.PublicKey
+	if signer.Certificate == nil {
+		err = MissingSignerCert
+	} else if signer.signedAttrs == nil || signer.contentType == nil ||
+		signer.messageDigest == nil {
+
+		err = MissingSignedAttr
+	} else if !signer.contentType.Equal(oidData) {
+		err = IllFormedCMS
+	} else if !bytes.Equal(signer.messageDigest, contentDigest) {
+		err = MessageDigestMismatch
+	} else {
+		pubKey = signer.Certificate.PublicKey
+		if !cmsSigAlgoAllowed(pubKey, signer.sigAlgo,
+			signer.DigestAlgorithm) {
+
+			err = UnsupportedSigScheme
+		}
+	}
+	if err == nil {
+		if pk, isEd := pubKey.(ed25519.PublicKey); isEd {
+			if !ed25519.Verify(pk, signer.signedAttrs, signer.signature) {
+				err = SigVerificationFailure
+			}
+		} else {
+			var digest []byte
+			h := signer.DigestAlgorithm
+			if digest, err = DigestMessage(h, signer.signedAttrs); err == nil &&
+				VerifyDigestWithOpts(pubKey, h, digest, signer.signature) != nil {
+
+				err = SigVerificationFailure
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,262 @@
+package crypto
+
+// xlCrypto_go/cms_test.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	. "gopkg.in/check.v1"
+	"strings"
+	"time"
+)
+
+// A detached signature over OPENSSL_CMS_MSG made by openssl cms -sign
+// -binary -md sha256 with an ECDSA P-256 key certified by the root,
+// both valid until 2126.
+const (
+	OPENSSL_CMS_MSG  = "The quick brown fox jumps over the lazy dog\n"
+	OPENSSL_CMS_ROOT = `-----BEGIN CERTIFICATE-----
+MIIBlDCCATugAwIBAgIUUm7q45RXMUMWiJtWi9+hUywldQgwCgYIKoZIzj0EAwIw
+FzEVMBMGA1UEAwwMUGFydG5lciBSb290MCAXDTI2MTAxNzA0MTcyMloYDzIxMjYw
+OTIzMDQxNzIyWjAXMRUwEwYDVQQDDAxQYXJ0bmVyIFJvb3QwWTATBgcqhkjOPQIB
+BggqhkjOPQMBBwNCAAQQEEXQv2CX19FaPnOfdEWhQ9WZMpBLC46wisxWZ0PcJ38v
+/3c6QT0TwTIEyRveysF7ageeFb6FKJVeSo3ZnHLco2MwYTAdBgNVHQ4EFgQUKt91
+lsMvyLltSZNEfNL9hnBMR/UwHwYDVR0jBBgwFoAUKt91lsMvyLltSZNEfNL9hnBM
+R/UwDwYDVR0TAQH/BAUwAwEB/zAOBgNVHQ8BAf8EBAMCAQYwCgYIKoZIzj0EAwID
+RwAwRAIgHsQxd4Kiiv381rTG7gMs2IIrgXjqdm1EHEBxK+WhEfsCIDA0bIgrGLN9
+OJ3rbHNT5QeADJR8pUF2iHeOsrIdZI+R
+-----END CERTIFICATE-----
+`
+	OPENSSL_CMS_SIG = `-----BEGIN CMS-----
+MIIDZAYJKoZIhvcNAQcCoIIDVTCCA1ECAQExDTALBglghkgBZQMEAgEwCwYJKoZI
+hvcNAQcBoIIBqTCCAaUwggFMoAMCAQICFFeLcm/L+lXWlo3yHliyRXx5C7GWMAoG
+CCqGSM49BAMCMBcxFTATBgNVBAMMDFBhcnRuZXIgUm9vdDAgFw0yNjEwMTcwNDE3
+MjNaGA8yMTI2MDkyMzA0MTcyM1owGTEXMBUGA1UEAwwOcmVsZWFzZSBzaWduZXIw
+WTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAARLrfATBdosce46jPUt80hkgo3oEmrO
+hYu2lrRQ9Fd4ApXWQbWgFEjCuRM2gjj5CPSwfoo1NFE4UWv6wX1OjIbao3IwcDAJ
+BgNVHRMEAjAAMA4GA1UdDwEB/wQEAwIHgDATBgNVHSUEDDAKBggrBgEFBQcDAzAd
+BgNVHQ4EFgQUQlF6KjWJNDmSyikSdAZw638FivIwHwYDVR0jBBgwFoAUKt91lsMv
+yLltSZNEfNL9hnBMR/UwCgYIKoZIzj0EAwIDRwAwRAIgQRfoarKJsKFOHePgaJkk
+l/xK48G0snAsc0Kl+eE43ZICIBBETbvocbOfC0KzmTRmXEjQhrudu6FyNa+UUm/S
+l0DxMYIBgTCCAX0CAQEwLzAXMRUwEwYDVQQDDAxQYXJ0bmVyIFJvb3QCFFeLcm/L
++lXWlo3yHliyRXx5C7GWMAsGCWCGSAFlAwQCAaCB5DAYBgkqhkiG9w0BCQMxCwYJ
+KoZIhvcNAQcBMBwGCSqGSIb3DQEJBTEPFw0yNjEwMTcwNDE3MjNaMC8GCSqGSIb3
+DQEJBDEiBCDAOQX82rKXUTpiDsge1GykTdti1By72D60paNZK+JqaTB5BgkqhkiG
+9w0BCQ8xbDBqMAsGCWCGSAFlAwQBKjALBglghkgBZQMEARYwCwYJYIZIAWUDBAEC
+MAoGCCqGSIb3DQMHMA4GCCqGSIb3DQMCAgIAgDANBggqhkiG9w0DAgIBQDAHBgUr
+DgMCBzANBggqhkiG9w0DAgIBKDAKBggqhkjOPQQDAgRHMEUCIQC/e5RTA+7nsXGr
+p9wIlSinVR0AQN6Lc0ZOvj5edJcCvwIgfN2WZ/1kEPnKxZJD3hPshk7ebtTrUWvE
+y4Q+5DT9tzg=
+-----END CMS-----
+`
+)
+
+func (s *XLSuite) TestOpenSSLCMSSignature(c *C) {
+	chain, err := ParseCertChainPEM([]byte(OPENSSL_CMS_ROOT))
+	c.Assert(err, IsNil)
+	roots := x509.NewCertPool()
+	roots.AddCert(chain[0])
+
+	cs, err := ParseCMSSignature([]byte(OPENSSL_CMS_SIG))
+	c.Assert(err, IsNil)
+	c.Assert(cs.Certificates, HasLen, 1)
+	c.Assert(cs.Signers, HasLen, 1)
+	signer := cs.Signers[0]
+	c.Assert(signer.Certificate, Equals, cs.Certificates[0])
+	c.Assert(signer.Certificate.Subject.CommonName, Equals, "release signer")
+	c.Assert(signer.DigestAlgorithm, Equals, cr.SHA256)
+	c.Assert(signer.SigningTime.Equal(
+		time.Date(2026, 10, 17, 4, 17, 23, 0, time.UTC)), Equals, true)
+
+	c.Assert(cs.VerifyDetached(strings.NewReader(OPENSSL_CMS_MSG), roots,
+		signer.SigningTime), IsNil)
+	c.Assert(cs.VerifyDetached(strings.NewReader("The quick brown fox"),
+		roots, signer.SigningTime), Equals, MessageDigestMismatch)
+	c.Assert(cs.VerifyDetached(strings.NewReader(OPENSSL_CMS_MSG),
+		x509.NewCertPool(), signer.SigningTime), NotNil)
+	c.Assert(cs.VerifyDetached(strings.NewReader(OPENSSL_CMS_MSG), nil,
+		signer.SigningTime), Equals, NilCertPool)
+	c.Assert(cs.VerifyDetachedWithDigests(strings.NewReader(OPENSSL_CMS_MSG),
+		roots, signer.SigningTime, STRONG_SIG_DIGESTS), IsNil)
+	c.Assert(cs.VerifyDetachedWithDigests(strings.NewReader(OPENSSL_CMS_MSG),
+		roots, signer.SigningTime, []cr.Hash{cr.SHA512}),
+		Equals, DigestNotAccepted)
+
+	// the same signature in DER, as in a .p7s file
+	block, _ := pem.Decode([]byte(OPENSSL_CMS_SIG))
+	cs, err = ParseCMSSignature(block.Bytes)
+	c.Assert(err, IsNil)
+	c.Assert(cs.VerifyDetached(strings.NewReader(OPENSSL_CMS_MSG), roots,
+		cs.Signers[0].SigningTime), IsNil)
+
+	// a signer whose certificate was not sent
+	cs.Signers[0].Certificate = nil
+	c.Assert(cs.VerifyDetached(strings.NewReader(OPENSSL_CMS_MSG), roots,
+		time.Time{}), Equals, MissingSignerCert)
+
+	_, err = ParseCMSSignature(nil)
+	c.Assert(err, Equals, NilData)
+	_, err = ParseCMSSignature([]byte(OPENSSL_CMS_ROOT))
+	c.Assert(err, Equals, IllFormedCMS)
+	_, err = ParseCMSSignature(block.Bytes[:len(block.Bytes)-1])
+	c.Assert(err, Equals, IllFormedCMS)
+}
+
+func (s *XLSuite) TestCMSSignDetached(c *C) {
+	_, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	root, err := NewSelfSignedCert(rootKey, &CertOpts{IsCA: true})
+	c.Assert(err, IsNil)
+	interKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	c.Assert(err, IsNil)
+	inter, err := NewCASignedCert(interKey.Public(),
+		&CertOpts{CommonName: "intermediate", IsCA: true}, root, rootKey)
+	c.Assert(err, IsNil)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	msg := []byte("content of a file to be signed\r\n")
+	signedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, key := range []cr.Signer{rsaKey, ecKey, edKey} {
+		cert, err := NewCASignedCert(key.Public(), nil, inter, interKey)
+		c.Assert(err, IsNil)
+		for _, h := range []cr.Hash{0, cr.SHA512, cr.SHA3_256} {
+			p7s, err := CMSSignDetached(bytes.NewReader(msg), cert, key,
+				&CMSSignOpts{Hash: h, SigningTime: signedAt,
+					Intermediates: []*x509.Certificate{inter}})
+			c.Assert(err, IsNil)
+			cs, err := ParseCMSSignature(p7s)
+			c.Assert(err, IsNil)
+			c.Assert(cs.Certificates, HasLen, 2)
+			signer := cs.Signers[0]
+			c.Assert(signer.Certificate.Equal(cert), Equals, true)
+			c.Assert(signer.SigningTime.Equal(signedAt), Equals, true)
+			expected := h
+			if _, isEd := key.(ed25519.PrivateKey); isEd {
+				expected = cr.SHA512
+			} else if h == 0 {
+				expected = cr.SHA256
+			}
+			c.Assert(signer.DigestAlgorithm, Equals, expected)
+
+			c.Assert(cs.VerifyDetached(bytes.NewReader(msg), roots,
+				time.Time{}), IsNil)
+			c.Assert(cs.VerifyDetached(bytes.NewReader(msg[1:]), roots,
+				time.Time{}), Equals, MessageDigestMismatch)
+
+			// a damaged signature
+			signer.signature[len(signer.signature)/2] ^= 1
+			c.Assert(cs.VerifyDetached(bytes.NewReader(msg), roots,
+				time.Time{}), Equals, SigVerificationFailure)
+		}
+	}
+
+	// without the intermediate the chain is incomplete
+	cert, err := NewCASignedCert(ecKey.Public(), nil, inter, interKey)
+	c.Assert(err, IsNil)
+	p7s, err := CMSSignDetached(bytes.NewReader(msg), cert, ecKey, nil)
+	c.Assert(err, IsNil)
+	cs, err := ParseCMSSignature(p7s)
+	c.Assert(err, IsNil)
+	c.Assert(cs.Certificates, HasLen, 1)
+	c.Assert(cs.VerifyDetached(bytes.NewReader(msg), roots, time.Time{}),
+		NotNil)
+	c.Assert(time.Since(cs.Signers[0].SigningTime) < time.Minute, Equals, true)
+
+	// a signature algorithm naming a digest other than the signer's
+	c.Assert(cs.Signers[0].DigestAlgorithm, Equals, cr.SHA256)
+	cs.Signers[0].sigAlgo = cmsDigestOIDs[2].ecdsa
+	c.Assert(cs.VerifyDetached(bytes.NewReader(msg), roots, time.Time{}),
+		Equals, UnsupportedSigScheme)
+	rsaCert, err := NewCASignedCert(rsaKey.Public(), nil, inter, interKey)
+	c.Assert(err, IsNil)
+	p7s, err = CMSSignDetached(bytes.NewReader(msg), rsaCert, rsaKey,
+		&CMSSignOpts{Intermediates: []*x509.Certificate{inter}})
+	c.Assert(err, IsNil)
+	cs, err = ParseCMSSignature(p7s)
+	c.Assert(err, IsNil)
+	cs.Signers[0].sigAlgo = cmsDigestOIDs[1].rsa
+	c.Assert(cs.VerifyDetached(bytes.NewReader(msg), roots, time.Time{}),
+		IsNil)
+	cs.Signers[0].sigAlgo = cmsDigestOIDs[2].rsa
+	c.Assert(cs.VerifyDetached(bytes.NewReader(msg), roots, time.Time{}),
+		Equals, UnsupportedSigScheme)
+
+	// a signature without signers verifies nothing
+	cs.Signers = nil
+	c.Assert(cs.VerifyDetached(bytes.NewReader(msg), roots, time.Time{}),
+		Equals, IllFormedCMS)
+
+	_, err = CMSSignDetached(bytes.NewReader(msg), cert, rsaKey, nil)
+	c.Assert(err, Equals, KeyCertMismatch)
+	_, err = CMSSignDetached(bytes.NewReader(msg), nil, ecKey, nil)
+	c.Assert(err, Equals, NilCertificate)
+	_, err = CMSSignDetached(nil, cert, ecKey, nil)
+	c.Assert(err, Equals, NilData)
+	_, err = CMSSignDetached(bytes.NewReader(msg), cert, ecKey,
+		&CMSSignOpts{Hash: cr.MD5})
+	c.Assert(err, Equals, UnsupportedDigest)
+
+	// SHA1 signatures verify unless only strong digests are accepted
+	p7s, err = CMSSignDetached(bytes.NewReader(msg), cert, ecKey,
+		&CMSSignOpts{Hash: cr.SHA1,
+			Intermediates: []*x509.Certificate{inter}})
+	c.Assert(err, IsNil)
+	cs, err = ParseCMSSignature(p7s)
+	c.Assert(err, IsNil)
+	c.Assert(cs.VerifyDetached(bytes.NewReader(msg), roots, time.Time{}),
+		IsNil)
+	c.Assert(cs.VerifyDetachedWithDigests(bytes.NewReader(msg), roots,
+		time.Time{}, STRONG_SIG_DIGESTS), Equals, DigestNotAccepted)
+}
+
+func (s *XLSuite) TestCMSSignedAttrs(c *C) {
+	digest := make([]byte, 32)
+	attrs, err := cmsSignedAttrs(digest, time.Now())
+	c.Assert(err, IsNil)
+	inner := attrs[cmsHeaderLen(attrs):]
+	c.Assert(new(CMSSigner).parseAttrs(inner), IsNil)
+
+	attr := func(oid asn1.ObjectIdentifier, values ...[]byte) []byte {
+		enc, err := asn1.Marshal(cmsAttribute{Type: oid,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal,
+				Tag: asn1.TagSet, IsCompound: true,
+				Bytes: bytes.Join(values, nil)}})
+		c.Assert(err, IsNil)
+		return enc
+	}
+	value, err := asn1.Marshal(digest)
+	c.Assert(err, IsNil)
+
+	// other attributes may be repeated
+	other := attr(asn1.ObjectIdentifier{1, 2, 3, 4}, value)
+	c.Assert(new(CMSSigner).parseAttrs(
+		bytes.Join([][]byte{inner, other, other}, nil)), IsNil)
+
+	// but not these, nor may they have more than one value
+	c.Assert(new(CMSSigner).parseAttrs(
+		append(append([]byte{}, inner...), attr(oidAttrMessageDigest, value)...)),
+		Equals, IllFormedCMS)
+	c.Assert(new(CMSSigner).parseAttrs(attr(oidAttrMessageDigest,
+		value, value)), Equals, IllFormedCMS)
+
+	// a nil signature verifies nothing
+	var cs *CMSSignature
+	c.Assert(cs.VerifyDetached(strings.NewReader("data"), x509.NewCertPool(),
+		time.Time{}), Equals, NilData)
+}
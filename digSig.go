@@ -0,0 +1,167 @@
+package crypto
+
+// xlCrypto_go/digSig.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+)
+
+// SignDigest and VerifyDigest sign and check a digest, such as a
+// BuildList's body hash, with whichever kind of key is supplied.
+// RSA keys use PKCS#1 v1.5, so the result is an ordinary SHA1withRSA
+// signature.  Ed25519 keys sign the digest itself as the message, so
+// they sign only 512-bit digests, SHA512 or SHA3-512; a shorter digest
+// would cap the signature's collision resistance below the key's.
+// Verification refuses shorter digests in the same way.  ECDSA keys
+// produce an ASN.1 signature over the digest.
+//
+// SignDigest and VerifyDigest expect an SHA256 digest, or for Ed25519
+// keys an SHA512 digest.  The WithOpts variants take the digest
+// algorithm, one of SIG_DIGESTS, from opts.
+// If opts is an *rsa.PSSOptions, RSA keys sign RSASSA-PSS instead of
+// PKCS#1 v1.5; the PSS scheme cannot be used with other keys.
+
+const (
+	// The name of the RSASSA-PSS signature scheme in serialized documents.
+	SIG_SCHEME_PSS = "PSS"
+
+	// The length of the digests Ed25519 keys will sign.
+	ED25519_DIGEST_LEN = 64
+)
+
+// Return the name of the signature scheme selected by opts:
+// SIG_SCHEME_PSS if opts is an *rsa.PSSOptions, otherwise the empty
+// string, meaning the key's default scheme.
+func SigSchemeName(opts cr.SignerOpts) (name string) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		name = SIG_SCHEME_PSS
+	}
+	return
+}
+
+// Return the options needed to verify a signature made with the scheme
+// named and the digest h.  The PSS salt length is detected.
+func VerifierOpts(scheme string, h cr.Hash) (opts cr.SignerOpts, err error) {
+	switch scheme {
+	case "":
+		opts = h
+	case SIG_SCHEME_PSS:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: h}
+	default:
+		err = UnsupportedSigScheme
+	}
+	return
+}
+
+func SignDigest(key cr.Signer, digest []byte) (sig []byte, err error) {
+	if isNilKey(key) {
+		return nil, NilPrivateKey
+	}
+	return SignDigestWithOpts(key, defaultSigDigest(key.Public()), digest)
+}
+
+func SignDigestWithOpts(key cr.Signer, opts cr.SignerOpts, digest []byte) (
+	sig []byte, err error) {
+
+	if key == nil {
+		err = NilPrivateKey
+	} else if digest == nil {
+		err = NilData
+	} else if err = checkDigestOpts(opts, digest); err == nil {
+		_, isPSS := opts.(*rsa.PSSOptions)
+		switch key.Public().(type) {
+		case *rsa.PublicKey:
+			if !isPSS {
+				opts = opts.HashFunc()
+			}
+			sig, err = key.Sign(rand.Reader, digest, opts)
+		case ed25519.PublicKey:
+			if isPSS {
+				err = UnsupportedSigScheme
+			} else if len(digest) < ED25519_DIGEST_LEN {
+				err = UnsupportedDigest
+			} else {
+				// Ed25519 requires a zero hash function
+				sig, err = key.Sign(rand.Reader, digest, cr.Hash(0))
+			}
+		case *ecdsa.PublicKey:
+			if isPSS {
+				err = UnsupportedSigScheme
+			} else {
+				sig, err = key.Sign(rand.Reader, digest, opts.HashFunc())
+			}
+		default:
+			err = UnsupportedKeyType
+		}
+	}
+	return
+}
+
+// Returns nil if the signature over the SHA256 digest, or for Ed25519
+// keys the SHA512 digest, is good.
+func VerifyDigest(pubKey cr.PublicKey, digest, sig []byte) (err error) {
+	return VerifyDigestWithOpts(pubKey, defaultSigDigest(pubKey), digest, sig)
+}
+
+// Returns nil if the signature over the digest is good.
+func VerifyDigestWithOpts(pubKey cr.PublicKey, opts cr.SignerOpts,
+	digest, sig []byte) (err error) {
+
+	if digest == nil || sig == nil {
+		err = NilData
+	} else if err = checkDigestOpts(opts, digest); err == nil {
+		pssOpts, isPSS := opts.(*rsa.PSSOptions)
+		switch pk := pubKey.(type) {
+		case *rsa.PublicKey:
+			if isPSS {
+				err = rsa.VerifyPSS(pk, opts.HashFunc(), digest, sig, pssOpts)
+			} else {
+				err = rsa.VerifyPKCS1v15(pk, opts.HashFunc(), digest, sig)
+			}
+		case ed25519.PublicKey:
+			if isPSS {
+				err = UnsupportedSigScheme
+			} else if len(digest) < ED25519_DIGEST_LEN {
+				err = UnsupportedDigest
+			} else {
+				err = Ed25519SigVerify(pk, digest, sig)
+			}
+		case *ecdsa.PublicKey:
+			if isPSS {
+				err = UnsupportedSigScheme
+			} else if !ecdsa.VerifyASN1(pk, digest, sig) {
+				err = SigVerificationFailure
+			}
+		case nil:
+			err = NilPublicKey
+		default:
+			err = UnsupportedKeyType
+		}
+	}
+	return
+}
+
+// Return the digest signed by default with the key: SHA512 for Ed25519
+// keys, which sign only 512-bit digests, and SHA256 for others.
+func defaultSigDigest(pubKey cr.PublicKey) cr.Hash {
+	if _, isEd := pubKey.(ed25519.PublicKey); isEd {
+		return cr.SHA512
+	}
+	return cr.SHA256
+}
+
+// The digest algorithm must be supported and the digest the right length.
+func checkDigestOpts(opts cr.SignerOpts, digest []byte) (err error) {
+	if opts == nil {
+		err = UnsupportedDigest
+	} else if err = CheckSigDigest(opts.HashFunc()); err == nil {
+		if len(digest) != opts.HashFunc().Size() {
+			err = WrongDigestLength
+		}
+	}
+	return
+}
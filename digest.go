@@ -0,0 +1,81 @@
+package crypto
+
+// xlCrypto_go/digest.go
+
+import (
+	cr "crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	_ "golang.org/x/crypto/sha3" // registers SHA3_256, SHA3_512
+)
+
+// The digests which may be used in digital signatures.  SHA1 remains
+// only so that signatures made with it, such as those on existing
+// signed BuildLists, can still be checked; it is no longer the default
+// except in SigVerify, which predates the choice.
+var SIG_DIGESTS = []cr.Hash{
+	cr.SHA1, cr.SHA256, cr.SHA512, cr.SHA3_256, cr.SHA3_512,
+}
+
+// The digests which pass security review: SIG_DIGESTS without SHA1.
+// Verifiers which take a list of the digests accepted refuse SHA1
+// signatures if given this one.
+var STRONG_SIG_DIGESTS = []cr.Hash{
+	cr.SHA256, cr.SHA512, cr.SHA3_256, cr.SHA3_512,
+}
+
+// Return nil if h is one of SIG_DIGESTS and is available.
+func CheckSigDigest(h cr.Hash) (err error) {
+	err = UnsupportedDigest
+	for _, d := range SIG_DIGESTS {
+		if h == d {
+			if h.Available() {
+				err = nil
+			}
+			break
+		}
+	}
+	return
+}
+
+// Return nil if h is one of the digests accepted, or DigestNotAccepted.
+func CheckDigestAccepted(h cr.Hash, accepted []cr.Hash) (err error) {
+	err = DigestNotAccepted
+	for _, d := range accepted {
+		if h == d {
+			err = nil
+			break
+		}
+	}
+	return
+}
+
+// Return the name used for the digest in serialized documents, for
+// example "SHA-256" or "SHA3-512".
+func DigestName(h cr.Hash) string {
+	return h.String()
+}
+
+// Return the digest whose name is given, which must be one of
+// SIG_DIGESTS.
+func ParseDigestName(name string) (h cr.Hash, err error) {
+	for _, d := range SIG_DIGESTS {
+		if d.String() == name {
+			return d, nil
+		}
+	}
+	err = UnsupportedDigest
+	return
+}
+
+// Hash the message with the digest specified, which must be one of
+// SIG_DIGESTS.
+func DigestMessage(h cr.Hash, msg []byte) (digest []byte, err error) {
+	if err = CheckSigDigest(h); err == nil {
+		d := h.New()
+		d.Write(msg)
+		digest = d.Sum(nil)
+	}
+	return
+}
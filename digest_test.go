@@ -0,0 +1,100 @@
+package crypto
+
+// xlCrypto_go/digest_test.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+)
+
+func (s *XLSuite) TestDigestNames(c *C) {
+	for _, h := range SIG_DIGESTS {
+		c.Assert(CheckSigDigest(h), IsNil)
+		h2, err := ParseDigestName(DigestName(h))
+		c.Assert(err, IsNil)
+		c.Assert(h2, Equals, h)
+	}
+	c.Assert(DigestName(cr.SHA3_256), Equals, "SHA3-256")
+	c.Assert(CheckSigDigest(cr.MD5), Equals, UnsupportedDigest)
+	_, err := ParseDigestName("MD5")
+	c.Assert(err, Equals, UnsupportedDigest)
+}
+
+func (s *XLSuite) TestSigVerifyWithHash(c *C) {
+	rng := xr.MakeSimpleRNG()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+
+	msg := make([]byte, 256)
+	rng.NextBytes(msg)
+
+	for _, h := range SIG_DIGESTS {
+		sig, err := SignWithHash(key, h, msg)
+		c.Assert(err, IsNil)
+		c.Assert(SigVerifyWithHash(&key.PublicKey, h, msg, sig), IsNil)
+
+		// the digest is bound into the signature
+		for _, other := range SIG_DIGESTS {
+			if other != h {
+				c.Assert(SigVerifyWithHash(&key.PublicKey, other, msg, sig),
+					Not(IsNil))
+			}
+		}
+	}
+	// SHA1 remains the default for SigVerify
+	sig, err := SignWithHash(key, cr.SHA1, msg)
+	c.Assert(err, IsNil)
+	c.Assert(SigVerify(&key.PublicKey, msg, sig), IsNil)
+
+	// but is not among the strong digests
+	c.Assert(CheckDigestAccepted(cr.SHA1, SIG_DIGESTS), IsNil)
+	c.Assert(CheckDigestAccepted(cr.SHA1, STRONG_SIG_DIGESTS),
+		Equals, DigestNotAccepted)
+	for _, h := range STRONG_SIG_DIGESTS {
+		c.Assert(CheckSigDigest(h), IsNil)
+		c.Assert(CheckDigestAccepted(h, STRONG_SIG_DIGESTS), IsNil)
+	}
+
+	_, err = SignWithHash(key, cr.MD5, msg)
+	c.Assert(err, Equals, UnsupportedDigest)
+}
+
+func (s *XLSuite) TestSignDigestWithOpts(c *C) {
+	rng := xr.MakeSimpleRNG()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	keys := []cr.Signer{rsaKey, edKey, ecKey}
+
+	for _, h := range SIG_DIGESTS {
+		msg := make([]byte, 64)
+		rng.NextBytes(msg)
+		digest, err := DigestMessage(h, msg)
+		c.Assert(err, IsNil)
+		c.Assert(len(digest), Equals, h.Size())
+
+		for _, key := range keys {
+			sig, err := SignDigestWithOpts(key, h, digest)
+			_, isEd := key.(ed25519.PrivateKey)
+			if isEd && h.Size() < ED25519_DIGEST_LEN {
+				// Ed25519 signs only 512-bit digests
+				c.Assert(err, Equals, UnsupportedDigest)
+				continue
+			}
+			c.Assert(err, IsNil)
+			c.Assert(VerifyDigestWithOpts(key.Public(), h, digest, sig), IsNil)
+		}
+	}
+	// a digest of the wrong length is rejected
+	_, err = SignDigestWithOpts(rsaKey, cr.SHA256, make([]byte, 20))
+	c.Assert(err, Equals, WrongDigestLength)
+}
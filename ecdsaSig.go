@@ -0,0 +1,64 @@
+package crypto
+
+// xlCrypto_go/ecdsaSig.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+)
+
+// As in SSH (RFC 5656, section 6.2.1), the digest used depends on the
+// size of the curve: SHA-256 for P-256 and SHA-384 for P-384.
+// Signatures are ASN.1 DER-encoded (r, s) pairs.
+
+// Return the hash used with keys on the curve.
+func ecdsaHashForCurve(curve elliptic.Curve) (h cr.Hash, err error) {
+	switch curve {
+	case elliptic.P256():
+		h = cr.SHA256
+	case elliptic.P384():
+		h = cr.SHA384
+	default:
+		err = UnsupportedCurve
+	}
+	return
+}
+
+// Sign a message with an ECDSA private key.
+func ECDSASign(privKey *ecdsa.PrivateKey, msg []byte) (sig []byte, err error) {
+	if privKey == nil {
+		err = NilPrivateKey
+	} else if msg == nil {
+		err = NilData
+	} else {
+		var h cr.Hash
+		h, err = ecdsaHashForCurve(privKey.Curve)
+		if err == nil {
+			d := h.New()
+			d.Write(msg)
+			sig, err = ecdsa.SignASN1(rand.Reader, privKey, d.Sum(nil))
+		}
+	}
+	return
+}
+
+// ECDSA counterpart to SigVerify.  Returns nil if the signature is
+// good.
+func ECDSASigVerify(pubkey *ecdsa.PublicKey, msg []byte, sig []byte) error {
+	if pubkey == nil || msg == nil || sig == nil {
+		return errors.New("IllegalArgument: nil parameter")
+	}
+	h, err := ecdsaHashForCurve(pubkey.Curve)
+	if err != nil {
+		return err
+	}
+	d := h.New()
+	d.Write(msg)
+	if !ecdsa.VerifyASN1(pubkey, d.Sum(nil), sig) {
+		return SigVerificationFailure
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package crypto
+
+// xlCrypto_go/ecdsa_serialization.go
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"golang.org/x/crypto/ssh"
+)
+
+// Only the NIST P-256 and P-384 curves are supported, these being the
+// curves used by the ecdsa-sha2-nistp256 and ecdsa-sha2-nistp384 SSH
+// key types.
+
+// Return an error unless the curve is one we support.
+func checkECDSACurve(curve elliptic.Curve) (err error) {
+	if curve != elliptic.P256() && curve != elliptic.P384() {
+		err = UnsupportedCurve
+	}
+	return
+}
+
+// CONVERSION TO AND FROM WIRE FORMAT ///////////////////////////////
+
+// Serialize an ECDSA public key to wire format, PKIX DER.
+func ECDSAPubKeyToWire(pubKey *ecdsa.PublicKey) (data []byte, err error) {
+	if pubKey == nil {
+		err = NilPublicKey
+	} else if err = checkECDSACurve(pubKey.Curve); err == nil {
+		data, err = x509.MarshalPKIXPublicKey(pubKey)
+	}
+	return
+}
+
+// Deserialize an ECDSA public key from wire format
+func ECDSAPubKeyFromWire(data []byte) (pub *ecdsa.PublicKey, err error) {
+	pk, err := x509.ParsePKIXPublicKey(data)
+	if err == nil {
+		var ok bool
+		if pub, ok = pk.(*ecdsa.PublicKey); !ok {
+			err = NotAnECDSAPublicKey
+		} else if err = checkECDSACurve(pub.Curve); err != nil {
+			pub = nil
+		}
+	}
+	return
+}
+
+// Serialize an ECDSA private key to wire format, SEC1 DER.
+func ECDSAPrivateKeyToWire(privKey *ecdsa.PrivateKey) (
+	data []byte, err error) {
+
+	if privKey == nil {
+		err = NilPrivateKey
+	} else if err = checkECDSACurve(privKey.Curve); err == nil {
+		data, err = x509.MarshalECPrivateKey(privKey)
+	}
+	return
+}
+
+// Deserialize an ECDSA private key from wire format, either SEC1 or
+// unencrypted PKCS#8 DER.
+func ECDSAPrivateKeyFromWire(data []byte) (
+	key *ecdsa.PrivateKey, err error) {
+
+	key, err = ecdsaPrivateKeyFromDER(data)
+	if err == nil {
+		if err = checkECDSACurve(key.Curve); err != nil {
+			key = nil
+		}
+	}
+	return
+}
+
+// CONVERSION TO AND FROM SSH FORMAT ////////////////////////////////
+
+// Serialize an ECDSA public key to the format used in SSH
+// authorized_keys files.  The output is newline-terminated.
+func ECDSAPubKeyToDisk(pubKey *ecdsa.PublicKey) (out []byte, err error) {
+	if pubKey == nil {
+		err = NilPublicKey
+	} else if err = checkECDSACurve(pubKey.Curve); err == nil {
+		var sshKey ssh.PublicKey
+		sshKey, err = ssh.NewPublicKey(pubKey)
+		if err == nil {
+			out = ssh.MarshalAuthorizedKey(sshKey)
+		}
+	}
+	return
+}
+
+// Deserialize an ECDSA public key from the format used in SSH
+// key files
+func ECDSAPubKeyFromDisk(data []byte) (*ecdsa.PublicKey, error) {
+	out, _, _, _, ok := ParseAuthorizedKey(data)
+	if ok {
+		if pub, isEC := out.(*ecdsa.PublicKey); isEC {
+			return pub, nil
+		}
+	}
+	return nil, NotAnECDSAPublicKey
+}
+
+// CONVERSION TO AND FROM PEM FORMAT ////////////////////////////////
+
+// Serialize an ECDSA private key to PEM format, an SEC1
+// "EC PRIVATE KEY" block.
+func ECDSAPrivateKeyToPEM(privKey *ecdsa.PrivateKey) (
+	data []byte, err error) {
+
+	der, err := ECDSAPrivateKeyToWire(privKey)
+	if err == nil {
+		block := pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+		data = pem.EncodeToMemory(&block)
+	}
+	return
+}
+
+// Deserialize an ECDSA private key from PEM format
+func ECDSAPrivateKeyFromPEM(data []byte) (
+	key *ecdsa.PrivateKey, err error) {
+
+	if data == nil {
+		err = NilData
+	} else {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			err = PemEncodeDecodeFailure
+		} else if IsEncryptedPEMBlock(block) {
+			err = PassphraseRequired
+		} else {
+			key, err = ECDSAPrivateKeyFromWire(block.Bytes)
+		}
+	}
+	return
+}
+
+// Serialize an ECDSA public key to PEM format.
+func ECDSAPubKeyToPEM(pubKey *ecdsa.PublicKey) (out []byte, err error) {
+	pubDer, err := ECDSAPubKeyToWire(pubKey)
+	if err == nil {
+		blk := pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: pubDer,
+		}
+		out = pem.EncodeToMemory(&blk)
+	}
+	return
+}
+
+// Deserialize an ECDSA public key from PEM format.
+func ECDSAPubKeyFromPEM(data []byte) (pk *ecdsa.PublicKey, err error) {
+	blk, _ := pem.Decode(data)
+	if blk == nil {
+		err = PemEncodeDecodeFailure
+	} else {
+		pk, err = ECDSAPubKeyFromWire(blk.Bytes)
+	}
+	return
+}
@@ -0,0 +1,24 @@
+package crypto
+
+// xlCrypto_go/ed25519Sig.go
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+// Ed25519 counterpart to SigVerify.  Ed25519 hashes the message
+// itself, so no digest is taken here.  Returns nil if the signature
+// is good.
+func Ed25519SigVerify(pubkey ed25519.PublicKey, msg []byte, sig []byte) error {
+	if pubkey == nil || msg == nil || sig == nil {
+		return errors.New("IllegalArgument: nil parameter")
+	}
+	if len(pubkey) != ed25519.PublicKeySize {
+		return NotAnEd25519PublicKey
+	}
+	if !ed25519.Verify(pubkey, msg, sig) {
+		return SigVerificationFailure
+	}
+	return nil
+}
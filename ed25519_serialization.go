@@ -0,0 +1,149 @@
+package crypto
+
+// xlCrypto_go/ed25519_serialization.go
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"golang.org/x/crypto/ssh"
+)
+
+// CONVERSION TO AND FROM WIRE FORMAT ///////////////////////////////
+
+// Serialize an Ed25519 public key to wire format, PKIX DER.
+func Ed25519PubKeyToWire(pubKey ed25519.PublicKey) ([]byte, error) {
+	if pubKey == nil {
+		return nil, NilPublicKey
+	}
+	return x509.MarshalPKIXPublicKey(pubKey)
+}
+
+// Deserialize an Ed25519 public key from wire format
+func Ed25519PubKeyFromWire(data []byte) (pub ed25519.PublicKey, err error) {
+	pk, err := x509.ParsePKIXPublicKey(data)
+	if err == nil {
+		var ok bool
+		if pub, ok = pk.(ed25519.PublicKey); !ok {
+			err = NotAnEd25519PublicKey
+		}
+	}
+	return
+}
+
+// Serialize an Ed25519 private key to wire format.  There is no PKCS1
+// equivalent for Ed25519, so the key is written as PKCS8 DER.
+func Ed25519PrivateKeyToWire(privKey ed25519.PrivateKey) (
+	data []byte, err error) {
+
+	if privKey == nil {
+		err = NilPrivateKey
+	} else {
+		data, err = x509.MarshalPKCS8PrivateKey(privKey)
+	}
+	return
+}
+
+// Deserialize an Ed25519 private key from wire format
+func Ed25519PrivateKeyFromWire(data []byte) (
+	key ed25519.PrivateKey, err error) {
+
+	k, err := x509.ParsePKCS8PrivateKey(data)
+	if err == nil {
+		var ok bool
+		if key, ok = k.(ed25519.PrivateKey); !ok {
+			err = NotAnEd25519PrivateKey
+		}
+	}
+	return
+}
+
+// CONVERSION TO AND FROM SSH FORMAT ////////////////////////////////
+
+// Serialize an Ed25519 public key to the format used in SSH
+// authorized_keys files.  The output is newline-terminated.
+func Ed25519PubKeyToDisk(pubKey ed25519.PublicKey) (out []byte, err error) {
+	if pubKey == nil {
+		err = NilPublicKey
+	} else {
+		var sshKey ssh.PublicKey
+		sshKey, err = ssh.NewPublicKey(pubKey)
+		if err == nil {
+			out = ssh.MarshalAuthorizedKey(sshKey)
+		}
+	}
+	return
+}
+
+// Deserialize an Ed25519 public key from the format used in SSH
+// key files
+func Ed25519PubKeyFromDisk(data []byte) (ed25519.PublicKey, error) {
+	out, _, _, _, ok := ParseAuthorizedKey(data)
+	if ok {
+		if pub, isEd := out.(ed25519.PublicKey); isEd {
+			return pub, nil
+		}
+	}
+	return nil, NotAnEd25519PublicKey
+}
+
+// CONVERSION TO AND FROM PEM FORMAT ////////////////////////////////
+
+// Serialize an Ed25519 private key to PEM format, a PKCS8
+// "PRIVATE KEY" block.
+func Ed25519PrivateKeyToPEM(privKey ed25519.PrivateKey) (
+	data []byte, err error) {
+
+	der, err := Ed25519PrivateKeyToWire(privKey)
+	if err == nil {
+		block := pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
+		data = pem.EncodeToMemory(&block)
+	}
+	return
+}
+
+// Deserialize an Ed25519 private key from PEM format
+func Ed25519PrivateKeyFromPEM(data []byte) (
+	key ed25519.PrivateKey, err error) {
+
+	if data == nil {
+		err = NilData
+	} else {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			err = PemEncodeDecodeFailure
+		} else if IsEncryptedPEMBlock(block) {
+			err = PassphraseRequired
+		} else {
+			key, err = Ed25519PrivateKeyFromWire(block.Bytes)
+		}
+	}
+	return
+}
+
+// Serialize an Ed25519 public key to PEM format.
+func Ed25519PubKeyToPEM(pubKey ed25519.PublicKey) (out []byte, err error) {
+	pubDer, err := Ed25519PubKeyToWire(pubKey)
+	if err == nil {
+		blk := pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: pubDer,
+		}
+		out = pem.EncodeToMemory(&blk)
+	}
+	return
+}
+
+// Deserialize an Ed25519 public key from PEM format.
+func Ed25519PubKeyFromPEM(data []byte) (pk ed25519.PublicKey, err error) {
+	blk, _ := pem.Decode(data)
+	if blk == nil {
+		err = PemEncodeDecodeFailure
+	} else {
+		pk, err = Ed25519PubKeyFromWire(blk.Bytes)
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package crypto
+
+// xlCrypto_go/ed25519_serialization_test.go
+
+import (
+	cr "crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+)
+
+func (s *XLSuite) makeEd25519Key(c *C) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	return pub, priv
+}
+
+// Prove that pub matches priv by signing a random message.
+func (s *XLSuite) checkEd25519Pair(c *C, rng *xr.PRNG,
+	pub ed25519.PublicKey, priv ed25519.PrivateKey) {
+
+	msg := make([]byte, 128)
+	rng.NextBytes(msg)
+	sig := ed25519.Sign(priv, msg)
+	c.Assert(Ed25519SigVerify(pub, msg, sig), IsNil)
+
+	msg[0] ^= 0x01
+	c.Assert(Ed25519SigVerify(pub, msg, sig), Equals, SigVerificationFailure)
+}
+
+func (s *XLSuite) TestEd25519KeyToFromWire(c *C) {
+	rng := xr.MakeSimpleRNG()
+	pub, priv := s.makeEd25519Key(c)
+
+	wirePub, err := Ed25519PubKeyToWire(pub)
+	c.Assert(err, IsNil)
+	pub2, err := Ed25519PubKeyFromWire(wirePub)
+	c.Assert(err, IsNil)
+	c.Assert(pub2.Equal(pub), Equals, true)
+
+	wirePriv, err := Ed25519PrivateKeyToWire(priv)
+	c.Assert(err, IsNil)
+	priv2, err := Ed25519PrivateKeyFromWire(wirePriv)
+	c.Assert(err, IsNil)
+	s.checkEd25519Pair(c, rng, pub, priv2)
+
+	// an RSA key in wire format is rejected
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	wireRSA, err := RSAPubKeyToWire(&rsaKey.PublicKey)
+	c.Assert(err, IsNil)
+	_, err = Ed25519PubKeyFromWire(wireRSA)
+	c.Assert(err, Equals, NotAnEd25519PublicKey)
+}
+
+func (s *XLSuite) TestEd25519KeyToFromPEM(c *C) {
+	rng := xr.MakeSimpleRNG()
+	pub, priv := s.makeEd25519Key(c)
+
+	pemPub, err := Ed25519PubKeyToPEM(pub)
+	c.Assert(err, IsNil)
+	pub2, err := Ed25519PubKeyFromPEM(pemPub)
+	c.Assert(err, IsNil)
+	c.Assert(pub2.Equal(pub), Equals, true)
+
+	pemPriv, err := Ed25519PrivateKeyToPEM(priv)
+	c.Assert(err, IsNil)
+	priv2, err := Ed25519PrivateKeyFromPEM(pemPriv)
+	c.Assert(err, IsNil)
+	s.checkEd25519Pair(c, rng, pub, priv2)
+
+	_, err = Ed25519PrivateKeyFromPEM([]byte("no PEM here"))
+	c.Assert(err, Equals, PemEncodeDecodeFailure)
+}
+
+func (s *XLSuite) TestEd25519PubKeyToFromSSH(c *C) {
+	pub, _ := s.makeEd25519Key(c)
+
+	sshAuthKey, err := Ed25519PubKeyToDisk(pub)
+	c.Assert(err, IsNil)
+	c.Assert(string(sshAuthKey[:12]), Equals, "ssh-ed25519 ")
+
+	pub2, err := Ed25519PubKeyFromDisk(sshAuthKey)
+	c.Assert(err, IsNil)
+	c.Assert(pub2.Equal(pub), Equals, true)
+
+	// an Ed25519 key is not an RSA key
+	_, err = RSAPubKeyFromDisk(sshAuthKey)
+	c.Assert(err, Equals, NotAnRSAPublicKey)
+
+	// ParseAuthorizedKey handles options and comments
+	line := append([]byte("# comment line\nno-pty,command=\"ls -l\" "),
+		sshAuthKey[:len(sshAuthKey)-1]...)
+	line = append(line, []byte(" jdd@example.com\n")...)
+	out, comment, options, rest, ok := ParseAuthorizedKey(line)
+	c.Assert(ok, Equals, true)
+	c.Assert(out.(ed25519.PublicKey).Equal(pub), Equals, true)
+	c.Assert(comment, Equals, "jdd@example.com")
+	c.Assert(options, DeepEquals, []string{"no-pty", "command=\"ls -l\""})
+	c.Assert(len(rest), Equals, 0)
+
+	// generic functions
+	disk, err := PubKeyToDisk(pub)
+	c.Assert(err, IsNil)
+	c.Assert(disk, DeepEquals, sshAuthKey)
+	pk, err := PubKeyFromDisk(disk)
+	c.Assert(err, IsNil)
+	c.Assert(pk.(ed25519.PublicKey).Equal(pub), Equals, true)
+
+	wire, err := PubKeyToWire(pub)
+	c.Assert(err, IsNil)
+	pk, err = PubKeyFromWire(wire)
+	c.Assert(err, IsNil)
+	c.Assert(pk.(ed25519.PublicKey).Equal(pub), Equals, true)
+}
+
+func (s *XLSuite) TestSignVerifyDigest(c *C) {
+	rng := xr.MakeSimpleRNG()
+	digest := make([]byte, 20)
+	rng.NextBytes(digest)
+
+	pub, priv := s.makeEd25519Key(c)
+	_, err := SignDigestWithOpts(priv, cr.SHA1, digest)
+	c.Assert(err, Equals, UnsupportedDigest)
+	// nor are signatures over shorter digests accepted
+	sig := ed25519.Sign(priv, digest)
+	c.Assert(VerifyDigestWithOpts(pub, cr.SHA1, digest, sig),
+		Equals, UnsupportedDigest)
+	digest256 := make([]byte, 32)
+	rng.NextBytes(digest256)
+	sig = ed25519.Sign(priv, digest256)
+	c.Assert(VerifyDigestWithOpts(pub, cr.SHA256, digest256, sig),
+		Equals, UnsupportedDigest)
+
+	// by default Ed25519 keys sign SHA512 digests
+	_, err = SignDigest(priv, digest)
+	c.Assert(err, Equals, WrongDigestLength)
+	digest512 := make([]byte, ED25519_DIGEST_LEN)
+	rng.NextBytes(digest512)
+	sig, err = SignDigest(priv, digest512)
+	c.Assert(err, IsNil)
+	c.Assert(VerifyDigest(pub, digest512, sig), IsNil)
+	c.Assert(VerifyDigestWithOpts(pub, cr.SHA512, digest512, sig), IsNil)
+
+	// and other keys SHA256 digests
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	sig, err = SignDigest(rsaKey, digest256)
+	c.Assert(err, IsNil)
+	c.Assert(VerifyDigest(&rsaKey.PublicKey, digest256, sig), IsNil)
+	c.Assert(VerifyDigestWithOpts(&rsaKey.PublicKey, cr.SHA256, digest256,
+		sig), IsNil)
+	c.Assert(VerifyDigest(pub, digest256, sig), Not(IsNil))
+	_, err = SignDigest(rsaKey, digest)
+	c.Assert(err, Equals, WrongDigestLength)
+	var nilKey *rsa.PrivateKey
+	_, err = SignDigest(nilKey, digest256)
+	c.Assert(err, Equals, NilPrivateKey)
+}
@@ -0,0 +1,89 @@
+package crypto
+
+// xlCrypto_go/encryptedPEM_test.go
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+	"strings"
+)
+
+// cheap parameters to keep the tests quick
+var testScryptParams = ScryptParams{N: 1 << 10, R: 8, P: 1}
+
+func (s *XLSuite) TestEncryptedPEM(c *C) {
+	rng := xr.MakeSimpleRNG()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	passphrase := []byte(rng.NextFileName(16))
+
+	_, err = RSAPrivateKeyToEncryptedPEMWithParams(nil, passphrase,
+		testScryptParams)
+	c.Assert(err, Equals, NilPrivateKey)
+	_, err = RSAPrivateKeyToEncryptedPEMWithParams(key, passphrase,
+		ScryptParams{N: 1000, R: 8, P: 1})
+	c.Assert(err, Equals, BadKDFParams)
+
+	data, err := RSAPrivateKeyToEncryptedPEMWithParams(key, passphrase,
+		testScryptParams)
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(string(data),
+		"-----BEGIN "+ENCRYPTED_PEM_TYPE+"-----"), Equals, true)
+	block, _ := pem.Decode(data)
+	c.Assert(IsEncryptedPEMBlock(block), Equals, true)
+	c.Assert(block.Headers["Key-Type"], Equals, "RSA PRIVATE KEY")
+	c.Assert(block.Headers["KDF-N"], Equals, "1024")
+
+	key2, err := RSAPrivateKeyFromEncryptedPEM(data, passphrase)
+	c.Assert(err, IsNil)
+	c.Assert(key2.Equal(key), Equals, true)
+
+	// the wrong passphrase fails
+	_, err = RSAPrivateKeyFromEncryptedPEM(data, []byte("not it"))
+	c.Assert(err, Equals, BadPassphrase)
+
+	// and so does any change to the headers
+	block.Headers["Key-Type"] = "EC PRIVATE KEY"
+	_, _, err = DecryptPEMBlock(block, passphrase)
+	c.Assert(err, Equals, BadPassphrase)
+	block.Headers["Key-Type"] = "RSA PRIVATE KEY"
+	block.Headers["KDF-N"] = "1048576000"
+	_, _, err = DecryptPEMBlock(block, passphrase)
+	c.Assert(err, Equals, BadKDFParams)
+}
+
+func (s *XLSuite) TestLegacyEncryptedPEM(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	passphrase := []byte("legacy passphrase")
+
+	// an old OpenSSL-style Proc-Type/DEK-Info block
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY",
+		x509.MarshalPKCS1PrivateKey(key), passphrase, x509.PEMCipherAES256)
+	c.Assert(err, IsNil)
+	c.Assert(IsEncryptedPEMBlock(block), Equals, true)
+	legacy := pem.EncodeToMemory(block)
+
+	key2, err := RSAPrivateKeyFromEncryptedPEM(legacy, passphrase)
+	c.Assert(err, IsNil)
+	c.Assert(key2.Equal(key), Equals, true)
+
+	// migrate it
+	data, err := RSAPrivateKeyToEncryptedPEMWithParams(key2, passphrase,
+		testScryptParams)
+	c.Assert(err, IsNil)
+	key3, err := RSAPrivateKeyFromEncryptedPEM(data, passphrase)
+	c.Assert(err, IsNil)
+	c.Assert(key3.Equal(key), Equals, true)
+
+	// an unencrypted key is read as it is
+	plain, err := RSAPrivateKeyToPEM(key)
+	c.Assert(err, IsNil)
+	key4, err := RSAPrivateKeyFromEncryptedPEM(plain, nil)
+	c.Assert(err, IsNil)
+	c.Assert(key4.Equal(key), Equals, true)
+}
@@ -9,17 +9,83 @@ import (
 var (
 	//EmptyHash               = e.New("empty hash slice parameter")
 	//EmptyPath               = e.New("empty path parameter")
+	AEADAuthFailure         = e.New("message authentication failed")
+	AmbiguousKeyData        = e.New("input holds more than one key")
+	BadAESKeySize           = e.New("AES key must be 16, 24, or 32 bytes")
+	BadKDFParams            = e.New("bad or excessive KDF parameters")
+	BadKeyComment           = e.New("comment contains a line break or NUL")
+	BadKeyOption            = e.New("unknown or malformed authorized_keys option")
+	BadNonceSize            = e.New("nonce has wrong length")
+	BadPKCS7PaddingLength   = e.New("PKCS7 padding length out of range")
+	BadPassphrase           = e.New("wrong passphrase or corrupt key")
+	CertExpired             = e.New("certificate has expired")
+	CertNotForSigning       = e.New("certificate not valid for digital signatures")
+	CertNotYetValid         = e.New("certificate is not yet valid")
+	CertWrongPrincipal      = e.New("principal not listed in certificate")
+	CertWrongType           = e.New("certificate is of the wrong type")
+	CiphertextTooShort      = e.New("ciphertext too short")
+	DigestNotAccepted       = e.New("digest algorithm not accepted")
+	DuplicateKeyOption      = e.New("authorized_keys option given more than once")
 	EmptyTitle              = e.New("empty title parameter")
 	ExhaustedStringArray    = e.New("exhausted string array")
+	IllFormedAllowedSigner  = e.New("ill-formed allowed_signers line")
+	IllFormedAuthorizedKey  = e.New("ill-formed authorized_keys line")
+	IllFormedCMS            = e.New("ill-formed CMS SignedData")
+	IllFormedJWK            = e.New("ill-formed JSON Web Key")
+	IllFormedKnownHost      = e.New("ill-formed known_hosts line")
+	IllFormedOpenSSHKey     = e.New("ill-formed OpenSSH private key")
+	IllFormedSSHSig         = e.New("ill-formed SSH signature")
 	ImpossibleBlockSize     = e.New("impossible block size")
+	IncorrectPadding        = e.New("incorrect block padding")
 	IncorrectPKCS7Padding   = e.New("incorrectly padded data")
+	KeyCertMismatch         = e.New("certificate does not certify the key")
+	KeyExpired              = e.New("key has expired")
+	KeyNotAuthorized        = e.New("key not in authorized_keys")
+	MissingSignedAttr       = e.New("required signed attribute missing")
+	MissingSignerCert       = e.New("signer certificate not included")
 	MissingContentStart     = e.New("missing CONTENT START line")
+	MessageDigestMismatch   = e.New("message digest attribute does not match content")
+	MismatchedPKCS7Padding  = e.New("PKCS7 padding bytes differ from padding length")
+	NilCertificate          = e.New("nil certificate parameter")
+	NilCertPool             = e.New("nil certificate pool parameter")
 	NilData                 = e.New("nil data argument")
+	NilPaddingScheme        = e.New("nil padding scheme")
 	NilPrivateKey           = e.New("nil private key parameter")
 	NilPublicKey            = e.New("nil public key parameter")
+	NoCertificates          = e.New("no certificates found")
+	NotACACertificate       = e.New("not a CA certificate")
+	NotDetachedCMS          = e.New("CMS signature is not detached")
+	NotACertificate         = e.New("not an OpenSSH certificate")
+	NotAnAllowedSigner      = e.New("no allowed signer trusts the key for this identity")
+	NotAnECDSAPrivateKey    = e.New("Not an ECDSA private key")
+	NotAnECDSAPublicKey     = e.New("Not an ECDSA public key")
+	NotAnEd25519PrivateKey  = e.New("Not an Ed25519 private key")
+	NotAnEd25519PublicKey   = e.New("Not an Ed25519 public key")
 	NotAnRSAPrivateKey      = e.New("Not an RSA private key")
 	NotImplemented          = e.New("not implemented")
 	NotAnRSAPublicKey       = e.New("Not an RSA public key")
+	NotAPublicKey           = e.New("not a recognized public key")
+	PassphraseRequired      = e.New("key is encrypted; passphrase required")
 	PemEncodeDecodeFailure  = e.New("Pem encode/decode failure")
+	SigVerificationFailure  = e.New("signature verification failed")
+	SourceNotPermitted      = e.New("key not permitted from this source")
+	UnalignedCiphertext     = e.New("ciphertext not a whole number of blocks")
+	UnalignedPaddedData     = e.New("padded data not a whole number of blocks")
+	UnexpectedCertificate   = e.New("expected a key, found an SSH certificate")
+	UnexpectedPrivateKey    = e.New("expected a public key, found a private key")
+	UnexpectedPublicKey     = e.New("expected a private key, found a public key")
+	UnknownKeyFormat        = e.New("unrecognized key format")
+	UnknownSSHSigVersion    = e.New("unsupported SSH signature version")
+	UnsupportedAEADVersion  = e.New("unsupported sealed message version")
+	UnsupportedCertOption   = e.New("unsupported critical option in certificate")
+	UnsupportedCurve        = e.New("unsupported elliptic curve")
+	UnsupportedDigest       = e.New("unsupported digest algorithm")
+	UnsupportedSigScheme    = e.New("unsupported signature scheme")
+	UnsupportedKeyType      = e.New("unsupported key type")
+	UnsupportedPBEScheme    = e.New("unsupported password-based encryption scheme")
+	UnsupportedPEMType      = e.New("no key among the PEM blocks")
+	UntrustedCA             = e.New("certificate not signed by a trusted CA")
+	WrongSigNamespace       = e.New("signature namespace missing or wrong")
+	WrongDigestLength       = e.New("digest has wrong length for algorithm")
 	X509ParseOrMarshalError = e.New("X509 parse/marshal error")
 )
@@ -0,0 +1,176 @@
+package crypto
+
+// xlCrypto_go/fingerprint.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Public key fingerprints and randomart pictures as ssh-keygen -l and
+// ssh-keygen -lv display them.  Fingerprints are digests of the key in
+// SSH wire format.
+
+const (
+	// the dimensions of the randomart field
+	RANDOMART_BASE   = 8
+	RANDOMART_HEIGHT = RANDOMART_BASE + 1
+	RANDOMART_WIDTH  = RANDOMART_BASE*2 + 1
+)
+
+// The characters ssh-keygen draws, from least to most visited, followed
+// by those marking where the walk starts and ends.
+const randomArtChars = " .o+=*BOX@%&#/^SE"
+
+// Return the SHA256 fingerprint of the key in the form ssh-keygen
+// shows by default, "SHA256:" followed by the unpadded base64 digest.
+func SSHFingerprintSHA256(key cr.PublicKey) (fp string, err error) {
+	blob, err := sshKeyBlob(key)
+	if err == nil {
+		digest := sha256.Sum256(blob)
+		fp = "SHA256:" + base64.RawStdEncoding.EncodeToString(digest[:])
+	}
+	return
+}
+
+// Return the legacy MD5 fingerprint of the key as colon-separated hex,
+// prefixed with "MD5:" as ssh-keygen -E md5 shows it.
+func SSHFingerprintMD5(key cr.PublicKey) (fp string, err error) {
+	blob, err := sshKeyBlob(key)
+	if err == nil {
+		digest := md5.Sum(blob)
+		hexDigest := hex.EncodeToString(digest[:])
+		pairs := make([]string, len(digest))
+		for i := range pairs {
+			pairs[i] = hexDigest[2*i : 2*i+2]
+		}
+		fp = "MD5:" + strings.Join(pairs, ":")
+	}
+	return
+}
+
+// Return the randomart picture of the key which ssh-keygen -lv draws
+// beneath the fingerprint.  The digest, cr.SHA256 or cr.MD5, selects
+// which fingerprint is pictured.  The picture is RANDOMART_HEIGHT + 2
+// lines, without a final newline.
+func SSHRandomArt(key cr.PublicKey, digest cr.Hash) (art string, err error) {
+	var (
+		raw  []byte
+		name string
+		blob []byte
+	)
+	blob, err = sshKeyBlob(key)
+	if err == nil {
+		switch digest {
+		case cr.SHA256:
+			sum := sha256.Sum256(blob)
+			raw, name = sum[:], "SHA256"
+		case cr.MD5:
+			sum := md5.Sum(blob)
+			raw, name = sum[:], "MD5"
+		default:
+			err = UnsupportedDigest
+		}
+	}
+	if err == nil {
+		title := "[" + sshKeyTypeName(key) + " " +
+			strconv.Itoa(sshKeyBits(key)) + "]"
+		if len(title) > RANDOMART_WIDTH {
+			title = "[" + sshKeyTypeName(key) + "]"
+		}
+		art = drawRandomArt(raw, title, "["+name+"]")
+	}
+	return
+}
+
+// Draw the picture as OpenSSH does, following the "drunken bishop"
+// walk described in "The drunken bishop: An analysis of the OpenSSH
+// fingerprint visualization algorithm" by Loss, Limmer, and von
+// Gernler.  Each byte of the digest, least significant bits first,
+// gives four moves, each diagonal; the bishop cannot leave the field.
+func drawRandomArt(digest []byte, title, footer string) string {
+	var field [RANDOMART_WIDTH][RANDOMART_HEIGHT]int
+	top := len(randomArtChars) - 1
+	x, y := RANDOMART_WIDTH/2, RANDOMART_HEIGHT/2
+	for _, b := range digest {
+		for i := 0; i < 4; i++ {
+			if b&1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&2 != 0 {
+				y++
+			} else {
+				y--
+			}
+			x = max(0, min(x, RANDOMART_WIDTH-1))
+			y = max(0, min(y, RANDOMART_HEIGHT-1))
+			if field[x][y] < top-2 {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+	field[RANDOMART_WIDTH/2][RANDOMART_HEIGHT/2] = top - 1
+	field[x][y] = top
+
+	var buf bytes.Buffer
+	randomArtBorder(&buf, title)
+	buf.WriteByte('\n')
+	for y = 0; y < RANDOMART_HEIGHT; y++ {
+		buf.WriteByte('|')
+		for x = 0; x < RANDOMART_WIDTH; x++ {
+			buf.WriteByte(randomArtChars[field[x][y]])
+		}
+		buf.WriteString("|\n")
+	}
+	randomArtBorder(&buf, footer)
+	return buf.String()
+}
+
+// Write a border line with the label centred in it, as OpenSSH centres
+// it: any odd dash goes to the right.
+func randomArtBorder(buf *bytes.Buffer, label string) {
+	left := (RANDOMART_WIDTH - len(label)) / 2
+	buf.WriteByte('+')
+	buf.WriteString(strings.Repeat("-", left))
+	buf.WriteString(label)
+	buf.WriteString(strings.Repeat("-", RANDOMART_WIDTH-left-len(label)))
+	buf.WriteByte('+')
+}
+
+// Return the key type as ssh-keygen names it in randomart titles.
+func sshKeyTypeName(key cr.PublicKey) string {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return "RSA"
+	case ed25519.PublicKey:
+		return "ED25519"
+	case *ecdsa.PublicKey:
+		return "ECDSA"
+	}
+	return "UNKNOWN"
+}
+
+// Return the size of the key in bits as ssh-keygen reports it.
+func sshKeyBits(key cr.PublicKey) int {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case ed25519.PublicKey:
+		return 256
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	}
+	return 0
+}
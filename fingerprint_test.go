@@ -0,0 +1,113 @@
+package crypto
+
+// xlCrypto_go/fingerprint_test.go
+
+import (
+	cr "crypto"
+	. "gopkg.in/check.v1"
+	"strings"
+)
+
+const SSH_KEYGEN_RSA_PUB = `ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC5h+WWrCNwfgs9wNchEHhvsTzhIahJFQEm9lSPiQvUaXN1uciJWGUF4wwCmizmmGecVT/+5c5qLnknaiug07kmc9LO+E6kxRx3JgfeGNEK3lOZJQ9QkXp3czUBxidIQOr6NbtLMZ9ykldUV5ZRCJ0qjwfFgh0dLaRonQ+7NAtzq+NZmpO8vrOv5hQ4jRC+mMgu8xsDM4gABQgZr04Rh+bfgKxLOqzF1I9qd7su7LWV2EpG/tLxqqlhALiSIwW71dB0T6XOyBNnX13ewGz7DjFDpHG/ks8fuL/C0n+JmdCmfP7HqjO/iXRUjmQZJeXVH+RlZP0WpILEjVNb/OxC4zXJ carol@example.com`
+
+// ssh-keygen -lv output for SSH_KEYGEN_RSA_PUB, SSH_KEYGEN_ED25519_PUB
+// and SSH_KEYGEN_ECDSA_PUB.
+const (
+	RSA_SHA256_ART = `+---[RSA 2048]----+
+|          o ..ooo|
+|         . + o +o|
+|          o . B  |
+|       o + . . o |
+|      o S +   .  |
+|       o @ o +   |
+|      ..O = + .  |
+|     oo*.o.o o   |
+|     oOBEoooo .  |
++----[SHA256]-----+`
+	ED25519_SHA256_ART = `+--[ED25519 256]--+
+|                 |
+|                 |
+| . .    o .      |
+|. . .o . =       |
+| . .o + S o      |
+|.  o+O = o =     |
+|oo o@.B * E      |
+|oo.+o* O + .     |
+|  +=*+= .        |
++----[SHA256]-----+`
+	ED25519_MD5_ART = `+--[ED25519 256]--+
+|                 |
+|                .|
+|             ..+.|
+|            o.*o+|
+|        S o..oo*o|
+|         + B oo..|
+|          B o . E|
+|           +     |
+|          .      |
++------[MD5]------+`
+	ECDSA_SHA256_ART = `+---[ECDSA 256]---+
+|  ..oo           |
+| + ..            |
+|o.o ..           |
+|E+. o. .         |
+|oo.=.o  S        |
+|+.* *..  .       |
+|**.= =. .        |
+|%=+.+  o         |
+|#Xo .o.          |
++----[SHA256]-----+`
+)
+
+func (s *XLSuite) TestSSHFingerprints(c *C) {
+	tests := []struct {
+		disk, sha256, md5 string
+	}{
+		{SSH_KEYGEN_RSA_PUB,
+			"SHA256:mJ+uH7GOvuSth+PV5N2OlBVp1HTKE6Bhr6Qb6bW29qo",
+			"MD5:19:5a:9a:23:a3:56:aa:8b:fd:03:56:cf:7d:07:a9:10"},
+		{SSH_KEYGEN_ED25519_PUB,
+			"SHA256:tHHqyho7RmOEuJr9Cp/F65EZh21q3JMnOX0Ts5wa6tU",
+			"MD5:7f:18:9e:a5:b4:5b:c5:5e:49:b2:d8:d7:70:69:6e:f9"},
+		{SSH_KEYGEN_ECDSA_PUB,
+			"SHA256:vBoQyFSRO6qGrwb7hukMOqqm9ROcY6cDC3rBsWhhRpo",
+			"MD5:b4:78:f2:ef:b1:7d:9e:53:e0:54:1d:37:82:bb:21:97"},
+	}
+	for _, t := range tests {
+		key, err := PubKeyFromDisk([]byte(t.disk))
+		c.Assert(err, IsNil)
+		fp, err := SSHFingerprintSHA256(key)
+		c.Assert(err, IsNil)
+		c.Assert(fp, Equals, t.sha256)
+		fp, err = SSHFingerprintMD5(key)
+		c.Assert(err, IsNil)
+		c.Assert(fp, Equals, t.md5)
+	}
+	_, err := SSHFingerprintSHA256(nil)
+	c.Assert(err, Equals, NilPublicKey)
+}
+
+func (s *XLSuite) TestSSHRandomArt(c *C) {
+	tests := []struct {
+		disk   string
+		digest cr.Hash
+		art    string
+	}{
+		{SSH_KEYGEN_RSA_PUB, cr.SHA256, RSA_SHA256_ART},
+		{SSH_KEYGEN_ED25519_PUB, cr.SHA256, ED25519_SHA256_ART},
+		{SSH_KEYGEN_ED25519_PUB, cr.MD5, ED25519_MD5_ART},
+		{SSH_KEYGEN_ECDSA_PUB, cr.SHA256, ECDSA_SHA256_ART},
+	}
+	for _, t := range tests {
+		key, err := PubKeyFromDisk([]byte(t.disk))
+		c.Assert(err, IsNil)
+		art, err := SSHRandomArt(key, t.digest)
+		c.Assert(err, IsNil)
+		c.Assert(art, Equals, t.art)
+		c.Assert(len(strings.Split(art, "\n")), Equals, RANDOMART_HEIGHT+2)
+	}
+	key, err := PubKeyFromDisk([]byte(SSH_KEYGEN_ED25519_PUB))
+	c.Assert(err, IsNil)
+	_, err = SSHRandomArt(key, cr.SHA1)
+	c.Assert(err, Equals, UnsupportedDigest)
+}
@@ -0,0 +1,532 @@
+package crypto
+
+// xlCrypto_go/jwk.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// JSON Web Keys (RFC 7517) and JWK Sets.  RSA and ECDSA keys are
+// represented as RFC 7518 specifies, Ed25519 keys as RFC 8037 does.
+// All binary values are base64url-encoded without padding; EC
+// coordinates and private keys are padded to the size of the curve.
+// Thumbprints are those of RFC 7638.
+
+const (
+	JWK_KTY_RSA = "RSA"
+	JWK_KTY_EC  = "EC"
+	JWK_KTY_OKP = "OKP"
+
+	JWK_CRV_P256    = "P-256"
+	JWK_CRV_P384    = "P-384"
+	JWK_CRV_ED25519 = "Ed25519"
+)
+
+type JWK struct {
+	Kty    string   `json:"kty"`
+	Use    string   `json:"use,omitempty"`
+	KeyOps []string `json:"key_ops,omitempty"`
+	Alg    string   `json:"alg,omitempty"`
+	Kid    string   `json:"kid,omitempty"`
+
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// private members
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+}
+
+// Build the JWK for a public key.
+func NewJWK(pubKey cr.PublicKey) (j *JWK, err error) {
+	switch pk := pubKey.(type) {
+	case *rsa.PublicKey:
+		if pk == nil {
+			err = NilPublicKey
+		} else {
+			j = &JWK{
+				Kty: JWK_KTY_RSA,
+				N:   jwkEncodeInt(pk.N, 0),
+				E:   jwkEncodeInt(big.NewInt(int64(pk.E)), 0),
+			}
+		}
+	case *ecdsa.PublicKey:
+		var crv string
+		if pk == nil {
+			err = NilPublicKey
+		} else if crv, err = jwkCurveName(pk.Curve); err == nil {
+			size := jwkCurveSize(pk.Curve)
+			j = &JWK{
+				Kty: JWK_KTY_EC,
+				Crv: crv,
+				X:   jwkEncodeInt(pk.X, size),
+				Y:   jwkEncodeInt(pk.Y, size),
+			}
+		}
+	case ed25519.PublicKey:
+		if len(pk) != ed25519.PublicKeySize {
+			err = NotAnEd25519PublicKey
+		} else {
+			j = &JWK{
+				Kty: JWK_KTY_OKP,
+				Crv: JWK_CRV_ED25519,
+				X:   base64.RawURLEncoding.EncodeToString(pk),
+			}
+		}
+	case nil:
+		err = NilPublicKey
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Build the JWK for a private key, including the public members.
+func NewPrivateJWK(key cr.PrivateKey) (j *JWK, err error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k == nil || len(k.Primes) != 2 {
+			err = NotAnRSAPrivateKey
+		} else if j, err = NewJWK(&k.PublicKey); err == nil {
+			// precompute on a copy, leaving the caller's key alone
+			kc := *k
+			kc.Precompute()
+			j.D = jwkEncodeInt(kc.D, 0)
+			j.P = jwkEncodeInt(kc.Primes[0], 0)
+			j.Q = jwkEncodeInt(kc.Primes[1], 0)
+			j.DP = jwkEncodeInt(kc.Precomputed.Dp, 0)
+			j.DQ = jwkEncodeInt(kc.Precomputed.Dq, 0)
+			j.QI = jwkEncodeInt(kc.Precomputed.Qinv, 0)
+		}
+	case *ecdsa.PrivateKey:
+		if k == nil {
+			err = NilPrivateKey
+		} else if j, err = NewJWK(&k.PublicKey); err == nil {
+			j.D = jwkEncodeInt(k.D, jwkCurveSize(k.Curve))
+		}
+	case ed25519.PrivateKey:
+		if len(k) != ed25519.PrivateKeySize {
+			err = NotAnEd25519PrivateKey
+		} else if j, err = NewJWK(k.Public()); err == nil {
+			j.D = base64.RawURLEncoding.EncodeToString(k.Seed())
+		}
+	case nil:
+		err = NilPrivateKey
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Parse a single JWK.
+func ParseJWK(data []byte) (j *JWK, err error) {
+	j = &JWK{}
+	if err = json.Unmarshal(data, j); err != nil || j.Kty == "" {
+		j, err = nil, IllFormedJWK
+	}
+	return
+}
+
+// Whether the JWK holds a private key.
+func (j *JWK) IsPrivate() bool {
+	return j.D != ""
+}
+
+// Return a copy of the JWK without its private members, suitable for
+// publishing.
+func (j *JWK) Public() *JWK {
+	pub := *j
+	pub.D, pub.P, pub.Q, pub.DP, pub.DQ, pub.QI = "", "", "", "", "", ""
+	return &pub
+}
+
+// Serialize the JWK as compact JSON.
+func (j *JWK) Marshal() ([]byte, error) {
+	return json.Marshal(j)
+}
+
+// Return the public key the JWK describes, checking that it is well
+// formed: for example, that an EC point lies on its curve.
+func (j *JWK) PublicKey() (pubKey cr.PublicKey, err error) {
+	switch j.Kty {
+	case JWK_KTY_RSA:
+		var n, e *big.Int
+		if n, err = jwkDecodeInt(j.N, 0); err == nil {
+			e, err = jwkDecodeInt(j.E, 0)
+		}
+		if err == nil {
+			if n.Sign() <= 0 || e.BitLen() > 31 || e.Cmp(big.NewInt(1)) <= 0 {
+				err = IllFormedJWK
+			} else {
+				pubKey = &rsa.PublicKey{N: n, E: int(e.Int64())}
+			}
+		}
+	case JWK_KTY_EC:
+		var (
+			curve elliptic.Curve
+			x, y  *big.Int
+			size  int
+		)
+		if curve, err = jwkCurve(j.Crv); err == nil {
+			size = jwkCurveSize(curve)
+			if x, err = jwkDecodeInt(j.X, size); err == nil {
+				y, err = jwkDecodeInt(j.Y, size)
+			}
+		}
+		if err == nil {
+			// elliptic.Unmarshal rejects points not on the curve
+			point := make([]byte, 1+2*size)
+			point[0] = 4
+			x.FillBytes(point[1 : 1+size])
+			y.FillBytes(point[1+size:])
+			if px, _ := elliptic.Unmarshal(curve, point); px == nil {
+				err = IllFormedJWK
+			} else {
+				pubKey = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+			}
+		}
+	case JWK_KTY_OKP:
+		var raw []byte
+		if j.Crv != JWK_CRV_ED25519 {
+			err = UnsupportedCurve
+		} else if raw, err = base64.RawURLEncoding.DecodeString(j.X); err != nil ||
+			len(raw) != ed25519.PublicKeySize {
+
+			err = IllFormedJWK
+		} else {
+			pubKey = ed25519.PublicKey(raw)
+		}
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Return the private key the JWK holds, checking that it is well formed
+// and agrees with the public members.  RSA keys must include their
+// primes.
+func (j *JWK) PrivateKey() (key cr.PrivateKey, err error) {
+	var pubKey cr.PublicKey
+	if !j.IsPrivate() {
+		return nil, IllFormedJWK
+	}
+	if pubKey, err = j.PublicKey(); err != nil {
+		return
+	}
+	switch pk := pubKey.(type) {
+	case *rsa.PublicKey:
+		var d, p, q *big.Int
+		if d, err = jwkDecodeInt(j.D, 0); err == nil {
+			if p, err = jwkDecodeInt(j.P, 0); err == nil {
+				q, err = jwkDecodeInt(j.Q, 0)
+			}
+		}
+		if err == nil {
+			k := &rsa.PrivateKey{PublicKey: *pk, D: d, Primes: []*big.Int{p, q}}
+			if err = k.Validate(); err != nil {
+				err = IllFormedJWK
+			} else {
+				k.Precompute()
+				// the CRT members are optional, but must agree if given
+				if (j.DP != "" && j.DP != jwkEncodeInt(k.Precomputed.Dp, 0)) ||
+					(j.DQ != "" && j.DQ != jwkEncodeInt(k.Precomputed.Dq, 0)) ||
+					(j.QI != "" && j.QI != jwkEncodeInt(k.Precomputed.Qinv, 0)) {
+
+					err = IllFormedJWK
+				} else {
+					key = k
+				}
+			}
+		}
+	case *ecdsa.PublicKey:
+		var d *big.Int
+		if d, err = jwkDecodeInt(j.D, jwkCurveSize(pk.Curve)); err == nil {
+			x, y := pk.Curve.ScalarBaseMult(d.Bytes())
+			if d.Sign() <= 0 || d.Cmp(pk.Curve.Params().N) >= 0 ||
+				x.Cmp(pk.X) != 0 || y.Cmp(pk.Y) != 0 {
+
+				err = IllFormedJWK
+			} else {
+				key = &ecdsa.PrivateKey{PublicKey: *pk, D: d}
+			}
+		}
+	case ed25519.PublicKey:
+		var seed []byte
+		if seed, err = base64.RawURLEncoding.DecodeString(j.D); err != nil ||
+			len(seed) != ed25519.SeedSize {
+
+			err = IllFormedJWK
+		} else {
+			k := ed25519.NewKeyFromSeed(seed)
+			if !bytes.Equal(k.Public().(ed25519.PublicKey), pk) {
+				err = IllFormedJWK
+			} else {
+				key = k
+			}
+		}
+	}
+	return
+}
+
+// Return the RFC 7638 thumbprint of the key, the digest of the JSON
+// object made of its required public members in lexicographic order.
+func (j *JWK) Thumbprint(h cr.Hash) (digest []byte, err error) {
+	var (
+		pubKey cr.PublicKey
+		canon  *JWK
+		data   []byte
+	)
+	if !h.Available() {
+		err = UnsupportedDigest
+	} else if pubKey, err = j.PublicKey(); err == nil {
+		canon, err = NewJWK(pubKey)
+	}
+	if err == nil {
+		// json.Marshal writes map keys in sorted order without spaces
+		members := map[string]string{"kty": canon.Kty}
+		switch canon.Kty {
+		case JWK_KTY_RSA:
+			members["e"], members["n"] = canon.E, canon.N
+		case JWK_KTY_EC:
+			members["crv"], members["x"], members["y"] =
+				canon.Crv, canon.X, canon.Y
+		case JWK_KTY_OKP:
+			members["crv"], members["x"] = canon.Crv, canon.X
+		}
+		if data, err = json.Marshal(members); err == nil {
+			d := h.New()
+			d.Write(data)
+			digest = d.Sum(nil)
+		}
+	}
+	return
+}
+
+// Return the SHA-256 RFC 7638 thumbprint of a public key, base64url
+// encoded, as commonly used for a key ID.
+func JWKThumbprint(pubKey cr.PublicKey) (tp string, err error) {
+	var (
+		j      *JWK
+		digest []byte
+	)
+	if j, err = NewJWK(pubKey); err == nil {
+		digest, err = j.Thumbprint(cr.SHA256)
+	}
+	if err == nil {
+		tp = base64.RawURLEncoding.EncodeToString(digest)
+	}
+	return
+}
+
+// SERIALIZATION ////////////////////////////////////////////////////
+
+// Serialize an RSA public key as a JWK.
+func RSAPubKeyToJWK(pubKey *rsa.PublicKey) (data []byte, err error) {
+	return PubKeyToJWK(pubKey)
+}
+
+// Deserialize an RSA public key from a JWK.
+func RSAPubKeyFromJWK(data []byte) (pubKey *rsa.PublicKey, err error) {
+	pk, err := PubKeyFromJWK(data)
+	if err == nil {
+		var ok bool
+		if pubKey, ok = pk.(*rsa.PublicKey); !ok {
+			err = NotAnRSAPublicKey
+		}
+	}
+	return
+}
+
+// Serialize an RSA private key as a JWK, which includes the public key.
+func RSAPrivateKeyToJWK(key *rsa.PrivateKey) (data []byte, err error) {
+	return PrivateKeyToJWK(key)
+}
+
+// Deserialize an RSA private key from a JWK.
+func RSAPrivateKeyFromJWK(data []byte) (key *rsa.PrivateKey, err error) {
+	k, err := PrivateKeyFromJWK(data)
+	if err == nil {
+		var ok bool
+		if key, ok = k.(*rsa.PrivateKey); !ok {
+			err = NotAnRSAPrivateKey
+		}
+	}
+	return
+}
+
+// Serialize a public key of any supported type as a JWK.
+func PubKeyToJWK(pubKey cr.PublicKey) (data []byte, err error) {
+	j, err := NewJWK(pubKey)
+	if err == nil {
+		data, err = j.Marshal()
+	}
+	return
+}
+
+// Deserialize a public key from a JWK.  Any private members are
+// ignored.
+func PubKeyFromJWK(data []byte) (pubKey cr.PublicKey, err error) {
+	j, err := ParseJWK(data)
+	if err == nil {
+		pubKey, err = j.PublicKey()
+	}
+	return
+}
+
+// Serialize a private key of any supported type as a JWK.
+func PrivateKeyToJWK(key cr.PrivateKey) (data []byte, err error) {
+	j, err := NewPrivateJWK(key)
+	if err == nil {
+		data, err = j.Marshal()
+	}
+	return
+}
+
+// Deserialize a private key from a JWK.
+func PrivateKeyFromJWK(data []byte) (key cr.PrivateKey, err error) {
+	j, err := ParseJWK(data)
+	if err == nil {
+		key, err = j.PrivateKey()
+	}
+	return
+}
+
+// JWK SETS /////////////////////////////////////////////////////////
+
+// A JWK Set, as published at a jwks_uri.  Keys of types the library
+// does not support are kept, so that a set can be read and written
+// back, but PublicKeys skips them, as RFC 7517 says readers should.
+type JWKSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// Parse a JWK Set document.
+func ParseJWKSet(data []byte) (set *JWKSet, err error) {
+	set = &JWKSet{}
+	if err = json.Unmarshal(data, set); err != nil || set.Keys == nil {
+		return nil, IllFormedJWK
+	}
+	for _, j := range set.Keys {
+		if j == nil || j.Kty == "" {
+			return nil, IllFormedJWK
+		}
+	}
+	return
+}
+
+// Serialize the set.
+func (set *JWKSet) Marshal() ([]byte, error) {
+	if set.Keys == nil {
+		return []byte(`{"keys":[]}`), nil
+	}
+	return json.Marshal(set)
+}
+
+// Add a public key to the set with the use, alg, and kid given, any of
+// which may be empty.  If kid is empty, the key's RFC 7638 thumbprint
+// is used.  Returns the JWK added.
+func (set *JWKSet) Add(pubKey cr.PublicKey, use, alg, kid string) (
+	j *JWK, err error) {
+
+	if j, err = NewJWK(pubKey); err == nil && kid == "" {
+		kid, err = JWKThumbprint(pubKey)
+	}
+	if err == nil {
+		j.Use, j.Alg, j.Kid = use, alg, kid
+		set.Keys = append(set.Keys, j)
+	}
+	return
+}
+
+// Return the first JWK with the key ID given, or nil if there is none.
+func (set *JWKSet) Find(kid string) *JWK {
+	for _, j := range set.Keys {
+		if j.Kid == kid {
+			return j
+		}
+	}
+	return nil
+}
+
+// Return the public keys in the set which the library supports, in
+// order, with their key IDs.
+func (set *JWKSet) PublicKeys() (keys []cr.PublicKey, kids []string) {
+	for _, j := range set.Keys {
+		if pubKey, err := j.PublicKey(); err == nil {
+			keys = append(keys, pubKey)
+			kids = append(kids, j.Kid)
+		}
+	}
+	return
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Encode a non-negative integer big-endian, left-padded with zeros to
+// size bytes if size is not zero.
+func jwkEncodeInt(n *big.Int, size int) string {
+	b := n.Bytes()
+	if len(b) < size {
+		b = n.FillBytes(make([]byte, size))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode an integer; if size is not zero, its encoding must be exactly
+// that many bytes long.
+func jwkDecodeInt(s string, size int) (n *big.Int, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 || (size != 0 && len(b) != size) {
+		err = IllFormedJWK
+	} else {
+		n = new(big.Int).SetBytes(b)
+	}
+	return
+}
+
+func jwkCurveName(curve elliptic.Curve) (name string, err error) {
+	switch curve {
+	case elliptic.P256():
+		name = JWK_CRV_P256
+	case elliptic.P384():
+		name = JWK_CRV_P384
+	default:
+		err = UnsupportedCurve
+	}
+	return
+}
+
+func jwkCurve(name string) (curve elliptic.Curve, err error) {
+	switch name {
+	case JWK_CRV_P256:
+		curve = elliptic.P256()
+	case JWK_CRV_P384:
+		curve = elliptic.P384()
+	default:
+		err = UnsupportedCurve
+	}
+	return
+}
+
+// The size in bytes of coordinates and private keys on the curve.
+func jwkCurveSize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
@@ -0,0 +1,210 @@
+package crypto
+
+// xlCrypto_go/jwk_test.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	. "gopkg.in/check.v1"
+	"strings"
+)
+
+const (
+	// RFC 7638 section 3.1
+	RFC7638_JWK        = `{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256","kid":"2011-04-29"}`
+	RFC7638_THUMBPRINT = "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+
+	// RFC 8037 appendices A.1 and A.3
+	RFC8037_JWK        = `{"kty":"OKP","crv":"Ed25519","d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`
+	RFC8037_THUMBPRINT = "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"
+
+	// RFC 7517 appendix A.2
+	RFC7517_EC_JWK = `{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM","d":"870MB6gfuTJ4HtUnUvYMyJpr5eUZNP4Bk43bVdj3eAE","use":"enc","kid":"1"}`
+)
+
+func (s *XLSuite) TestJWKVectors(c *C) {
+	pub, err := RSAPubKeyFromJWK([]byte(RFC7638_JWK))
+	c.Assert(err, IsNil)
+	c.Assert(pub.E, Equals, 65537)
+	c.Assert(pub.N.BitLen(), Equals, 2048)
+	tp, err := JWKThumbprint(pub)
+	c.Assert(err, IsNil)
+	c.Assert(tp, Equals, RFC7638_THUMBPRINT)
+
+	key, err := PrivateKeyFromJWK([]byte(RFC8037_JWK))
+	c.Assert(err, IsNil)
+	edKey := key.(ed25519.PrivateKey)
+	tp, err = JWKThumbprint(edKey.Public())
+	c.Assert(err, IsNil)
+	c.Assert(tp, Equals, RFC8037_THUMBPRINT)
+	data, err := PrivateKeyToJWK(edKey)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals,
+		`{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo","d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A"}`)
+
+	key, err = PrivateKeyFromJWK([]byte(RFC7517_EC_JWK))
+	c.Assert(err, IsNil)
+	ecKey := key.(*ecdsa.PrivateKey)
+	c.Assert(ecKey.Curve, Equals, elliptic.P256())
+
+	// a JWK's thumbprint does not depend on its optional members
+	j, err := ParseJWK([]byte(RFC7517_EC_JWK))
+	c.Assert(err, IsNil)
+	c.Assert(j.Kid, Equals, "1")
+	c.Assert(j.IsPrivate(), Equals, true)
+	c.Assert(j.Public().IsPrivate(), Equals, false)
+	digest, err := j.Thumbprint(cr.SHA256)
+	c.Assert(err, IsNil)
+	j2, err := NewJWK(&ecKey.PublicKey)
+	c.Assert(err, IsNil)
+	digest2, err := j2.Thumbprint(cr.SHA256)
+	c.Assert(err, IsNil)
+	c.Assert(digest2, DeepEquals, digest)
+}
+
+func (s *XLSuite) TestJWKRoundTrips(c *C) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	c.Assert(err, IsNil)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+
+	data, err := RSAPubKeyToJWK(&rsaKey.PublicKey)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(data), `"d"`), Equals, false)
+	pub, err := RSAPubKeyFromJWK(data)
+	c.Assert(err, IsNil)
+	c.Assert(pub.Equal(&rsaKey.PublicKey), Equals, true)
+
+	data, err = RSAPrivateKeyToJWK(rsaKey)
+	c.Assert(err, IsNil)
+	rsaKey2, err := RSAPrivateKeyFromJWK(data)
+	c.Assert(err, IsNil)
+	c.Assert(rsaKey2.Equal(rsaKey), Equals, true)
+	// the public members of a private JWK are a public JWK
+	_, err = RSAPubKeyFromJWK(data)
+	c.Assert(err, IsNil)
+
+	for _, key := range []cr.PrivateKey{rsaKey, ecKey, edKey} {
+		signer := key.(cr.Signer)
+		data, err = PrivateKeyToJWK(key)
+		c.Assert(err, IsNil)
+		key2, err := PrivateKeyFromJWK(data)
+		c.Assert(err, IsNil)
+		c.Assert(key2.(cr.Signer).Public(), DeepEquals, signer.Public())
+
+		data, err = PubKeyToJWK(signer.Public())
+		c.Assert(err, IsNil)
+		pub2, err := PubKeyFromJWK(data)
+		c.Assert(err, IsNil)
+		c.Assert(pub2, DeepEquals, signer.Public())
+		_, err = PrivateKeyFromJWK(data)
+		c.Assert(err, Equals, IllFormedJWK)
+	}
+
+	// the wrong key type for the function
+	data, err = PubKeyToJWK(ecKey.Public())
+	c.Assert(err, IsNil)
+	_, err = RSAPubKeyFromJWK(data)
+	c.Assert(err, Equals, NotAnRSAPublicKey)
+	_, err = PubKeyToJWK(nil)
+	c.Assert(err, Equals, NilPublicKey)
+}
+
+func (s *XLSuite) TestBadJWKs(c *C) {
+	bad := []struct {
+		json string
+		err  error
+	}{
+		{`not json`, IllFormedJWK},
+		{`{"n":"AQAB","e":"AQAB"}`, IllFormedJWK},
+		{`{"kty":"oct","k":"AQAB"}`, UnsupportedKeyType},
+		{`{"kty":"RSA","n":"AQAB=","e":"AQAB"}`, IllFormedJWK},
+		{`{"kty":"RSA","n":"AQAB","e":"AQ"}`, IllFormedJWK},
+		{`{"kty":"EC","crv":"P-521","x":"AQ","y":"AQ"}`, UnsupportedCurve},
+		// a point not on the curve
+		{`{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyQ"}`, IllFormedJWK},
+		// a short coordinate
+		{`{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7A","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}`, IllFormedJWK},
+		{`{"kty":"OKP","crv":"X25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`, UnsupportedCurve},
+	}
+	for _, t := range bad {
+		_, err := PubKeyFromJWK([]byte(t.json))
+		c.Assert(err, Equals, t.err, Commentf("%s", t.json))
+	}
+
+	// a private key which does not match the public key
+	mismatched := strings.Replace(RFC8037_JWK, `"d":"nW`, `"d":"mW`, 1)
+	_, err := PrivateKeyFromJWK([]byte(mismatched))
+	c.Assert(err, Equals, IllFormedJWK)
+	mismatched = strings.Replace(RFC7517_EC_JWK, `"d":"87`, `"d":"97`, 1)
+	_, err = PrivateKeyFromJWK([]byte(mismatched))
+	c.Assert(err, Equals, IllFormedJWK)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	j, err := NewPrivateJWK(rsaKey)
+	c.Assert(err, IsNil)
+	j.QI = j.DP
+	data, err := j.Marshal()
+	c.Assert(err, IsNil)
+	_, err = PrivateKeyFromJWK(data)
+	c.Assert(err, Equals, IllFormedJWK)
+
+	// the caller's key is not changed
+	bare := &rsa.PrivateKey{PublicKey: rsaKey.PublicKey, D: rsaKey.D,
+		Primes: rsaKey.Primes}
+	j, err = NewPrivateJWK(bare)
+	c.Assert(err, IsNil)
+	c.Assert(bare.Precomputed.Dp, IsNil)
+	c.Assert(j.DP, Equals, jwkEncodeInt(rsaKey.Precomputed.Dp, 0))
+}
+
+func (s *XLSuite) TestJWKSet(c *C) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+
+	set := &JWKSet{}
+	data, err := set.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `{"keys":[]}`)
+
+	j, err := set.Add(&rsaKey.PublicKey, "sig", "RS256", "")
+	c.Assert(err, IsNil)
+	tp, err := JWKThumbprint(&rsaKey.PublicKey)
+	c.Assert(err, IsNil)
+	c.Assert(j.Kid, Equals, tp)
+	_, err = set.Add(edPub, "sig", "EdDSA", "ed-1")
+	c.Assert(err, IsNil)
+	_, err = set.Add(nil, "", "", "")
+	c.Assert(err, Equals, NilPublicKey)
+
+	data, err = set.Marshal()
+	c.Assert(err, IsNil)
+	// add a key of a type we do not support, which readers skip
+	data = []byte(strings.Replace(string(data), `{"keys":[`,
+		`{"keys":[{"kty":"oct","kid":"hmac","k":"c2VjcmV0"},`, 1))
+
+	set2, err := ParseJWKSet(data)
+	c.Assert(err, IsNil)
+	c.Assert(len(set2.Keys), Equals, 3)
+	c.Assert(set2.Find("hmac").Kty, Equals, "oct")
+	c.Assert(set2.Find("ed-1").Alg, Equals, "EdDSA")
+	c.Assert(set2.Find("nope"), IsNil)
+	keys, kids := set2.PublicKeys()
+	c.Assert(kids, DeepEquals, []string{tp, "ed-1"})
+	c.Assert(keys[0].(*rsa.PublicKey).Equal(&rsaKey.PublicKey), Equals, true)
+	c.Assert(keys[1].(ed25519.PublicKey).Equal(edPub), Equals, true)
+
+	for _, doc := range []string{`[]`, `{}`, `{"keys":[{}]}`, `{"keys":[null]}`} {
+		_, err = ParseJWKSet([]byte(doc))
+		c.Assert(err, Equals, IllFormedJWK, Commentf("%s", doc))
+	}
+}
@@ -0,0 +1,17 @@
+package keystore
+
+// xlCrypto_go/keystore/errors.go
+
+import (
+	e "errors"
+)
+
+var (
+	BadKeyName              = e.New("key names must be letters, digits, '.', '_' or '-'")
+	InsecurePermissions     = e.New("keystore file or directory accessible to others")
+	KeyExists               = e.New("a key with that name already exists")
+	KeyNotFound             = e.New("no key with that name")
+	UnsupportedAlgorithm    = e.New("unsupported key algorithm")
+	UnsupportedExportFormat = e.New("unsupported export format")
+	UsageNotPermitted       = e.New("key is not intended for this usage")
+)
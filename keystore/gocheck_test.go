@@ -0,0 +1,14 @@
+package keystore
+
+// xlCrypto_go/keystore/gocheck_test.go
+
+import (
+	. "gopkg.in/check.v1"
+	"testing"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type XLSuite struct{}
+
+var _ = Suite(&XLSuite{})
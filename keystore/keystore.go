@@ -0,0 +1,495 @@
+package keystore
+
+// xlCrypto_go/keystore/keystore.go
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"encoding/pem"
+	xc "github.com/jddixon/xlCrypto_go"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * A KeyStore is a directory of named keys.  Each key is kept in three
+ * files:
+ *
+ *   NAME.key    the private key, PKCS#8 PEM, encrypted if a passphrase
+ *               was given when it was stored
+ *   NAME.pub    the public key in SSH authorized_keys format
+ *   NAME.json   metadata: algorithm, fingerprint, creation time, usage
+ *
+ * The directory is mode 0700 and the files are mode 0600, except for
+ * the public key, which is 0644.  A directory or private key file which
+ * others can read is refused with InsecurePermissions.
+ *
+ * Private key files are read with xc.LoadPrivateKey, so a key copied
+ * into the directory by hand may be in any format the library reads,
+ * provided it has a metadata file.
+ */
+
+const (
+	// key algorithms
+	RSA_2048   = "RSA-2048"
+	RSA_3072   = "RSA-3072"
+	RSA_4096   = "RSA-4096"
+	ED25519    = "Ed25519"
+	ECDSA_P256 = "ECDSA-P256"
+	ECDSA_P384 = "ECDSA-P384"
+
+	// intended usages; a key with no usage recorded may be used for any
+	USAGE_SIGN       = "sign"
+	USAGE_BUILD_LIST = "buildlist"
+	USAGE_SSH        = "ssh"
+
+	// public key export formats
+	EXPORT_PEM = "pem"
+	EXPORT_SSH = "ssh"
+	EXPORT_JWK = "jwk"
+
+	DIR_PERM  = 0700
+	KEY_PERM  = 0600
+	PUB_PERM  = 0644
+	KEY_EXT   = ".key"
+	PUB_EXT   = ".pub"
+	META_EXT  = ".json"
+	MAX_NAME  = 64
+	TEMP_BASE = ".tmp-"
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Metadata describing a stored key.
+type KeyInfo struct {
+	Name        string    `json:"name"`
+	Algorithm   string    `json:"algorithm"`
+	Fingerprint string    `json:"fingerprint"` // as ssh-keygen -l shows it
+	Created     time.Time `json:"created"`
+	Usage       []string  `json:"usage,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	Encrypted   bool      `json:"encrypted"`
+}
+
+// Whether the key is intended for the usage given.  A key with no
+// usage recorded may be used for anything.
+func (info *KeyInfo) Permits(usage string) bool {
+	if len(info.Usage) == 0 {
+		return true
+	}
+	for _, u := range info.Usage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+type KeyStore struct {
+	dir string
+}
+
+// Open the keystore in the directory given, creating the directory if
+// it does not exist.
+func Open(dir string) (ks *KeyStore, err error) {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		err = os.MkdirAll(dir, DIR_PERM)
+		if err == nil {
+			// MkdirAll is subject to the umask
+			err = os.Chmod(dir, DIR_PERM)
+		}
+	} else if err == nil && (!info.IsDir() || info.Mode().Perm()&0077 != 0) {
+		err = InsecurePermissions
+	}
+	if err == nil {
+		ks = &KeyStore{dir: dir}
+	}
+	return
+}
+
+// Return the keystore's directory.
+func (ks *KeyStore) Dir() string {
+	return ks.dir
+}
+
+// GENERATE, IMPORT, DELETE /////////////////////////////////////////
+
+// Generate a key with the algorithm given, one of RSA_2048, RSA_3072,
+// RSA_4096, ED25519, ECDSA_P256, or ECDSA_P384, and store it under the
+// name.  If the passphrase is not empty the private key is encrypted
+// with it.
+func (ks *KeyStore) Generate(name, algorithm string, usage []string,
+	passphrase []byte) (info *KeyInfo, err error) {
+
+	var key crypto.Signer
+	if err = ks.checkNew(name); err == nil {
+		switch algorithm {
+		case RSA_2048, RSA_3072, RSA_4096:
+			bits := map[string]int{RSA_2048: 2048, RSA_3072: 3072, RSA_4096: 4096}
+			key, err = rsa.GenerateKey(rand.Reader, bits[algorithm])
+		case ED25519:
+			_, key, err = ed25519.GenerateKey(rand.Reader)
+		case ECDSA_P256:
+			key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		case ECDSA_P384:
+			key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		default:
+			err = UnsupportedAlgorithm
+		}
+	}
+	if err == nil {
+		info, err = ks.store(name, key, usage, "", passphrase)
+	}
+	return
+}
+
+// Import a private key in any format xc.LoadPrivateKey reads and store
+// it under the name.  The passphrase is used to decrypt the key if it
+// is encrypted, and to encrypt the stored key if it is not empty.  A
+// key whose comment contains a line break is refused with
+// xc.BadKeyComment.
+func (ks *KeyStore) Import(name string, data, passphrase []byte,
+	usage []string) (info *KeyInfo, err error) {
+
+	var (
+		key    crypto.PrivateKey
+		format *xc.KeyFormat
+	)
+	if err = ks.checkNew(name); err == nil {
+		key, format, err = xc.LoadPrivateKey(data, passphrase)
+	}
+	if err == nil {
+		if signer, ok := key.(crypto.Signer); !ok {
+			err = xc.UnsupportedKeyType
+		} else {
+			info, err = ks.store(name, signer, usage, format.Comment,
+				passphrase)
+		}
+	}
+	return
+}
+
+// Delete the key stored under the name.
+func (ks *KeyStore) Delete(name string) (err error) {
+	if _, err = ks.Info(name); err == nil {
+		// remove the metadata first, so that a partial delete is not listed
+		for _, ext := range []string{META_EXT, KEY_EXT, PUB_EXT} {
+			if e := os.Remove(ks.path(name, ext)); e != nil &&
+				!os.IsNotExist(e) && err == nil {
+
+				err = e
+			}
+		}
+	}
+	return
+}
+
+// Check that the name is valid and not in use.
+func (ks *KeyStore) checkNew(name string) (err error) {
+	if !isValidName(name) {
+		err = BadKeyName
+	} else if _, e := os.Lstat(ks.path(name, META_EXT)); e == nil {
+		err = KeyExists
+	} else if !os.IsNotExist(e) {
+		err = e
+	}
+	return
+}
+
+// Write the key files and then the metadata.  None of the files may
+// exist already: if another store under the same name has begun,
+// or a private key file has been left without metadata, the error is
+// KeyExists and nothing is overwritten.
+func (ks *KeyStore) store(name string, key crypto.Signer, usage []string,
+	comment string, passphrase []byte) (info *KeyInfo, err error) {
+
+	var (
+		priv, pub, meta []byte
+		fp, algorithm   string
+	)
+	if strings.ContainsAny(comment, "\r\n\x00") {
+		// it would break the authorized_keys line in the .pub file
+		err = xc.BadKeyComment
+	} else if algorithm, err = algorithmName(key.Public()); err == nil {
+		fp, err = xc.SSHFingerprintSHA256(key.Public())
+	}
+	if err == nil {
+		if len(passphrase) > 0 {
+			priv, err = xc.PrivateKeyToEncryptedPKCS8PEM(key, passphrase)
+		} else {
+			priv, err = xc.PrivateKeyToPKCS8PEM(key)
+		}
+	}
+	if err == nil {
+		pub, err = xc.PubKeyToDisk(key.Public())
+	}
+	if err == nil {
+		if comment != "" {
+			pub = []byte(strings.TrimSpace(string(pub)) + " " + comment + "\n")
+		}
+		info = &KeyInfo{
+			Name:        name,
+			Algorithm:   algorithm,
+			Fingerprint: fp,
+			Created:     time.Now().UTC().Truncate(time.Second),
+			Usage:       usage,
+			Comment:     comment,
+			Encrypted:   len(passphrase) > 0,
+		}
+		meta, err = json.MarshalIndent(info, "", "  ")
+	}
+	if err == nil {
+		// the private key file, written first, reserves the name
+		files := []struct {
+			ext  string
+			data []byte
+			perm os.FileMode
+		}{
+			{KEY_EXT, priv, KEY_PERM},
+			{PUB_EXT, pub, PUB_PERM},
+			{META_EXT, append(meta, '\n'), KEY_PERM},
+		}
+		var written []string
+		for i := 0; err == nil && i < len(files); i++ {
+			f := files[i]
+			if err = ks.writeFile(name, f.ext, f.data, f.perm); err == nil {
+				written = append(written, ks.path(name, f.ext))
+			}
+		}
+		if err != nil {
+			for _, path := range written {
+				os.Remove(path)
+			}
+		}
+	}
+	if err != nil {
+		info = nil
+	}
+	return
+}
+
+// LOOKUP ///////////////////////////////////////////////////////////
+
+// Return the metadata for the key stored under the name.
+func (ks *KeyStore) Info(name string) (info *KeyInfo, err error) {
+	var data []byte
+	if !isValidName(name) {
+		err = BadKeyName
+	} else if data, err = os.ReadFile(ks.path(name, META_EXT)); err != nil {
+		if os.IsNotExist(err) {
+			err = KeyNotFound
+		}
+	} else {
+		info = &KeyInfo{}
+		if err = json.Unmarshal(data, info); err != nil {
+			info = nil
+		}
+	}
+	return
+}
+
+// Return the metadata for every key in the store, sorted by name.
+// Files whose names are not valid key names are not keys and are
+// skipped.
+func (ks *KeyStore) List() (infos []*KeyInfo, err error) {
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*"+META_EXT))
+	sort.Strings(paths)
+	for _, path := range paths {
+		var info *KeyInfo
+		name := strings.TrimSuffix(filepath.Base(path), META_EXT)
+		if !isValidName(name) {
+			continue
+		}
+		if info, err = ks.Info(name); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return
+}
+
+// Return the public key stored under the name.
+func (ks *KeyStore) PublicKey(name string) (pubKey crypto.PublicKey, err error) {
+	var data []byte
+	if _, err = ks.Info(name); err == nil {
+		data, err = os.ReadFile(ks.path(name, PUB_EXT))
+	}
+	if err == nil {
+		pubKey, _, err = xc.LoadPublicKey(data)
+	}
+	return
+}
+
+// Return the private key stored under the name as a crypto.Signer,
+// decrypting it with the passphrase if it is encrypted.
+func (ks *KeyStore) Signer(name string, passphrase []byte) (
+	signer crypto.Signer, err error) {
+
+	var (
+		fi   os.FileInfo
+		data []byte
+		key  crypto.PrivateKey
+	)
+	path := ks.path(name, KEY_EXT)
+	if _, err = ks.Info(name); err == nil {
+		fi, err = os.Stat(path)
+	}
+	if err == nil && fi.Mode().Perm()&0077 != 0 {
+		err = InsecurePermissions
+	}
+	if err == nil {
+		data, err = os.ReadFile(path)
+	}
+	if err == nil {
+		key, _, err = xc.LoadPrivateKey(data, passphrase)
+	}
+	if err == nil {
+		var ok bool
+		if signer, ok = key.(crypto.Signer); !ok {
+			err = xc.UnsupportedKeyType
+		}
+	}
+	return
+}
+
+// Return the key stored under the name as an xc.KeyI, through which
+// DigSignerI signers can be obtained.  The signers sign SHA256
+// digests, or SHA512 digests for Ed25519 keys, as xc.NewSignerKey
+// describes.
+func (ks *KeyStore) Key(name string, passphrase []byte) (key xc.KeyI, err error) {
+	var sk *xc.SignerKey
+	signer, err := ks.Signer(name, passphrase)
+	if err == nil {
+		sk, err = xc.NewSignerKey(signer, 0)
+	}
+	if err == nil {
+		key = sk
+	}
+	return
+}
+
+// Return a DigSignerI for the key stored under the name.
+func (ks *KeyStore) DigSigner(name string, passphrase []byte) (
+	signer xc.DigSignerI, err error) {
+
+	key, err := ks.Key(name, passphrase)
+	if err == nil {
+		signer = key.GetSigner()
+	}
+	return
+}
+
+// EXPORT ///////////////////////////////////////////////////////////
+
+// Export the public key stored under the name in the format given,
+// EXPORT_PEM, EXPORT_SSH, or EXPORT_JWK.  A JWK has the key's name as
+// its key ID.
+func (ks *KeyStore) ExportPublic(name, format string) (data []byte, err error) {
+	pubKey, err := ks.PublicKey(name)
+	if err == nil {
+		switch format {
+		case EXPORT_PEM:
+			var der []byte
+			if der, err = xc.PubKeyToWire(pubKey); err == nil {
+				data = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+			}
+		case EXPORT_SSH:
+			data, err = os.ReadFile(ks.path(name, PUB_EXT))
+		case EXPORT_JWK:
+			var j *xc.JWK
+			if j, err = xc.NewJWK(pubKey); err == nil {
+				j.Kid = name
+				data, err = j.Marshal()
+			}
+		default:
+			err = UnsupportedExportFormat
+		}
+	}
+	return
+}
+
+// Export the private key stored under the name as PKCS#8 PEM, decrypting
+// it with the passphrase if it is encrypted.  If exportPassphrase is
+// not empty, the key exported is encrypted with it.
+func (ks *KeyStore) ExportPrivate(name string,
+	passphrase, exportPassphrase []byte) (data []byte, err error) {
+
+	signer, err := ks.Signer(name, passphrase)
+	if err == nil {
+		if len(exportPassphrase) > 0 {
+			data, err = xc.PrivateKeyToEncryptedPKCS8PEM(signer, exportPassphrase)
+		} else {
+			data, err = xc.PrivateKeyToPKCS8PEM(signer)
+		}
+	}
+	return
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Whether the name may be used for a key.
+func isValidName(name string) bool {
+	return validName.MatchString(name) && len(name) <= MAX_NAME
+}
+
+func (ks *KeyStore) path(name, ext string) string {
+	return filepath.Join(ks.dir, name+ext)
+}
+
+// Write a new file atomically with the permissions given.  The file is
+// linked into place rather than renamed, so an existing file is never
+// replaced; if there is one the error is KeyExists.
+func (ks *KeyStore) writeFile(name, ext string, data []byte,
+	perm os.FileMode) (err error) {
+
+	tmp, err := os.CreateTemp(ks.dir, TEMP_BASE)
+	if err == nil {
+		err = tmp.Chmod(perm)
+		if err == nil {
+			_, err = tmp.Write(data)
+		}
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			if err = os.Link(tmp.Name(), ks.path(name, ext)); os.IsExist(err) {
+				err = KeyExists
+			}
+		}
+		os.Remove(tmp.Name())
+	}
+	return
+}
+
+// Name the algorithm of a public key as Generate does.
+func algorithmName(pubKey crypto.PublicKey) (name string, err error) {
+	switch pk := pubKey.(type) {
+	case *rsa.PublicKey:
+		name = "RSA-" + strconv.Itoa(pk.N.BitLen())
+	case ed25519.PublicKey:
+		name = ED25519
+	case *ecdsa.PublicKey:
+		switch pk.Curve {
+		case elliptic.P256():
+			name = ECDSA_P256
+		case elliptic.P384():
+			name = ECDSA_P384
+		default:
+			err = xc.UnsupportedCurve
+		}
+	default:
+		err = xc.UnsupportedKeyType
+	}
+	return
+}
@@ -0,0 +1,390 @@
+package keystore
+
+// xlCrypto_go/keystore/keystore_test.go
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	xc "github.com/jddixon/xlCrypto_go"
+	. "gopkg.in/check.v1"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+func (s *XLSuite) checkPerm(c *C, path string, perm os.FileMode) {
+	fi, err := os.Stat(path)
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, perm)
+}
+
+func (s *XLSuite) TestKeyStoreGenerate(c *C) {
+	dir := filepath.Join(c.MkDir(), "keys")
+	ks, err := Open(dir)
+	c.Assert(err, IsNil)
+	c.Assert(ks.Dir(), Equals, dir)
+	s.checkPerm(c, dir, DIR_PERM)
+
+	algorithms := []string{ED25519, ECDSA_P256, ECDSA_P384, RSA_2048}
+	for _, algorithm := range algorithms {
+		name := strings.ToLower(algorithm)
+		info, err := ks.Generate(name, algorithm, []string{USAGE_SIGN}, nil)
+		c.Assert(err, IsNil)
+		c.Assert(info.Name, Equals, name)
+		c.Assert(info.Algorithm, Equals, algorithm)
+		c.Assert(info.Encrypted, Equals, false)
+		c.Assert(info.Permits(USAGE_SIGN), Equals, true)
+		c.Assert(info.Permits(USAGE_SSH), Equals, false)
+
+		s.checkPerm(c, filepath.Join(dir, name+KEY_EXT), KEY_PERM)
+		s.checkPerm(c, filepath.Join(dir, name+PUB_EXT), PUB_PERM)
+		s.checkPerm(c, filepath.Join(dir, name+META_EXT), KEY_PERM)
+
+		// the metadata read back matches, as does the fingerprint
+		info2, err := ks.Info(name)
+		c.Assert(err, IsNil)
+		c.Assert(info2, DeepEquals, info)
+		pubKey, err := ks.PublicKey(name)
+		c.Assert(err, IsNil)
+		fp, err := xc.SSHFingerprintSHA256(pubKey)
+		c.Assert(err, IsNil)
+		c.Assert(info.Fingerprint, Equals, fp)
+
+		// the signer signs with the key stored
+		signer, err := ks.Signer(name, nil)
+		c.Assert(err, IsNil)
+		c.Assert(signer.Public().(interface {
+			Equal(crypto.PublicKey) bool
+		}).Equal(pubKey), Equals, true)
+		msg := []byte("the quick brown fox")
+		digest := sha256.Sum256(msg)
+		switch pk := pubKey.(type) {
+		case ed25519.PublicKey:
+			sig, err := signer.Sign(rand.Reader, msg, crypto.Hash(0))
+			c.Assert(err, IsNil)
+			c.Assert(ed25519.Verify(pk, msg, sig), Equals, true)
+		case *ecdsa.PublicKey:
+			sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+			c.Assert(err, IsNil)
+			c.Assert(ecdsa.VerifyASN1(pk, digest[:], sig), Equals, true)
+		case *rsa.PublicKey:
+			sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+			c.Assert(err, IsNil)
+			c.Assert(rsa.VerifyPKCS1v15(pk, crypto.SHA256, digest[:], sig), IsNil)
+		}
+	}
+
+	infos, err := ks.List()
+	c.Assert(err, IsNil)
+	c.Assert(len(infos), Equals, len(algorithms))
+	for i := 1; i < len(infos); i++ {
+		c.Assert(infos[i-1].Name < infos[i].Name, Equals, true)
+	}
+
+	// no temporary files are left behind
+	temps, err := filepath.Glob(filepath.Join(dir, TEMP_BASE+"*"))
+	c.Assert(err, IsNil)
+	c.Assert(len(temps), Equals, 0)
+
+	// a store reopened sees the same keys
+	ks2, err := Open(dir)
+	c.Assert(err, IsNil)
+	infos2, err := ks2.List()
+	c.Assert(err, IsNil)
+	c.Assert(infos2, DeepEquals, infos)
+}
+
+func (s *XLSuite) TestKeyStoreNames(c *C) {
+	ks, err := Open(c.MkDir())
+	c.Assert(err, IsNil)
+
+	_, err = ks.Generate("signer", ED25519, nil, nil)
+	c.Assert(err, IsNil)
+	_, err = ks.Generate("signer", ED25519, nil, nil)
+	c.Assert(err, Equals, KeyExists)
+	_, err = ks.Generate("other", "DSA-1024", nil, nil)
+	c.Assert(err, Equals, UnsupportedAlgorithm)
+
+	for _, name := range []string{"", ".hidden", "../escape", "a/b",
+		"with space", strings.Repeat("x", MAX_NAME+1)} {
+
+		_, err = ks.Generate(name, ED25519, nil, nil)
+		c.Assert(err, Equals, BadKeyName)
+		_, err = ks.Info(name)
+		c.Assert(err, Equals, BadKeyName)
+	}
+	_, err = ks.Info("missing")
+	c.Assert(err, Equals, KeyNotFound)
+	_, err = ks.Signer("missing", nil)
+	c.Assert(err, Equals, KeyNotFound)
+	c.Assert(ks.Delete("missing"), Equals, KeyNotFound)
+
+	// a key with no usage recorded may be used for anything
+	info, err := ks.Info("signer")
+	c.Assert(err, IsNil)
+	c.Assert(info.Permits(USAGE_BUILD_LIST), Equals, true)
+
+	c.Assert(ks.Delete("signer"), IsNil)
+	_, err = ks.Info("signer")
+	c.Assert(err, Equals, KeyNotFound)
+	infos, err := ks.List()
+	c.Assert(err, IsNil)
+	c.Assert(len(infos), Equals, 0)
+	entries, err := os.ReadDir(ks.Dir())
+	c.Assert(err, IsNil)
+	c.Assert(len(entries), Equals, 0)
+
+	// JSON files which are not named as keys are not listed
+	stray := filepath.Join(ks.Dir(), ".settings"+META_EXT)
+	c.Assert(os.WriteFile(stray, []byte("{}"), 0600), IsNil)
+	infos, err = ks.List()
+	c.Assert(err, IsNil)
+	c.Assert(len(infos), Equals, 0)
+
+	// the name can then be reused
+	_, err = ks.Generate("signer", ECDSA_P256, nil, nil)
+	c.Assert(err, IsNil)
+}
+
+func (s *XLSuite) TestKeyStoreSameName(c *C) {
+	ks, err := Open(c.MkDir())
+	c.Assert(err, IsNil)
+
+	// of several keys stored under one name at once, one is kept
+	const N = 8
+	var (
+		wg    sync.WaitGroup
+		infos [N]*KeyInfo
+		errs  [N]error
+	)
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			infos[i], errs[i] = ks.Generate("racer", ED25519, nil, nil)
+		}(i)
+	}
+	wg.Wait()
+	var winner *KeyInfo
+	for i := 0; i < N; i++ {
+		if errs[i] == nil {
+			c.Assert(winner, IsNil)
+			winner = infos[i]
+		} else {
+			c.Assert(errs[i], Equals, KeyExists)
+		}
+	}
+	c.Assert(winner, NotNil)
+	signer, err := ks.Signer("racer", nil)
+	c.Assert(err, IsNil)
+	fp, err := xc.SSHFingerprintSHA256(signer.Public())
+	c.Assert(err, IsNil)
+	c.Assert(fp, Equals, winner.Fingerprint)
+	pubKey, err := ks.PublicKey("racer")
+	c.Assert(err, IsNil)
+	fp, err = xc.SSHFingerprintSHA256(pubKey)
+	c.Assert(err, IsNil)
+	c.Assert(fp, Equals, winner.Fingerprint)
+
+	// a private key file without metadata is not overwritten
+	stray := filepath.Join(ks.Dir(), "stray"+KEY_EXT)
+	c.Assert(os.WriteFile(stray, []byte("not a key"), KEY_PERM), IsNil)
+	_, err = ks.Generate("stray", ED25519, nil, nil)
+	c.Assert(err, Equals, KeyExists)
+	data, err := os.ReadFile(stray)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "not a key")
+	_, err = ks.Info("stray")
+	c.Assert(err, Equals, KeyNotFound)
+
+	// and no temporary files are left behind
+	entries, err := os.ReadDir(ks.Dir())
+	c.Assert(err, IsNil)
+	c.Assert(len(entries), Equals, 4)
+}
+
+func (s *XLSuite) TestKeyStorePermissions(c *C) {
+	dir := c.MkDir()
+	c.Assert(os.Chmod(dir, 0755), IsNil)
+	_, err := Open(dir)
+	c.Assert(err, Equals, InsecurePermissions)
+
+	c.Assert(os.Chmod(dir, DIR_PERM), IsNil)
+	ks, err := Open(dir)
+	c.Assert(err, IsNil)
+	_, err = ks.Generate("k", ED25519, nil, nil)
+	c.Assert(err, IsNil)
+
+	// a private key which others can read is refused
+	keyPath := filepath.Join(dir, "k"+KEY_EXT)
+	c.Assert(os.Chmod(keyPath, 0644), IsNil)
+	_, err = ks.Signer("k", nil)
+	c.Assert(err, Equals, InsecurePermissions)
+	c.Assert(os.Chmod(keyPath, KEY_PERM), IsNil)
+	_, err = ks.Signer("k", nil)
+	c.Assert(err, IsNil)
+
+	// so is a plain file where the directory should be
+	file := filepath.Join(c.MkDir(), "file")
+	c.Assert(os.WriteFile(file, []byte("x"), 0600), IsNil)
+	_, err = Open(file)
+	c.Assert(err, Equals, InsecurePermissions)
+}
+
+func (s *XLSuite) TestKeyStoreEncrypted(c *C) {
+	ks, err := Open(c.MkDir())
+	c.Assert(err, IsNil)
+	passphrase := []byte("correct horse battery staple")
+
+	info, err := ks.Generate("secret", ECDSA_P256, nil, passphrase)
+	c.Assert(err, IsNil)
+	c.Assert(info.Encrypted, Equals, true)
+	data, err := os.ReadFile(filepath.Join(ks.Dir(), "secret"+KEY_EXT))
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(data), "ENCRYPTED PRIVATE KEY"), Equals, true)
+
+	_, err = ks.Signer("secret", nil)
+	c.Assert(err, Equals, xc.PassphraseRequired)
+	_, err = ks.Signer("secret", []byte("wrong"))
+	c.Assert(err, Equals, xc.BadPassphrase)
+	signer, err := ks.Signer("secret", passphrase)
+	c.Assert(err, IsNil)
+
+	// the public key needs no passphrase
+	pubKey, err := ks.PublicKey("secret")
+	c.Assert(err, IsNil)
+	c.Assert(pubKey.(*ecdsa.PublicKey).Equal(signer.Public()), Equals, true)
+
+	// exported in the clear, or under another passphrase
+	clear, err := ks.ExportPrivate("secret", passphrase, nil)
+	c.Assert(err, IsNil)
+	key, format, err := xc.LoadPrivateKey(clear, nil)
+	c.Assert(err, IsNil)
+	c.Assert(format.Encryption, Equals, "")
+	c.Assert(key.(*ecdsa.PrivateKey).Equal(signer), Equals, true)
+
+	other := []byte("another passphrase")
+	enc, err := ks.ExportPrivate("secret", passphrase, other)
+	c.Assert(err, IsNil)
+	_, _, err = xc.LoadPrivateKey(enc, passphrase)
+	c.Assert(err, Equals, xc.BadPassphrase)
+	key, _, err = xc.LoadPrivateKey(enc, other)
+	c.Assert(err, IsNil)
+	c.Assert(key.(*ecdsa.PrivateKey).Equal(signer), Equals, true)
+}
+
+func (s *XLSuite) TestKeyStoreImportExport(c *C) {
+	ks, err := Open(c.MkDir())
+	c.Assert(err, IsNil)
+
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	passphrase := []byte("import me")
+	data, err := xc.PrivateKeyToOpenSSHWithPassphrase(privKey,
+		"jdd@example.com", passphrase)
+	c.Assert(err, IsNil)
+
+	_, err = ks.Import("imported", data, nil, []string{USAGE_SSH})
+	c.Assert(err, Equals, xc.PassphraseRequired)
+	info, err := ks.Import("imported", data, passphrase, []string{USAGE_SSH})
+	c.Assert(err, IsNil)
+	c.Assert(info.Algorithm, Equals, ED25519)
+	c.Assert(info.Comment, Equals, "jdd@example.com")
+	c.Assert(info.Encrypted, Equals, true)
+	_, err = ks.Import("imported", data, passphrase, nil)
+	c.Assert(err, Equals, KeyExists)
+
+	// a key whose comment would add a line to the .pub file is refused
+	data, err = xc.PrivateKeyToOpenSSH(privKey,
+		"jdd@example.com\nssh-ed25519 AAAA attacker")
+	c.Assert(err, IsNil)
+	_, err = ks.Import("injected", data, nil, nil)
+	c.Assert(err, Equals, xc.BadKeyComment)
+	_, err = ks.Info("injected")
+	c.Assert(err, Equals, KeyNotFound)
+
+	// a public key cannot be imported
+	sshPub, err := xc.PubKeyToDisk(pubKey)
+	c.Assert(err, IsNil)
+	_, err = ks.Import("public", sshPub, nil, nil)
+	c.Assert(err, Equals, xc.UnexpectedPublicKey)
+
+	// SSH export carries the comment
+	exported, err := ks.ExportPublic("imported", EXPORT_SSH)
+	c.Assert(err, IsNil)
+	c.Assert(string(exported), Equals,
+		strings.TrimSpace(string(sshPub))+" jdd@example.com\n")
+
+	exported, err = ks.ExportPublic("imported", EXPORT_PEM)
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(string(exported), "-----BEGIN PUBLIC KEY-----"),
+		Equals, true)
+	key, format, err := xc.LoadPublicKey(exported)
+	c.Assert(err, IsNil)
+	c.Assert(format.Encoding, Equals, xc.KEY_ENCODING_PEM)
+	c.Assert(key.(ed25519.PublicKey).Equal(pubKey), Equals, true)
+
+	// the JWK has the name as its key ID
+	exported, err = ks.ExportPublic("imported", EXPORT_JWK)
+	c.Assert(err, IsNil)
+	j, err := xc.ParseJWK(exported)
+	c.Assert(err, IsNil)
+	c.Assert(j.Kid, Equals, "imported")
+	key, err = j.PublicKey()
+	c.Assert(err, IsNil)
+	c.Assert(key.(ed25519.PublicKey).Equal(pubKey), Equals, true)
+
+	_, err = ks.ExportPublic("imported", "der")
+	c.Assert(err, Equals, UnsupportedExportFormat)
+	_, err = ks.ExportPublic("missing", EXPORT_PEM)
+	c.Assert(err, Equals, KeyNotFound)
+}
+
+func (s *XLSuite) TestKeyStoreKeyI(c *C) {
+	ks, err := Open(c.MkDir())
+	c.Assert(err, IsNil)
+
+	for _, algo := range []string{RSA_2048, ED25519, ECDSA_P256} {
+		name := strings.ToLower(algo)
+		_, err = ks.Generate(name, algo, nil, nil)
+		c.Assert(err, IsNil)
+		key, err := ks.Key(name, nil)
+		c.Assert(err, IsNil)
+
+		signer, err := ks.DigSigner(name, nil)
+		c.Assert(err, IsNil)
+		msg := []byte("abc")
+		signer.Update(msg)
+		sig := signer.Sign()
+		c.Assert(sig, NotNil)
+		c.Assert(len(sig) <= signer.Length(), Equals, true)
+
+		// never SHA1
+		expected := crypto.SHA256
+		if algo == ED25519 {
+			expected = crypto.SHA512
+		}
+		c.Assert(key.(*xc.SignerKey).GetDigestAlgo(), Equals, expected)
+		verifier, err := xc.NewDigestSigVerifier(expected)
+		c.Assert(err, IsNil)
+		verifier.Init(key.GetPublicKey())
+		verifier.Update(msg)
+		c.Assert(verifier.Verify(sig), Equals, true)
+		verifier, err = xc.NewDigestSigVerifier(crypto.SHA1)
+		c.Assert(err, IsNil)
+		verifier.Init(key.GetPublicKey())
+		verifier.Update(msg)
+		c.Assert(verifier.Verify(sig), Equals, false)
+	}
+	key, err := ks.Key("rsa-2048", nil)
+	c.Assert(err, IsNil)
+	c.Assert(key.Algorithm(), Equals, xc.RSA_ALGORITHM)
+	signer, err := ks.DigSigner("rsa-2048", nil)
+	c.Assert(err, IsNil)
+	c.Assert(signer.Algorithm(nil), Equals, "SHA256withRSA")
+}
@@ -0,0 +1,272 @@
+package crypto
+
+// xlCrypto_go/knownHosts.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OpenSSH known_hosts files, as described in the SSH_KNOWN_HOSTS FILE
+// FORMAT section of sshd(8).  Each line is
+//
+//     [marker] hosts keytype base64-key [comment]
+//
+// where the optional marker is @cert-authority or @revoked and hosts is
+// either a comma-separated list of patterns or a single hashed entry of
+// the form |1|base64(salt)|base64(HMAC-SHA1(salt, host)).  Patterns may
+// use the wildcards * and ? and be negated with a leading '!'.  A host
+// on a port other than 22 is written [host]:port, both in patterns and
+// before hashing.
+//
+// golang.org/x/crypto/ssh/knownhosts also reads these files, but only
+// to build an ssh.HostKeyCallback for use during a handshake, and it
+// rejects the whole file if any line cannot be parsed.  This parser,
+// like ssh itself, skips such lines, recording why in Err; it exposes
+// the entries with their markers and comments, and answers a lookup
+// with a status and the entry which decided it rather than an error,
+// for checking keys outside a handshake.  Hostnames are hashed as
+// knownhosts and ssh-keygen -H hash them.
+
+const (
+	KNOWN_HOSTS_CERT_AUTHORITY = "@cert-authority"
+	KNOWN_HOSTS_REVOKED        = "@revoked"
+	KNOWN_HOSTS_HASH_MAGIC     = "|1|"
+	SSH_DEFAULT_PORT           = 22
+)
+
+// The result of looking up a host key.
+type HostKeyStatus int
+
+const (
+	HOST_KEY_UNKNOWN  HostKeyStatus = iota // no key of this type for the host
+	HOST_KEY_MATCH                         // a known key for the host
+	HOST_KEY_MISMATCH                      // another key of this type
+	HOST_KEY_REVOKED                       // the key has been revoked
+)
+
+var hostKeyStatusNames = []string{"unknown", "match", "mismatch", "revoked"}
+
+func (s HostKeyStatus) String() string {
+	if s >= 0 && int(s) < len(hostKeyStatusNames) {
+		return hostKeyStatusNames[s]
+	}
+	return "HostKeyStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+type KnownHostsEntry struct {
+	LineNo   int
+	Marker   string   // "", KNOWN_HOSTS_CERT_AUTHORITY or KNOWN_HOSTS_REVOKED
+	Patterns []string // host patterns; nil if the entry is hashed
+	Key      cr.PublicKey
+	Comment  string
+	Err      error // why the line is ignored; nil if it is not
+	salt     []byte
+	hash     []byte
+}
+
+// Whether the entry holds a hashed hostname.
+func (ent *KnownHostsEntry) IsHashed() bool {
+	return ent.hash != nil
+}
+
+// Whether the entry applies to the host, which should already have
+// been formatted with KnownHostsName.
+func (ent *KnownHostsEntry) MatchesHost(name string) bool {
+	if ent.Err != nil || ent.Key == nil {
+		return false
+	}
+	name = strings.ToLower(name)
+	if ent.hash != nil {
+		return hmac.Equal(hashHostname(ent.salt, name), ent.hash)
+	}
+	return MatchPatternList(name, ent.Patterns) > 0
+}
+
+type KnownHosts struct {
+	Entries []*KnownHostsEntry
+}
+
+// Parse the contents of a known_hosts file.  Lines which cannot be
+// parsed are recorded with the reason in Err and otherwise ignored.
+func ParseKnownHosts(data []byte) *KnownHosts {
+	kh := &KnownHosts{}
+	for i, line := range strings.Split(string(data), "\n") {
+		text := strings.TrimSpace(line)
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		ent := parseKnownHostsLine(text)
+		ent.LineNo = i + 1
+		kh.Entries = append(kh.Entries, ent)
+	}
+	return kh
+}
+
+// Read and parse a known_hosts file.
+func ReadKnownHosts(path string) (kh *KnownHosts, err error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		kh = ParseKnownHosts(data)
+	}
+	return
+}
+
+func parseKnownHostsLine(text string) (ent *KnownHostsEntry) {
+	ent = &KnownHostsEntry{}
+	end := strings.IndexAny(text, " \t")
+	if text[0] == '@' && end != -1 {
+		marker := text[:end]
+		if marker != KNOWN_HOSTS_CERT_AUTHORITY && marker != KNOWN_HOSTS_REVOKED {
+			ent.Err = IllFormedKnownHost
+			return
+		}
+		ent.Marker, text = marker, strings.TrimLeft(text[end:], " \t")
+		end = strings.IndexAny(text, " \t")
+	}
+	if end == -1 {
+		ent.Err = IllFormedKnownHost
+		return
+	}
+	hosts := text[:end]
+	if strings.HasPrefix(hosts, KNOWN_HOSTS_HASH_MAGIC) {
+		ent.salt, ent.hash, ent.Err = parseHashedHost(hosts)
+	} else {
+		ent.Patterns = strings.Split(hosts, ",")
+	}
+	if ent.Err == nil {
+		ent.Key, ent.Comment, ent.Err = parseKeyFields(text[end:])
+	}
+	if ent.Err == IllFormedAuthorizedKey {
+		ent.Err = IllFormedKnownHost
+	}
+	return
+}
+
+// Split |1|salt|hash into its salt and hash.
+func parseHashedHost(hosts string) (salt, hash []byte, err error) {
+	parts := strings.Split(hosts[len(KNOWN_HOSTS_HASH_MAGIC):], "|")
+	if len(parts) != 2 {
+		err = IllFormedKnownHost
+	} else if salt, err = base64.StdEncoding.DecodeString(parts[0]); err != nil {
+		err = IllFormedKnownHost
+	} else if hash, err = base64.StdEncoding.DecodeString(parts[1]); err != nil ||
+		len(hash) != sha1.Size {
+
+		salt, hash, err = nil, nil, IllFormedKnownHost
+	}
+	return
+}
+
+func hashHostname(salt []byte, name string) []byte {
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return mac.Sum(nil)
+}
+
+// Return the name under which a host appears in known_hosts: the host
+// itself for the default port, or [host]:port for any other.
+func KnownHostsName(host string, port int) string {
+	if port == 0 || port == SSH_DEFAULT_PORT {
+		return host
+	}
+	return "[" + host + "]:" + strconv.Itoa(port)
+}
+
+// Return the hashed form of a host name, as ssh-keygen -H writes it,
+// using a random salt.
+func HashKnownHost(host string, port int) (hashed string, err error) {
+	salt := make([]byte, sha1.Size)
+	_, err = io.ReadFull(rand.Reader, salt)
+	if err == nil {
+		name := strings.ToLower(KnownHostsName(host, port))
+		hashed = KNOWN_HOSTS_HASH_MAGIC +
+			base64.StdEncoding.EncodeToString(salt) + "|" +
+			base64.StdEncoding.EncodeToString(hashHostname(salt, name))
+	}
+	return
+}
+
+// LOOKUP ///////////////////////////////////////////////////////////
+
+// Check the key presented by a host as ssh does.  A key revoked for
+// the host gives HOST_KEY_REVOKED whatever else the file says.
+// Otherwise the result is HOST_KEY_MATCH if the key is listed for the
+// host, HOST_KEY_MISMATCH if a different key of the same type is, and
+// HOST_KEY_UNKNOWN if the host has no key of that type.  The entry
+// which decided the result is returned for HOST_KEY_MATCH,
+// HOST_KEY_MISMATCH, and HOST_KEY_REVOKED.  @cert-authority entries
+// are not considered; see CertAuthorities.
+func (kh *KnownHosts) Lookup(host string, port int, key cr.PublicKey) (
+	status HostKeyStatus, ent *KnownHostsEntry, err error) {
+
+	var (
+		sshKey   ssh.PublicKey
+		blob     []byte
+		mismatch *KnownHostsEntry
+	)
+	if key == nil {
+		err = NilPublicKey
+	} else if sshKey, err = ssh.NewPublicKey(key); err == nil {
+		blob = sshKey.Marshal()
+	}
+	if err != nil {
+		return
+	}
+	name := KnownHostsName(host, port)
+	for _, e := range kh.Entries {
+		if e.Marker == KNOWN_HOSTS_CERT_AUTHORITY || !e.MatchesHost(name) {
+			continue
+		}
+		same := sameKey(e.Key, blob)
+		if e.Marker == KNOWN_HOSTS_REVOKED {
+			if same {
+				return HOST_KEY_REVOKED, e, nil
+			}
+		} else if same {
+			if ent == nil {
+				ent = e
+			}
+		} else if mismatch == nil && sameKeyType(e.Key, sshKey.Type()) {
+			mismatch = e
+		}
+	}
+	if ent != nil {
+		status = HOST_KEY_MATCH
+	} else if mismatch != nil {
+		status, ent = HOST_KEY_MISMATCH, mismatch
+	}
+	return
+}
+
+// Return the keys of the certificate authorities trusted to sign host
+// certificates for the host.
+func (kh *KnownHosts) CertAuthorities(host string, port int) (
+	keys []cr.PublicKey) {
+
+	name := KnownHostsName(host, port)
+	for _, e := range kh.Entries {
+		if e.Marker == KNOWN_HOSTS_CERT_AUTHORITY && e.MatchesHost(name) {
+			keys = append(keys, e.Key)
+		}
+	}
+	return
+}
+
+func sameKeyType(key cr.PublicKey, keyType string) bool {
+	blob, err := sshKeyBlob(key)
+	if err == nil {
+		algo, _, ok := ParseLenHeadedString(blob)
+		return ok && bytes.Equal(algo, []byte(keyType))
+	}
+	return false
+}
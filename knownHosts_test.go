@@ -0,0 +1,148 @@
+package crypto
+
+// xlCrypto_go/knownHosts_test.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	. "gopkg.in/check.v1"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SSH_KEYGEN_ED25519_PUB listed for host1.example.com on ports 22 and
+// 2222, hashed by ssh-keygen -H.
+const SSH_KEYGEN_HASHED_HOSTS = `|1|6m0NNcR1SvWK0RICXcLcGf/XulQ=|URrUsQVDNSfTdCDxjU09bIbxcJ8= ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAINK3GUJ87RUSA0wh4A5DFgXRqss3kerl7fnvGal49kqs
+|1|3l63jYG4OMODOwakjxsyX6CY8tI=|L7zFMJnNG15aZSO3OvwiqCaZu8s= ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAINK3GUJ87RUSA0wh4A5DFgXRqss3kerl7fnvGal49kqs
+`
+
+func (s *XLSuite) newEd25519Pub(c *C) ed25519.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	return pub
+}
+
+func (s *XLSuite) TestKnownHosts(c *C) {
+	hostKey := s.newEd25519Pub(c)
+	otherKey := s.newEd25519Pub(c)
+	revokedKey := s.newEd25519Pub(c)
+	caKey := s.newEd25519Pub(c)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	ecKey := &ecPriv.PublicKey
+
+	hashed, err := HashKnownHost("Hidden.Example.COM", 2022)
+	c.Assert(err, IsNil)
+	lines := []string{
+		"# comment",
+		"build.example.com,10.0.0.5 " + s.makeDiskKey(c, hostKey) + " build",
+		"*.example.com,!evil.example.com " + s.makeDiskKey(c, otherKey),
+		"[git.example.com]:2222 " + s.makeDiskKey(c, hostKey),
+		hashed + " " + s.makeDiskKey(c, hostKey),
+		"@revoked * " + s.makeDiskKey(c, revokedKey),
+		"@cert-authority *.example.com " + s.makeDiskKey(c, caKey),
+		"@bogus host " + s.makeDiskKey(c, hostKey),
+		"|1|notbase64|x " + s.makeDiskKey(c, hostKey),
+	}
+	kh := ParseKnownHosts([]byte(strings.Join(lines, "\n")))
+	c.Assert(len(kh.Entries), Equals, len(lines)-1)
+	c.Assert(kh.Entries[0].LineNo, Equals, 2)
+	c.Assert(kh.Entries[0].Comment, Equals, "build")
+	c.Assert(kh.Entries[3].IsHashed(), Equals, true)
+	c.Assert(kh.Entries[6].Err, Equals, IllFormedKnownHost)
+	c.Assert(kh.Entries[7].Err, Equals, IllFormedKnownHost)
+
+	check := func(host string, port int, key cr.PublicKey,
+		expected HostKeyStatus) {
+
+		status, _, err := kh.Lookup(host, port, key)
+		c.Assert(err, IsNil)
+		c.Assert(status, Equals, expected,
+			Commentf("%s:%d", host, port))
+	}
+	check("build.example.com", 22, hostKey, HOST_KEY_MATCH)
+	check("BUILD.example.com", 0, hostKey, HOST_KEY_MATCH)
+	check("10.0.0.5", 22, hostKey, HOST_KEY_MATCH)
+	// the wildcard line also covers build.example.com, with another key
+	check("build.example.com", 22, s.newEd25519Pub(c), HOST_KEY_MISMATCH)
+	check("www.example.com", 22, otherKey, HOST_KEY_MATCH)
+	check("www.example.com", 22, hostKey, HOST_KEY_MISMATCH)
+	// no key of this type is known
+	check("www.example.com", 22, ecKey, HOST_KEY_UNKNOWN)
+	// negated and unlisted hosts
+	check("evil.example.com", 22, otherKey, HOST_KEY_UNKNOWN)
+	check("example.org", 22, otherKey, HOST_KEY_UNKNOWN)
+	// ports
+	check("git.example.com", 2222, hostKey, HOST_KEY_MATCH)
+	check("git.example.com", 22, hostKey, HOST_KEY_MISMATCH)
+	check("hidden.example.com", 2022, hostKey, HOST_KEY_MATCH)
+	check("hidden.example.com", 22, hostKey, HOST_KEY_MISMATCH)
+	// revocation beats everything
+	check("www.example.com", 22, revokedKey, HOST_KEY_REVOKED)
+	// CA keys are not host keys
+	check("x.example.org", 22, caKey, HOST_KEY_UNKNOWN)
+
+	cas := kh.CertAuthorities("www.example.com", 22)
+	c.Assert(len(cas), Equals, 1)
+	c.Assert(cas[0].(ed25519.PublicKey).Equal(caKey), Equals, true)
+	c.Assert(kh.CertAuthorities("example.org", 22), IsNil)
+
+	_, _, err = kh.Lookup("www.example.com", 22, nil)
+	c.Assert(err, Equals, NilPublicKey)
+	c.Assert(HOST_KEY_MISMATCH.String(), Equals, "mismatch")
+}
+
+func (s *XLSuite) TestSSHKeygenHashedHosts(c *C) {
+	kh := ParseKnownHosts([]byte(SSH_KEYGEN_HASHED_HOSTS))
+	key, err := PubKeyFromDisk([]byte(SSH_KEYGEN_ED25519_PUB))
+	c.Assert(err, IsNil)
+
+	for _, port := range []int{22, 2222} {
+		status, ent, err := kh.Lookup("host1.example.com", port, key)
+		c.Assert(err, IsNil)
+		c.Assert(status, Equals, HOST_KEY_MATCH)
+		c.Assert(ent.IsHashed(), Equals, true)
+	}
+	status, _, err := kh.Lookup("host1.example.com", 2200, key)
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, HOST_KEY_UNKNOWN)
+}
+
+func (s *XLSuite) TestKnownHostsPackage(c *C) {
+	hostKey := s.newEd25519Pub(c)
+	sshKey, err := ssh.NewPublicKey(hostKey)
+	c.Assert(err, IsNil)
+	hashed, err := HashKnownHost("host1.example.com", 2222)
+	c.Assert(err, IsNil)
+	good := hashed + " " + s.makeDiskKey(c, hostKey) + "\n"
+	bad := "host2.example.com ssh-ed25519 not-base64\n"
+
+	// the knownhosts package reads our hashed names
+	dir := c.MkDir()
+	path := filepath.Join(dir, "known_hosts")
+	c.Assert(os.WriteFile(path, []byte(good), 0644), IsNil)
+	callback, err := knownhosts.New(path)
+	c.Assert(err, IsNil)
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+	c.Assert(callback("host1.example.com:2222", addr, sshKey), IsNil)
+
+	// but gives up on the file if a line is bad, where we skip the line
+	badPath := filepath.Join(dir, "bad_known_hosts")
+	c.Assert(os.WriteFile(badPath, []byte(bad+good), 0644), IsNil)
+	_, err = knownhosts.New(badPath)
+	c.Assert(err, NotNil)
+	kh, err := ReadKnownHosts(badPath)
+	c.Assert(err, IsNil)
+	c.Assert(kh.Entries[0].Err, NotNil)
+	status, ent, err := kh.Lookup("host1.example.com", 2222, hostKey)
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, HOST_KEY_MATCH)
+	c.Assert(ent.LineNo, Equals, 2)
+}
@@ -0,0 +1,473 @@
+package crypto
+
+// xlCrypto_go/loadKey.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"unicode/utf8"
+)
+
+// Loading keys whose format is not known in advance.  LoadPublicKey and
+// LoadPrivateKey accept any of the forms the library reads:
+//
+//   - DER: PKIX or PKCS#1 public keys; PKCS#1, SEC1, PKCS#8, or
+//     encrypted PKCS#8 private keys
+//   - the same DER base64-encoded, possibly over several lines
+//   - PEM, possibly indented or embedded in a larger document, of any
+//     of the above, the OpenSSH private key format, or our own
+//     encrypted format
+//   - a line in the format of authorized_keys or an OpenSSH *.pub or
+//     *-cert.pub file, or the same key in SSH wire format
+//   - a JSON Web Key, or a JWK Set holding one key
+//
+// Each returns the key together with a description of the format
+// found.  The description is also returned with most errors, so that
+// a caller can say, for example, that a file holds an encrypted PKCS#8
+// RSA key when the passphrase is missing.
+//
+// An OpenSSH certificate is recognized but its key is not returned:
+// the key is only to be trusted once the certificate's signature,
+// validity and principals have been checked, so the error is
+// UnexpectedCertificate.  Such data should be read with
+// ParseSSHCertificate or SSHCertificateFromDisk and checked with
+// SSHCertificate.Validate.
+
+const (
+	// encodings
+	KEY_ENCODING_DER             = "DER"
+	KEY_ENCODING_BASE64_DER      = "base64 DER"
+	KEY_ENCODING_PEM             = "PEM"
+	KEY_ENCODING_SSH             = "SSH"
+	KEY_ENCODING_SSH_WIRE        = "SSH wire"
+	KEY_ENCODING_BASE64_SSH_WIRE = "base64 SSH wire"
+	KEY_ENCODING_JSON            = "JSON"
+
+	// syntaxes
+	KEY_SYNTAX_PKIX     = "PKIX"
+	KEY_SYNTAX_PKCS1    = "PKCS#1"
+	KEY_SYNTAX_PKCS8    = "PKCS#8"
+	KEY_SYNTAX_SEC1     = "SEC1"
+	KEY_SYNTAX_OPENSSH  = "OpenSSH"
+	KEY_SYNTAX_SSH      = "RFC 4253"
+	KEY_SYNTAX_SSH_CERT = "OpenSSH certificate"
+	KEY_SYNTAX_JWK      = "JWK"
+
+	// encryption schemes
+	KEY_ENCRYPTION_PBES2   = "PBES2"
+	KEY_ENCRYPTION_SCRYPT  = "scrypt/AES-256-GCM"
+	KEY_ENCRYPTION_OPENSSL = "OpenSSL legacy"
+	KEY_ENCRYPTION_BCRYPT  = "bcrypt-pbkdf"
+)
+
+// the suffix of the type names of OpenSSH certificates
+const sshCertSuffix = "-cert-v01@openssh.com"
+
+// A description of the format in which a key was found.
+type KeyFormat struct {
+	Encoding   string // one of the KEY_ENCODING_* values
+	Syntax     string // one of the KEY_SYNTAX_* values; empty if unknown
+	KeyType    string // "RSA", "ECDSA", or "Ed25519"; empty if unknown
+	Private    bool
+	Encryption string // one of the KEY_ENCRYPTION_* values, or empty
+	PEMType    string // the PEM block type, if the encoding is PEM
+	Comment    string // the SSH comment, if any
+}
+
+// Describe the format, for example "PEM PKCS#8 RSA private key,
+// encrypted with PBES2".
+func (f *KeyFormat) String() string {
+	parts := []string{f.Encoding}
+	if f.Syntax != "" {
+		parts = append(parts, f.Syntax)
+	}
+	if f.KeyType != "" {
+		parts = append(parts, f.KeyType)
+	}
+	if f.Private {
+		parts = append(parts, "private key")
+	} else {
+		parts = append(parts, "public key")
+	}
+	s := strings.Join(parts, " ")
+	if f.Encryption != "" {
+		s += ", encrypted with " + f.Encryption
+	}
+	return s
+}
+
+// Load a public key in any supported format.  If the data holds a
+// private key, the error is UnexpectedPrivateKey; if it holds more than
+// one key, AmbiguousKeyData.  The key returned is an *rsa.PublicKey, an
+// ed25519.PublicKey, or an *ecdsa.PublicKey.
+func LoadPublicKey(data []byte) (
+	key cr.PublicKey, format *KeyFormat, err error) {
+
+	k, format, err := loadKey(data, nil)
+	if format != nil && format.Private {
+		err = UnexpectedPrivateKey
+	} else if err == nil {
+		key = k
+	}
+	return
+}
+
+// Load a private key in any supported format, decrypting it with the
+// passphrase if it is encrypted.  The passphrase is ignored if it is
+// not.  If the data holds a public key, the error is
+// UnexpectedPublicKey; if the key is encrypted and the passphrase is
+// empty, PassphraseRequired.  The key returned is an *rsa.PrivateKey, an
+// ed25519.PrivateKey, or an *ecdsa.PrivateKey.
+func LoadPrivateKey(data, passphrase []byte) (
+	key cr.PrivateKey, format *KeyFormat, err error) {
+
+	k, format, err := loadKey(data, passphrase)
+	if format != nil && !format.Private {
+		err = UnexpectedPublicKey
+	} else if err == nil {
+		key = k
+	}
+	return
+}
+
+// Work out the format of the data and load the key it holds, which is
+// either public or private according to format.Private.
+func loadKey(data, passphrase []byte) (
+	key interface{}, format *KeyFormat, err error) {
+
+	text := bytes.TrimSpace(data)
+	if len(text) == 0 {
+		err = NilData
+	} else if bytes.Contains(text, []byte("-----BEGIN ")) {
+		key, format, err = loadPEMKey(text, passphrase)
+	} else if !isKeyText(text) {
+		key, format, err = loadBinaryKey(data, passphrase)
+	} else if text[0] == '{' {
+		key, format, err = loadJWKKey(text)
+	} else {
+		key, format, err = loadTextKey(string(text), passphrase)
+	}
+	if err == nil && format != nil && format.KeyType == "" {
+		format.KeyType = keyTypeName(key)
+	}
+	if err != nil {
+		key = nil
+	}
+	return
+}
+
+// Whether the data looks like text rather than binary.
+func isKeyText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, c := range data {
+		if c < ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// PEM //////////////////////////////////////////////////////////////
+
+// Load a key from the one key-bearing PEM block in the data.  Other
+// blocks, certificates for example, are ignored.
+func loadPEMKey(data, passphrase []byte) (
+	key interface{}, format *KeyFormat, err error) {
+
+	// PEM lines may be indented, as in YAML and similar config files
+	lines := strings.Split(string(data), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	rest := []byte(strings.Join(lines, "\n"))
+
+	var (
+		keyBlocks []*pem.Block
+		nBlocks   int
+	)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		nBlocks++
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") ||
+			strings.HasSuffix(block.Type, "PUBLIC KEY") || block.Type == "" {
+
+			keyBlocks = append(keyBlocks, block)
+		}
+	}
+	if nBlocks == 0 {
+		return nil, nil, PemEncodeDecodeFailure
+	} else if len(keyBlocks) == 0 {
+		return nil, nil, UnsupportedPEMType
+	} else if len(keyBlocks) > 1 {
+		return nil, nil, AmbiguousKeyData
+	}
+	block := keyBlocks[0]
+	format = &KeyFormat{Encoding: KEY_ENCODING_PEM, PEMType: block.Type}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		format.Syntax = KEY_SYNTAX_PKIX
+		key, err = PubKeyFromWire(block.Bytes)
+	case "RSA PUBLIC KEY":
+		format.Syntax = KEY_SYNTAX_PKCS1
+		if key, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+			err = X509ParseOrMarshalError
+		}
+	case OPENSSH_PEM_TYPE:
+		key, err = loadOpenSSHKey(block, passphrase, format)
+	case "", "RSA PRIVATE KEY", "EC PRIVATE KEY", PKCS8_PEM_TYPE,
+		ENCRYPTED_PKCS8_PEM_TYPE, ENCRYPTED_PEM_TYPE:
+
+		format.Private = true
+		der := block.Bytes
+		if IsEncryptedPEMBlock(block) {
+			switch block.Type {
+			case ENCRYPTED_PEM_TYPE:
+				format.Encryption = KEY_ENCRYPTION_SCRYPT
+			case ENCRYPTED_PKCS8_PEM_TYPE:
+				format.Encryption = KEY_ENCRYPTION_PBES2
+			default:
+				format.Encryption = KEY_ENCRYPTION_OPENSSL
+			}
+			if len(passphrase) == 0 {
+				err = PassphraseRequired
+			} else {
+				_, der, err = DecryptPEMBlock(block, passphrase)
+			}
+		}
+		if err == nil {
+			key, err = loadPrivateKeyDER(der, format)
+		}
+	default:
+		format.Private = strings.HasSuffix(block.Type, "PRIVATE KEY")
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Load a key from an OpenSSH private key file's PEM block.
+func loadOpenSSHKey(block *pem.Block, passphrase []byte,
+	format *KeyFormat) (key interface{}, err error) {
+
+	var file *openSSHKeyFile
+	format.Syntax, format.Private = KEY_SYNTAX_OPENSSH, true
+	if file, err = parseOpenSSHKeyFile(block); err == nil &&
+		file.CipherName != "none" {
+
+		format.Encryption = KEY_ENCRYPTION_BCRYPT
+		if len(passphrase) == 0 {
+			err = PassphraseRequired
+		}
+	}
+	if err == nil {
+		key, format.Comment, err = PrivateKeyFromOpenSSHWithPassphrase(
+			pem.EncodeToMemory(block), passphrase)
+	}
+	return
+}
+
+// BINARY ///////////////////////////////////////////////////////////
+
+// Load a key from DER or SSH wire format.
+func loadBinaryKey(data, passphrase []byte) (
+	key interface{}, format *KeyFormat, err error) {
+
+	if len(data) > 4 && data[0] == 0 {
+		// SSH wire format begins with the length of the key type
+		format = &KeyFormat{Encoding: KEY_ENCODING_SSH_WIRE}
+		key, err = loadSSHWireKey(data, format)
+		return
+	}
+	format = &KeyFormat{Encoding: KEY_ENCODING_DER}
+	var epki encryptedPrivateKeyInfo
+	if pub, e := x509.ParsePKIXPublicKey(data); e == nil {
+		format.Syntax = KEY_SYNTAX_PKIX
+		format.KeyType = keyTypeName(pub)
+		key, err = PubKeyFromWire(data)
+	} else if pub, e := x509.ParsePKCS1PublicKey(data); e == nil {
+		format.Syntax = KEY_SYNTAX_PKCS1
+		key = pub
+	} else if unmarshalAll(data, &epki) == nil {
+		format.Syntax, format.Private = KEY_SYNTAX_PKCS8, true
+		format.Encryption = KEY_ENCRYPTION_PBES2
+		if len(passphrase) == 0 {
+			err = PassphraseRequired
+		} else {
+			var der []byte
+			if der, err = decryptPKCS8(data, passphrase); err == nil {
+				key, err = loadPrivateKeyDER(der, format)
+			}
+		}
+	} else {
+		format.Private = true
+		key, err = loadPrivateKeyDER(data, format)
+		if err == UnknownKeyFormat {
+			format = nil
+		}
+	}
+	return
+}
+
+// Load a private key from PKCS#8, PKCS#1, or SEC1 DER, recording the
+// syntax found.
+func loadPrivateKeyDER(der []byte, format *KeyFormat) (
+	key interface{}, err error) {
+
+	if k, e := x509.ParsePKCS8PrivateKey(der); e == nil {
+		format.Syntax = KEY_SYNTAX_PKCS8
+		format.KeyType = keyTypeName(k)
+		key, err = PrivateKeyFromPKCS8Wire(der)
+	} else if k, e := x509.ParsePKCS1PrivateKey(der); e == nil {
+		format.Syntax = KEY_SYNTAX_PKCS1
+		key = k
+	} else if k, e := x509.ParseECPrivateKey(der); e == nil {
+		format.Syntax, format.KeyType = KEY_SYNTAX_SEC1, keyTypeName(k)
+		if err = checkECDSACurve(k.Curve); err == nil {
+			key = k
+		}
+	} else {
+		err = UnknownKeyFormat
+	}
+	return
+}
+
+// Load a key in SSH wire format.  A certificate is described in the
+// format but refused.
+func loadSSHWireKey(data []byte, format *KeyFormat) (
+	key interface{}, err error) {
+
+	algo, _, ok := ParseLenHeadedString(data)
+	if !ok {
+		return nil, UnknownKeyFormat
+	}
+	if strings.HasSuffix(string(algo), sshCertSuffix) {
+		format.Syntax = KEY_SYNTAX_SSH_CERT
+		var sc *SSHCertificate
+		if sc, err = ParseSSHCertificate(data); err == nil {
+			format.KeyType = keyTypeName(sc.Key)
+			err = UnexpectedCertificate
+		}
+	} else {
+		format.Syntax = KEY_SYNTAX_SSH
+		var rest []byte
+		if key, rest, ok = ParseSSHPublicKey(data); !ok {
+			key, err = nil, UnsupportedKeyType
+		} else if len(rest) > 0 {
+			key, err = nil, UnknownKeyFormat
+		}
+	}
+	return
+}
+
+// TEXT /////////////////////////////////////////////////////////////
+
+// Load a key from an SSH public key line, or from base64-encoded DER
+// or SSH wire format, possibly broken over several lines.
+func loadTextKey(text string, passphrase []byte) (
+	key interface{}, format *KeyFormat, err error) {
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && line[0] != '#' {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, nil, NilData
+	}
+	if len(strings.Fields(lines[0])) == 1 {
+		// base64 has no spaces; SSH lines have at least two fields
+		var data []byte
+		data, err = base64.StdEncoding.DecodeString(strings.Join(lines, ""))
+		if err != nil || len(data) == 0 {
+			return nil, nil, UnknownKeyFormat
+		}
+		key, format, err = loadBinaryKey(data, passphrase)
+		if format != nil {
+			format.Encoding = "base64 " + format.Encoding
+		}
+		return
+	}
+	if len(lines) > 1 {
+		return nil, nil, AmbiguousKeyData
+	}
+	return loadSSHLineKey(lines[0])
+}
+
+// Load a key from a line in authorized_keys or *.pub format, with or
+// without options.  A certificate in *-cert.pub format is described in
+// the format but refused.
+func loadSSHLineKey(line string) (
+	key interface{}, format *KeyFormat, err error) {
+
+	fields := strings.Fields(line)
+	if !strings.Contains(line, "ssh-") && !strings.Contains(line, "ecdsa-") {
+		return nil, nil, UnknownKeyFormat
+	}
+	format = &KeyFormat{Encoding: KEY_ENCODING_SSH, Syntax: KEY_SYNTAX_SSH}
+	if strings.HasSuffix(fields[0], sshCertSuffix) {
+		var sc *SSHCertificate
+		format.Syntax = KEY_SYNTAX_SSH_CERT
+		if sc, format.Comment, err = SSHCertificateFromDisk([]byte(line)); err == nil {
+			format.KeyType = keyTypeName(sc.Key)
+			err = UnexpectedCertificate
+		}
+		return
+	}
+	ent := parseAuthorizedKeysLine(line)
+	key, format.Comment, err = ent.Key, ent.Comment, ent.Err
+	return
+}
+
+// JSON /////////////////////////////////////////////////////////////
+
+// Load a key from a JWK or a JWK Set holding exactly one key.
+func loadJWKKey(data []byte) (
+	key interface{}, format *KeyFormat, err error) {
+
+	var j *JWK
+	if set, e := ParseJWKSet(data); e == nil {
+		if len(set.Keys) != 1 {
+			return nil, nil, AmbiguousKeyData
+		}
+		j = set.Keys[0]
+	} else if j, err = ParseJWK(data); err != nil {
+		return
+	}
+	format = &KeyFormat{Encoding: KEY_ENCODING_JSON, Syntax: KEY_SYNTAX_JWK,
+		Private: j.IsPrivate()}
+	if format.Private {
+		key, err = j.PrivateKey()
+	} else {
+		key, err = j.PublicKey()
+	}
+	return
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Return the name of the type of a public or private key.
+func keyTypeName(key interface{}) string {
+	switch key.(type) {
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return "RSA"
+	case *ecdsa.PublicKey, *ecdsa.PrivateKey:
+		return "ECDSA"
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return "Ed25519"
+	}
+	return ""
+}
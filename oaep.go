@@ -0,0 +1,110 @@
+package crypto
+
+// xlCrypto_go/oaep.go
+
+import (
+	cr "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+)
+
+// RSA-OAEP ENCRYPTION //////////////////////////////////////////////
+
+// Encrypt a short message under the RSA public key using OAEP (RFC
+// 8017, section 7.1).  The hash, which must be one of SIG_DIGESTS, is
+// used both for the label and in the mask generation function.  The
+// label may be nil; if it is not, the same label must be used in
+// decrypting.
+func OAEPEncrypt(pubKey *rsa.PublicKey, h cr.Hash, label, msg []byte) (
+	ciphertext []byte, err error) {
+
+	if pubKey == nil {
+		err = NilPublicKey
+	} else if msg == nil {
+		err = NilData
+	} else if err = CheckSigDigest(h); err == nil {
+		ciphertext, err = rsa.EncryptOAEP(h.New(), rand.Reader,
+			pubKey, msg, label)
+	}
+	return
+}
+
+// Decrypt a message encrypted with OAEPEncrypt, using the same hash
+// and label.
+func OAEPDecrypt(privKey *rsa.PrivateKey, h cr.Hash, label, ciphertext []byte) (
+	msg []byte, err error) {
+
+	if privKey == nil {
+		err = NilPrivateKey
+	} else if ciphertext == nil {
+		err = NilData
+	} else if err = CheckSigDigest(h); err == nil {
+		msg, err = rsa.DecryptOAEP(h.New(), rand.Reader,
+			privKey, ciphertext, label)
+	}
+	return
+}
+
+// SESSION KEY WRAPPING /////////////////////////////////////////////
+
+// Session keys are AES keys wrapped with RSA-OAEP/SHA-256 and the
+// label below, so that a wrapped session key cannot be mistaken for
+// any other OAEP-encrypted message.
+
+const (
+	SESSION_KEY_LEN = 32 // AES-256
+)
+
+var (
+	SESSION_KEY_HASH  = cr.SHA256
+	SESSION_KEY_LABEL = []byte("xlCrypto_go session key")
+)
+
+// Wrap an AES key, which must be 16, 24, or 32 bytes long, under the
+// RSA public key.
+func WrapSessionKey(pubKey *rsa.PublicKey, key []byte) (
+	wrapped []byte, err error) {
+
+	if !isAESKeySize(len(key)) {
+		err = BadAESKeySize
+	} else {
+		wrapped, err = OAEPEncrypt(pubKey, SESSION_KEY_HASH,
+			SESSION_KEY_LABEL, key)
+	}
+	return
+}
+
+// Unwrap a session key wrapped with WrapSessionKey.
+func UnwrapSessionKey(privKey *rsa.PrivateKey, wrapped []byte) (
+	key []byte, err error) {
+
+	key, err = OAEPDecrypt(privKey, SESSION_KEY_HASH,
+		SESSION_KEY_LABEL, wrapped)
+	if err == nil && !isAESKeySize(len(key)) {
+		key, err = nil, BadAESKeySize
+	}
+	return
+}
+
+// Generate a random SESSION_KEY_LEN-byte AES key and wrap it under the
+// RSA public key.  The sender keeps the key and sends the wrapped form
+// to the holder of the private key.
+func NewSessionKey(pubKey *rsa.PublicKey) (key, wrapped []byte, err error) {
+	key = make([]byte, SESSION_KEY_LEN)
+	_, err = io.ReadFull(rand.Reader, key)
+	if err == nil {
+		wrapped, err = WrapSessionKey(pubKey, key)
+	}
+	if err != nil {
+		key = nil
+	}
+	return
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Return whether n is a valid AES key length.
+func isAESKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
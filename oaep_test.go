@@ -0,0 +1,101 @@
+package crypto
+
+// xlCrypto_go/oaep_test.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+)
+
+func (s *XLSuite) TestOAEP(c *C) {
+	rng := xr.MakeSimpleRNG()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+
+	msg := make([]byte, 32)
+	rng.NextBytes(msg)
+	label := []byte("some label")
+
+	for _, h := range []cr.Hash{cr.SHA1, cr.SHA256} {
+		ciphertext, err := OAEPEncrypt(&key.PublicKey, h, label, msg)
+		c.Assert(err, IsNil)
+		c.Assert(len(ciphertext), Equals, key.Size())
+
+		reply, err := OAEPDecrypt(key, h, label, ciphertext)
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(reply, msg), Equals, true)
+
+		// label and hash must match
+		_, err = OAEPDecrypt(key, h, nil, ciphertext)
+		c.Assert(err, NotNil)
+		_, err = OAEPDecrypt(key, cr.SHA512, label, ciphertext)
+		c.Assert(err, NotNil)
+	}
+	_, err = OAEPEncrypt(nil, cr.SHA256, nil, msg)
+	c.Assert(err, Equals, NilPublicKey)
+	_, err = OAEPEncrypt(&key.PublicKey, cr.MD5, nil, msg)
+	c.Assert(err, Equals, UnsupportedDigest)
+}
+
+// Simulate the handshake: the client loads the server's public key,
+// generates and wraps a session key, and sends the wrapped key and a
+// message encrypted with the session key.  The server unwraps the
+// key and decrypts the message.
+func (s *XLSuite) TestSessionKeyHandshake(c *C) {
+	rng := xr.MakeSimpleRNG()
+	serverKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+
+	pemPubKey, err := RSAPubKeyToPEM(&serverKey.PublicKey)
+	c.Assert(err, IsNil)
+	sshPubKey, err := RSAPubKeyToDisk(&serverKey.PublicKey)
+	c.Assert(err, IsNil)
+
+	fromPEM, err := RSAPubKeyFromPEM(pemPubKey)
+	c.Assert(err, IsNil)
+	fromDisk, err := RSAPubKeyFromDisk(sshPubKey)
+	c.Assert(err, IsNil)
+
+	for _, pubKey := range []*rsa.PublicKey{fromPEM, fromDisk} {
+		sessionKey, wrapped, err := NewSessionKey(pubKey)
+		c.Assert(err, IsNil)
+		c.Assert(len(sessionKey), Equals, SESSION_KEY_LEN)
+
+		msg := make([]byte, rng.Intn(1024))
+		rng.NextBytes(msg)
+		ciphertext, err := AESCBCEncrypt(sessionKey, msg)
+		c.Assert(err, IsNil)
+
+		// server side
+		unwrapped, err := UnwrapSessionKey(serverKey, wrapped)
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(unwrapped, sessionKey), Equals, true)
+		reply, err := AESCBCDecrypt(unwrapped, ciphertext)
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(reply, msg), Equals, true)
+	}
+
+	// AES-128 keys can be wrapped too, but not keys of other sizes
+	aesKey := make([]byte, 16)
+	rng.NextBytes(aesKey)
+	wrapped, err := WrapSessionKey(&serverKey.PublicKey, aesKey)
+	c.Assert(err, IsNil)
+	unwrapped, err := UnwrapSessionKey(serverKey, wrapped)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(unwrapped, aesKey), Equals, true)
+
+	_, err = WrapSessionKey(&serverKey.PublicKey, aesKey[:10])
+	c.Assert(err, Equals, BadAESKeySize)
+
+	// a message OAEP-encrypted without the session key label is not
+	// accepted as a session key
+	other, err := OAEPEncrypt(&serverKey.PublicKey, SESSION_KEY_HASH,
+		nil, aesKey)
+	c.Assert(err, IsNil)
+	_, err = UnwrapSessionKey(serverKey, other)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,304 @@
+package crypto
+
+// xlCrypto_go/openssh.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// OpenSSH private key files, the format written by ssh-keygen since
+// OpenSSH 7.8, as described in PROTOCOL.key in the OpenSSH sources.
+// The PEM block, of type "OPENSSH PRIVATE KEY", contains
+//
+//     "openssh-key-v1\0"
+//     string  cipher name          "none", or for example "aes256-ctr"
+//     string  KDF name             "none" or "bcrypt"
+//     string  KDF options          for bcrypt, string salt, uint32 rounds
+//     uint32  number of keys       always 1 here
+//     string  public key           in SSH wire format
+//     string  private section      encrypted unless the cipher is "none"
+//
+// where the private section holds two copies of a random check value,
+// the private key, the comment, and padding to the cipher's block size.
+//
+// The keys themselves are read and written by the ssh package, which
+// encrypts with aes256-ctr and bcrypt-pbkdf as ssh-keygen does.  The
+// comment is in the private section, which the ssh package does not
+// return, so once it has accepted the passphrase the section is
+// decrypted again here to read the comment.
+//
+// RSA, Ed25519, and ECDSA P-256 and P-384 keys are supported.
+
+const (
+	OPENSSH_PEM_TYPE  = "OPENSSH PRIVATE KEY"
+	OPENSSH_KEY_MAGIC = "openssh-key-v1\x00"
+)
+
+type openSSHKeyFile struct {
+	CipherName string
+	KDFName    string
+	KDFOpts    []byte
+	NumKeys    uint32
+	PubKey     []byte
+	PrivBlock  []byte
+}
+
+type openSSHKDFOpts struct {
+	Salt   []byte
+	Rounds uint32
+}
+
+type openSSHPrivSection struct {
+	Check1  uint32
+	Check2  uint32
+	KeyType string
+	Rest    []byte `ssh:"rest"`
+}
+
+// the number of fields preceding the comment in the private section
+var openSSHKeyFields = map[string]int{
+	ssh.KeyAlgoRSA:      6, // n, e, d, iqmp, p, q
+	ssh.KeyAlgoED25519:  2, // public key, private key
+	ssh.KeyAlgoECDSA256: 3, // curve name, public point, d
+	ssh.KeyAlgoECDSA384: 3,
+}
+
+// SERIALIZATION ////////////////////////////////////////////////////
+
+// Serialize an RSA, Ed25519, or ECDSA private key and its comment to
+// an unencrypted OpenSSH private key file.
+func PrivateKeyToOpenSSH(key cr.PrivateKey, comment string) (
+	data []byte, err error) {
+
+	var block *pem.Block
+	if err = checkOpenSSHKey(key); err == nil {
+		block, err = ssh.MarshalPrivateKey(key, comment)
+	}
+	if err == nil {
+		data = pem.EncodeToMemory(block)
+	}
+	return
+}
+
+// Serialize a private key and its comment to an OpenSSH private key
+// file protected by the passphrase, as ssh-keygen would.
+func PrivateKeyToOpenSSHWithPassphrase(key cr.PrivateKey, comment string,
+	passphrase []byte) (data []byte, err error) {
+
+	var block *pem.Block
+	if len(passphrase) == 0 {
+		err = NilData
+	} else if err = checkOpenSSHKey(key); err == nil {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, comment,
+			passphrase)
+	}
+	if err == nil {
+		data = pem.EncodeToMemory(block)
+	}
+	return
+}
+
+// DESERIALIZATION //////////////////////////////////////////////////
+
+// Deserialize a private key and its comment from an unencrypted
+// OpenSSH private key file.  If the key is encrypted the error is
+// PassphraseRequired.  The key returned is an *rsa.PrivateKey, an
+// ed25519.PrivateKey, or an *ecdsa.PrivateKey.
+func PrivateKeyFromOpenSSH(data []byte) (
+	key cr.PrivateKey, comment string, err error) {
+
+	return PrivateKeyFromOpenSSHWithPassphrase(data, nil)
+}
+
+// Deserialize a private key and its comment from an OpenSSH private
+// key file, which may be protected by the passphrase.  The passphrase
+// is ignored if the key is not encrypted.
+func PrivateKeyFromOpenSSHWithPassphrase(data, passphrase []byte) (
+	key cr.PrivateKey, comment string, err error) {
+
+	var (
+		block *pem.Block
+		file  *openSSHKeyFile
+		raw   interface{}
+	)
+	if data == nil {
+		err = NilData
+	} else if block, _ = pem.Decode(data); block == nil ||
+		block.Type != OPENSSH_PEM_TYPE {
+
+		err = PemEncodeDecodeFailure
+	} else {
+		file, err = parseOpenSSHKeyFile(block)
+	}
+	if err == nil {
+		if file.CipherName == "none" {
+			raw, err = ssh.ParseRawPrivateKey(data)
+			err = openSSHError(err)
+		} else if len(passphrase) == 0 {
+			err = PassphraseRequired
+		} else {
+			raw, err = ssh.ParseRawPrivateKeyWithPassphrase(data, passphrase)
+			err = openSSHError(err)
+		}
+	}
+	if err == nil {
+		if k, ok := raw.(*ed25519.PrivateKey); ok {
+			raw = *k
+		}
+		if err = checkOpenSSHKey(raw); err == nil {
+			key = raw
+		}
+	}
+	if err == nil {
+		// the public key in clear must match the private key
+		var pubKey ssh.PublicKey
+		pubKey, err = ssh.NewPublicKey(key.(cr.Signer).Public())
+		if err == nil && !bytes.Equal(pubKey.Marshal(), file.PubKey) {
+			err = IllFormedOpenSSHKey
+		}
+	}
+	if err == nil {
+		priv := file.PrivBlock
+		if file.CipherName != "none" {
+			priv, err = decryptOpenSSHSection(file, passphrase)
+		}
+		if err == nil {
+			comment, err = openSSHComment(priv)
+		}
+	}
+	if err != nil {
+		key, comment = nil, ""
+	}
+	return
+}
+
+// Unmarshal the outer part of an OpenSSH private key file, as far as
+// the private section.
+func parseOpenSSHKeyFile(block *pem.Block) (
+	file *openSSHKeyFile, err error) {
+
+	file = new(openSSHKeyFile)
+	if !bytes.HasPrefix(block.Bytes, []byte(OPENSSH_KEY_MAGIC)) ||
+		ssh.Unmarshal(block.Bytes[len(OPENSSH_KEY_MAGIC):], file) != nil ||
+		file.NumKeys != 1 {
+
+		file, err = nil, IllFormedOpenSSHKey
+	}
+	return
+}
+
+// Decrypt the private section of a file whose key the ssh package has
+// already decrypted with the passphrase.
+func decryptOpenSSHSection(file *openSSHKeyFile, passphrase []byte) (
+	priv []byte, err error) {
+
+	var (
+		opts  openSSHKDFOpts
+		k     []byte
+		block cipher.Block
+	)
+	if file.KDFName != "bcrypt" || ssh.Unmarshal(file.KDFOpts, &opts) != nil {
+		err = IllFormedOpenSSHKey
+	} else {
+		k, err = bcryptPBKDF(passphrase, opts.Salt, int(opts.Rounds),
+			32+aes.BlockSize)
+	}
+	if err == nil {
+		block, err = aes.NewCipher(k[:32])
+	}
+	if err == nil {
+		iv := k[32:]
+		priv = make([]byte, len(file.PrivBlock))
+		switch {
+		case file.CipherName == "aes256-ctr":
+			cipher.NewCTR(block, iv).XORKeyStream(priv, file.PrivBlock)
+		case file.CipherName == "aes256-cbc" &&
+			len(priv)%aes.BlockSize == 0:
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(priv, file.PrivBlock)
+		default:
+			priv, err = nil, IllFormedOpenSSHKey
+		}
+	}
+	return
+}
+
+// Return the comment from a decrypted private section, which the ssh
+// package has already checked.
+func openSSHComment(priv []byte) (comment string, err error) {
+	var section openSSHPrivSection
+	if ssh.Unmarshal(priv, &section) != nil {
+		err = IllFormedOpenSSHKey
+	} else {
+		rest, ok := section.Rest, true
+		for i := 0; ok && i < openSSHKeyFields[section.KeyType]; i++ {
+			_, rest, ok = ParseLenHeadedString(rest)
+		}
+		var field []byte
+		if ok {
+			field, _, ok = ParseLenHeadedString(rest)
+		}
+		if !ok {
+			err = IllFormedOpenSSHKey
+		} else {
+			comment = string(field)
+		}
+	}
+	return
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Return nil if the key is one which may be kept in an OpenSSH
+// private key file, or an error saying why not.
+func checkOpenSSHKey(key cr.PrivateKey) (err error) {
+	switch k := key.(type) {
+	case nil:
+		err = NilPrivateKey
+	case *rsa.PrivateKey:
+		if k == nil {
+			err = NilPrivateKey
+		} else if len(k.Primes) != 2 {
+			err = UnsupportedKeyType
+		}
+	case ed25519.PrivateKey:
+		if k == nil {
+			err = NilPrivateKey
+		} else if len(k) != ed25519.PrivateKeySize {
+			err = NotAnEd25519PrivateKey
+		}
+	case *ecdsa.PrivateKey:
+		if k == nil {
+			err = NilPrivateKey
+		} else {
+			err = checkECDSACurve(k.Curve)
+		}
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Translate an error from the ssh package into one of ours.
+func openSSHError(sshErr error) (err error) {
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case sshErr == nil:
+	case errors.As(sshErr, &missing):
+		err = PassphraseRequired
+	case sshErr == x509.IncorrectPasswordError:
+		err = BadPassphrase
+	default:
+		err = IllFormedOpenSSHKey
+	}
+	return
+}
@@ -0,0 +1,251 @@
+package crypto
+
+// xlCrypto_go/padding.go
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"io"
+	"math"
+)
+
+// Block padding schemes implementing PaddingI.
+//
+//   PKCS7Scheme     n bytes each of value n (RFC 5652)
+//   X923Scheme      n-1 zero bytes followed by n (ANSI X9.23)
+//   ISO10126Scheme  n-1 random bytes followed by n (ISO 10126-2)
+//   ISO7816Scheme   0x80 followed by zero bytes (ISO/IEC 7816-4, also
+//                   ISO/IEC 9797-1 method 2)
+//   ZeroScheme      zero bytes, none if the message fills its last block
+//
+// With the first three schemes the padding is from 1 to blockSize bytes
+// long and the block size can be at most 255.  ISO/IEC 7816-4 padding
+// is from 1 to blockSize bytes and the block size is unlimited.  Zero
+// padding cannot be told apart from zero bytes at the end of the
+// message, which it strips too; it is only suitable for messages which
+// cannot end in a zero byte, and is provided for legacy formats.  A
+// message of length zero is zero-padded to a full block.
+//
+// Unpadding is done in constant time, except that the length of the
+// data returned reveals the length of the padding, as it must.
+
+var (
+	PKCS7Scheme    PaddingI = pkcs7Scheme{}
+	X923Scheme     PaddingI = x923Scheme{}
+	ISO10126Scheme PaddingI = iso10126Scheme{}
+	ISO7816Scheme  PaddingI = iso7816Scheme{}
+	ZeroScheme     PaddingI = zeroScheme{}
+)
+
+// Return the data with padding appended according to the scheme.  The
+// data passed is not modified.
+func AddPadding(scheme PaddingI, data []byte, blockSize int) (
+	out []byte, err error) {
+
+	var padding []byte
+	if scheme == nil {
+		err = NilPaddingScheme
+	} else if padding, err = scheme.Padding(len(data), blockSize); err == nil {
+		out = make([]byte, len(data)+len(padding))
+		copy(out, data)
+		copy(out[len(data):], padding)
+	}
+	return
+}
+
+// Return the number of bytes, from 1 to blockSize, needed to pad a
+// message of the length given.
+func padLength(length, blockSize int) int {
+	return blockSize - length%blockSize
+}
+
+// Check the block size and that the data is a whole number of blocks.
+func checkPaddedData(data []byte, blockSize, maxBlockSize int) (err error) {
+	if blockSize <= 1 || blockSize > maxBlockSize {
+		err = ImpossibleBlockSize
+	} else if len(data) == 0 || len(data)%blockSize != 0 {
+		err = IncorrectPadding
+	}
+	return
+}
+
+// Strip padding whose last byte is its length, as X9.23 and ISO 10126
+// padding are.  If zeroFill is set the other padding bytes must be
+// zero; otherwise their values are ignored.
+func unpadLengthByte(data []byte, blockSize int, zeroFill bool) (
+	out []byte, err error) {
+
+	if err = checkPaddedData(data, blockSize, 255); err == nil {
+		lenData := len(data)
+		lenPadding := int(data[lenData-1])
+		good := subtle.ConstantTimeLessOrEq(1, lenPadding) &
+			subtle.ConstantTimeLessOrEq(lenPadding, blockSize)
+		if zeroFill {
+			for i := 2; i <= blockSize; i++ {
+				inPadding := subtle.ConstantTimeLessOrEq(i, lenPadding)
+				zero := subtle.ConstantTimeByteEq(data[lenData-i], 0)
+				good &= subtle.ConstantTimeSelect(inPadding, zero, 1)
+			}
+		}
+		if good == 0 {
+			err = IncorrectPadding
+		} else {
+			out = data[:lenData-lenPadding]
+		}
+	}
+	return
+}
+
+// PKCS7 ////////////////////////////////////////////////////////////
+
+type pkcs7Scheme struct{}
+
+func (pkcs7Scheme) Padding(length, blockSize int) (padding []byte, err error) {
+	if blockSize <= 1 || blockSize > 255 {
+		err = ImpossibleBlockSize
+	} else {
+		n := padLength(length, blockSize)
+		padding = make([]byte, n)
+		for i := range padding {
+			padding[i] = byte(n)
+		}
+	}
+	return
+}
+
+// Any fault in the padding is reported as IncorrectPKCS7Padding.
+func (pkcs7Scheme) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if blockSize <= 1 || blockSize > 255 {
+		return nil, ImpossibleBlockSize
+	}
+	return StripPKCS7PaddingUniform(data, blockSize)
+}
+
+func (pkcs7Scheme) String() string {
+	return "PKCS7"
+}
+
+// ANSI X9.23 ///////////////////////////////////////////////////////
+
+type x923Scheme struct{}
+
+func (x923Scheme) Padding(length, blockSize int) (padding []byte, err error) {
+	if blockSize <= 1 || blockSize > 255 {
+		err = ImpossibleBlockSize
+	} else {
+		n := padLength(length, blockSize)
+		padding = make([]byte, n)
+		padding[n-1] = byte(n)
+	}
+	return
+}
+
+func (x923Scheme) Unpad(data []byte, blockSize int) ([]byte, error) {
+	return unpadLengthByte(data, blockSize, true)
+}
+
+func (x923Scheme) String() string {
+	return "ANSI X.923"
+}
+
+// ISO 10126 ////////////////////////////////////////////////////////
+
+type iso10126Scheme struct{}
+
+func (iso10126Scheme) Padding(length, blockSize int) (padding []byte, err error) {
+	if blockSize <= 1 || blockSize > 255 {
+		err = ImpossibleBlockSize
+	} else {
+		n := padLength(length, blockSize)
+		padding = make([]byte, n)
+		if _, err = io.ReadFull(rand.Reader, padding[:n-1]); err == nil {
+			padding[n-1] = byte(n)
+		} else {
+			padding = nil
+		}
+	}
+	return
+}
+
+func (iso10126Scheme) Unpad(data []byte, blockSize int) ([]byte, error) {
+	return unpadLengthByte(data, blockSize, false)
+}
+
+func (iso10126Scheme) String() string {
+	return "ISO 10126"
+}
+
+// ISO/IEC 7816-4 ///////////////////////////////////////////////////
+
+type iso7816Scheme struct{}
+
+func (iso7816Scheme) Padding(length, blockSize int) (padding []byte, err error) {
+	if blockSize <= 1 {
+		err = ImpossibleBlockSize
+	} else {
+		padding = make([]byte, padLength(length, blockSize))
+		padding[0] = 0x80
+	}
+	return
+}
+
+// Scan the last block backwards for the 0x80 marking the start of the
+// padding; only zero bytes may follow it.
+func (iso7816Scheme) Unpad(data []byte, blockSize int) (out []byte, err error) {
+	if err = checkPaddedData(data, blockSize, math.MaxInt); err == nil {
+		lenData := len(data)
+		found, bad, lenPadding := 0, 0, 0
+		for i := 1; i <= blockSize; i++ {
+			b := data[lenData-i]
+			looking := found ^ 1
+			marker := subtle.ConstantTimeByteEq(b, 0x80)
+			other := 1 ^ (marker | subtle.ConstantTimeByteEq(b, 0))
+			lenPadding = subtle.ConstantTimeSelect(looking&marker, i, lenPadding)
+			bad |= looking & other
+			found |= looking & marker
+		}
+		if found&(bad^1) == 0 {
+			err = IncorrectPadding
+		} else {
+			out = data[:lenData-lenPadding]
+		}
+	}
+	return
+}
+
+func (iso7816Scheme) String() string {
+	return "ISO/IEC 7816-4"
+}
+
+// ZERO PADDING /////////////////////////////////////////////////////
+
+type zeroScheme struct{}
+
+func (zeroScheme) Padding(length, blockSize int) (padding []byte, err error) {
+	if blockSize <= 1 {
+		err = ImpossibleBlockSize
+	} else if length == 0 {
+		padding = make([]byte, blockSize)
+	} else {
+		padding = make([]byte, padLength(length, blockSize)%blockSize)
+	}
+	return
+}
+
+// Strip any zero bytes from the end of the last block.
+func (zeroScheme) Unpad(data []byte, blockSize int) (out []byte, err error) {
+	if err = checkPaddedData(data, blockSize, math.MaxInt); err == nil {
+		lenData := len(data)
+		zeros, stillZero := 0, 1
+		for i := 1; i <= blockSize; i++ {
+			stillZero &= subtle.ConstantTimeByteEq(data[lenData-i], 0)
+			zeros += stillZero
+		}
+		out = data[:lenData-zeros]
+	}
+	return
+}
+
+func (zeroScheme) String() string {
+	return "zero"
+}
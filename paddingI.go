@@ -0,0 +1,17 @@
+package crypto
+
+// A PaddingI is a block padding scheme, which fills a message out to a
+// whole number of blocks before it is encrypted by a block cipher mode
+// such as CBC, and removes the padding after decryption.
+
+type PaddingI interface {
+	// Return the padding to be appended to a message of the length
+	// given.
+	Padding(length, blockSize int) ([]byte, error)
+	// Return the data without its padding.  The padding is checked
+	// strictly, but every fault in it is reported with the same error,
+	// so that the caller does not become a padding oracle.
+	Unpad(data []byte, blockSize int) ([]byte, error)
+	// Return the name of the scheme.
+	String() string
+}
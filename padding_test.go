@@ -0,0 +1,201 @@
+package crypto
+
+// xlCrypto_go/padding_test.go
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/des"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+)
+
+var allSchemes = []PaddingI{
+	PKCS7Scheme, X923Scheme, ISO10126Scheme, ISO7816Scheme, ZeroScheme}
+
+func (s *XLSuite) TestPaddingSchemeVectors(c *C) {
+	msg := []byte{0xdd, 0xdd, 0xdd, 0xdd, 0xdd}
+	bs := des.BlockSize
+
+	vectors := []struct {
+		scheme PaddingI
+		padded []byte
+	}{
+		{PKCS7Scheme, []byte{0xdd, 0xdd, 0xdd, 0xdd, 0xdd, 0x03, 0x03, 0x03}},
+		{X923Scheme, []byte{0xdd, 0xdd, 0xdd, 0xdd, 0xdd, 0x00, 0x00, 0x03}},
+		{ISO7816Scheme, []byte{0xdd, 0xdd, 0xdd, 0xdd, 0xdd, 0x80, 0x00, 0x00}},
+		{ZeroScheme, []byte{0xdd, 0xdd, 0xdd, 0xdd, 0xdd, 0x00, 0x00, 0x00}},
+	}
+	for _, v := range vectors {
+		padded, err := AddPadding(v.scheme, msg, bs)
+		c.Assert(err, IsNil)
+		c.Assert(padded, DeepEquals, v.padded)
+		out, err := v.scheme.Unpad(v.padded, bs)
+		c.Assert(err, IsNil)
+		c.Assert(out, DeepEquals, msg)
+	}
+
+	// ISO 10126 padding is random but for its last byte
+	padded, err := AddPadding(ISO10126Scheme, msg, bs)
+	c.Assert(err, IsNil)
+	c.Assert(len(padded), Equals, bs)
+	c.Assert(padded[bs-1], Equals, byte(3))
+	out, err := ISO10126Scheme.Unpad(
+		[]byte{0xdd, 0xdd, 0xdd, 0xdd, 0xdd, 0x81, 0xa6, 0x03}, bs)
+	c.Assert(err, IsNil)
+	c.Assert(out, DeepEquals, msg)
+
+	// a message filling its block gets a full block of padding, except
+	// with zero padding, which adds none
+	full := bytes.Repeat([]byte{0xdd}, bs)
+	for _, scheme := range allSchemes {
+		padded, err := AddPadding(scheme, full, bs)
+		c.Assert(err, IsNil)
+		if scheme == ZeroScheme {
+			c.Assert(padded, DeepEquals, full)
+		} else {
+			c.Assert(len(padded), Equals, 2*bs)
+		}
+	}
+	padded, err = AddPadding(ZeroScheme, nil, bs)
+	c.Assert(err, IsNil)
+	c.Assert(padded, DeepEquals, make([]byte, bs))
+
+	c.Assert(PKCS7Scheme.String(), Equals, "PKCS7")
+	c.Assert(ISO7816Scheme.String(), Equals, "ISO/IEC 7816-4")
+}
+
+func (s *XLSuite) TestPaddingSchemeRoundTrip(c *C) {
+	rng := xr.MakeSimpleRNG()
+	for _, scheme := range allSchemes {
+		for _, bs := range []int{des.BlockSize, aes.BlockSize, 255} {
+			for _, size := range []int{0, 1, bs - 1, bs, bs + 1, rng.Intn(1024)} {
+				msg := make([]byte, size)
+				rng.NextBytes(msg)
+				if size > 0 && scheme == ZeroScheme {
+					msg[size-1] |= 1 // zero padding can't keep a final zero
+				}
+				padded, err := AddPadding(scheme, msg, bs)
+				c.Assert(err, IsNil)
+				c.Assert(len(padded)%bs, Equals, 0)
+				c.Assert(len(padded) > 0, Equals, true)
+				c.Assert(bytes.Equal(padded[:size], msg), Equals, true)
+				out, err := scheme.Unpad(padded, bs)
+				c.Assert(err, IsNil)
+				c.Assert(bytes.Equal(out, msg), Equals, true)
+			}
+		}
+	}
+}
+
+func (s *XLSuite) TestPaddingSchemeErrors(c *C) {
+	bs := aes.BlockSize
+	for _, scheme := range allSchemes {
+		_, err := scheme.Padding(5, 1)
+		c.Assert(err, Equals, ImpossibleBlockSize)
+		_, err = scheme.Unpad(make([]byte, bs), 0)
+		c.Assert(err, Equals, ImpossibleBlockSize)
+
+		expected := IncorrectPadding
+		if scheme == PKCS7Scheme {
+			expected = IncorrectPKCS7Padding
+		}
+		for _, data := range [][]byte{nil, {}, make([]byte, bs-1)} {
+			_, err = scheme.Unpad(data, bs)
+			c.Assert(err, Equals, expected)
+		}
+	}
+	for _, scheme := range []PaddingI{PKCS7Scheme, X923Scheme, ISO10126Scheme} {
+		_, err := scheme.Padding(5, 256)
+		c.Assert(err, Equals, ImpossibleBlockSize)
+	}
+	_, err := ISO7816Scheme.Padding(5, 256)
+	c.Assert(err, IsNil)
+	_, err = AddPadding(nil, []byte("abc"), bs)
+	c.Assert(err, Equals, NilPaddingScheme)
+
+	// the length byte must be in range
+	for _, scheme := range []PaddingI{X923Scheme, ISO10126Scheme} {
+		block := make([]byte, bs)
+		_, err = scheme.Unpad(block, bs)
+		c.Assert(err, Equals, IncorrectPadding)
+		block[bs-1] = byte(bs + 1)
+		_, err = scheme.Unpad(block, bs)
+		c.Assert(err, Equals, IncorrectPadding)
+	}
+
+	// X.923 padding must be zero-filled
+	block := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 0, 0, 0, 4}
+	_, err = X923Scheme.Unpad(block, bs)
+	c.Assert(err, IsNil)
+	block[13] = 1
+	_, err = X923Scheme.Unpad(block, bs)
+	c.Assert(err, Equals, IncorrectPadding)
+	block[13] = 0
+	block[12] = 1
+	_, err = X923Scheme.Unpad(block, bs)
+	c.Assert(err, Equals, IncorrectPadding)
+
+	// ISO/IEC 7816-4 padding needs its marker, followed only by zeros
+	block = make([]byte, bs)
+	_, err = ISO7816Scheme.Unpad(block, bs)
+	c.Assert(err, Equals, IncorrectPadding)
+	block[3] = 0x80
+	out, err := ISO7816Scheme.Unpad(block, bs)
+	c.Assert(err, IsNil)
+	c.Assert(len(out), Equals, 3)
+	block[9] = 0x01
+	_, err = ISO7816Scheme.Unpad(block, bs)
+	c.Assert(err, Equals, IncorrectPadding)
+	block[9] = 0x80
+	out, err = ISO7816Scheme.Unpad(block, bs)
+	c.Assert(err, IsNil)
+	c.Assert(len(out), Equals, 9)
+	// the marker must lie in the last block
+	twoBlocks := append(make([]byte, bs), 0x80)
+	twoBlocks = append(twoBlocks, make([]byte, bs-1)...)
+	out, err = ISO7816Scheme.Unpad(twoBlocks, bs)
+	c.Assert(err, IsNil)
+	c.Assert(len(out), Equals, bs)
+	copy(twoBlocks, twoBlocks[bs:])
+	for i := bs; i < 2*bs; i++ {
+		twoBlocks[i] = 0
+	}
+	_, err = ISO7816Scheme.Unpad(twoBlocks, bs)
+	c.Assert(err, Equals, IncorrectPadding)
+}
+
+func (s *XLSuite) TestAESCBCWithPadding(c *C) {
+	rng := xr.MakeSimpleRNG()
+	key := s.makeAESKey(rng)
+
+	for _, scheme := range allSchemes {
+		cbc, err := NewAESCBCCipherWithPadding(key, scheme)
+		c.Assert(err, IsNil)
+		c.Assert(cbc.Padding(), Equals, scheme)
+		for _, size := range []int{0, 1, 15, 16, 17, rng.Intn(2 * 1024)} {
+			msg := make([]byte, size)
+			rng.NextBytes(msg)
+			if size > 0 {
+				msg[size-1] |= 1
+			}
+			blob, err := cbc.Encrypt(msg)
+			c.Assert(err, IsNil)
+			reply, err := AESCBCDecryptWithPadding(key, blob, scheme)
+			c.Assert(err, IsNil)
+			c.Assert(bytes.Equal(reply, msg), Equals, true)
+		}
+	}
+
+	// a 7816-4 padded message decrypted as X.923 fails
+	blob, err := AESCBCEncryptWithPadding(key, []byte("abc"), ISO7816Scheme)
+	c.Assert(err, IsNil)
+	_, err = AESCBCDecryptWithPadding(key, blob, X923Scheme)
+	c.Assert(err, Equals, IncorrectPadding)
+
+	_, err = NewAESCBCCipherWithPadding(key, nil)
+	c.Assert(err, Equals, NilPaddingScheme)
+	cbc, err := NewAESCBCCipher(key)
+	c.Assert(err, IsNil)
+	c.Assert(cbc.Padding(), Equals, PKCS7Scheme)
+}
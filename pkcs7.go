@@ -2,7 +2,9 @@ package crypto
 
 // xlCrypto_go/pkcs7.go
 
-import ()
+import (
+	"crypto/subtle"
+)
 
 // PKCS7 padding (RFC 5652) pads a message out to a whole multiple
 // of the block size, with the value of each byte being the number
@@ -29,8 +31,11 @@ func PKCS7Padding(data []byte, blockSize int) (padding []byte) {
 	return
 }
 
+// Return the data with PKCS7 padding appended.  The padding length must
+// fit in a byte, so the block size can be at most 255.
+
 func AddPKCS7Padding(data []byte, blockSize int) (out []byte, err error) {
-	if blockSize <= 1 {
+	if blockSize <= 1 || blockSize > 255 {
 		err = ImpossibleBlockSize
 	} else {
 		padding := PKCS7Padding(data, blockSize)
@@ -44,29 +49,57 @@ func AddPKCS7Padding(data []byte, blockSize int) (out []byte, err error) {
 }
 
 // The data passed is presumed to have PKCS7 padding.  If possible, return
-// a copy of the data without the padding.  Return an error if the padding
-// is incorrect.
+// the data without the padding.  Return an error if the padding is
+// incorrect.
+//
+// Validation is strict, as RFC 5652 requires: the data must be a whole
+// number of blocks, the last byte must give a padding length from 1 to
+// blockSize, and every padding byte must hold that length.  The checks
+// on the padding itself take the same time whatever its content, but
+// the error returned says which check failed; callers decrypting data
+// which an attacker may have tampered with should use
+// StripPKCS7PaddingUniform instead, or they become a padding oracle.
 
 func StripPKCS7Padding(data []byte, blockSize int) (out []byte, err error) {
-	if blockSize <= 1 {
+	if blockSize <= 1 || blockSize > 255 {
 		err = ImpossibleBlockSize
 	} else if data == nil {
 		err = NilData
+	} else if len(data) == 0 || len(data)%blockSize != 0 {
+		err = UnalignedPaddedData
 	}
 	if err == nil {
 		lenData := len(data)
-		if lenData < blockSize {
-			err = IncorrectPKCS7Padding
+		lenPadding := int(data[lenData-1])
+		goodLen := subtle.ConstantTimeLessOrEq(1, lenPadding) &
+			subtle.ConstantTimeLessOrEq(lenPadding, blockSize)
+
+		// examine the whole of the last block, whatever the padding length
+		goodBytes := 1
+		for i := 1; i <= blockSize; i++ {
+			inPadding := subtle.ConstantTimeLessOrEq(i, lenPadding)
+			same := subtle.ConstantTimeByteEq(data[lenData-i], byte(lenPadding))
+			goodBytes &= subtle.ConstantTimeSelect(inPadding, same, 1)
+		}
+		if goodLen == 0 {
+			err = BadPKCS7PaddingLength
+		} else if goodBytes == 0 {
+			err = MismatchedPKCS7Padding
 		} else {
-			// examine the very last byte: it must be padding and must
-			// contain the number of padding bytes added
-			lenPadding := int(data[lenData-1])
-			if lenPadding < 1 || lenData < lenPadding {
-				err = IncorrectPKCS7Padding
-			} else {
-				out = data[:lenData-lenPadding]
-			}
+			out = data[:lenData-lenPadding]
 		}
 	}
 	return
 }
+
+// As StripPKCS7Padding, but any failure whatsoever is reported as
+// IncorrectPKCS7Padding, so that the caller cannot reveal which check
+// failed.  This is what decryption should use.
+
+func StripPKCS7PaddingUniform(data []byte, blockSize int) (out []byte, err error) {
+	out, err = StripPKCS7Padding(data, blockSize)
+	if err != nil {
+		out, err = nil, IncorrectPKCS7Padding
+	}
+	return
+}
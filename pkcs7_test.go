@@ -64,4 +64,74 @@ func (s *XLSuite) TestPKCS7Padding(c *C) {
 	c.Assert(seventeen, DeepEquals, unpaddedSeventeen)
 }
 
+func (s *XLSuite) TestStrictPKCS7Padding(c *C) {
+	bs := aes.BlockSize
+	msg := []byte("eleven byte")
+	padded, err := AddPKCS7Padding(append([]byte{}, msg...), bs)
+	c.Assert(err, IsNil)
+	c.Assert(len(padded), Equals, bs)
+
+	// argument checks
+	_, err = StripPKCS7Padding(padded, 1)
+	c.Assert(err, Equals, ImpossibleBlockSize)
+	_, err = StripPKCS7Padding(padded, 256)
+	c.Assert(err, Equals, ImpossibleBlockSize)
+	_, err = AddPKCS7Padding(msg, 256)
+	c.Assert(err, Equals, ImpossibleBlockSize)
+	_, err = StripPKCS7Padding(nil, bs)
+	c.Assert(err, Equals, NilData)
+	_, err = StripPKCS7Padding([]byte{}, bs)
+	c.Assert(err, Equals, UnalignedPaddedData)
+	_, err = StripPKCS7Padding(padded[:bs-1], bs)
+	c.Assert(err, Equals, UnalignedPaddedData)
+	_, err = StripPKCS7Padding(append(padded, 1), bs)
+	c.Assert(err, Equals, UnalignedPaddedData)
+
+	// the padding length must be from 1 to the block size
+	bad := make([]byte, 2*bs)
+	_, err = StripPKCS7Padding(bad, bs)
+	c.Assert(err, Equals, BadPKCS7PaddingLength)
+	for i := range bad {
+		bad[i] = byte(bs + 1)
+	}
+	_, err = StripPKCS7Padding(bad, bs)
+	c.Assert(err, Equals, BadPKCS7PaddingLength)
+
+	// every padding byte must hold the padding length
+	for i := len(msg); i < bs-1; i++ {
+		bad = append([]byte{}, padded...)
+		bad[i] ^= 0x40
+		_, err = StripPKCS7Padding(bad, bs)
+		c.Assert(err, Equals, MismatchedPKCS7Padding)
+	}
+	// but the data before it does not matter
+	bad = append([]byte{}, padded...)
+	bad[len(msg)-1] = byte(bs - len(msg))
+	out, err := StripPKCS7Padding(bad, bs)
+	c.Assert(err, IsNil)
+	c.Assert(len(out), Equals, len(msg))
+
+	// a full block of padding
+	full := make([]byte, 2*bs)
+	for i := bs; i < 2*bs; i++ {
+		full[i] = byte(bs)
+	}
+	out, err = StripPKCS7Padding(full, bs)
+	c.Assert(err, IsNil)
+	c.Assert(out, DeepEquals, full[:bs])
+	full[bs] = 0
+	_, err = StripPKCS7Padding(full, bs)
+	c.Assert(err, Equals, MismatchedPKCS7Padding)
+
+	// the uniform variant reports every failure alike
+	for _, data := range [][]byte{nil, padded[:bs-1], make([]byte, bs), full} {
+		out, err = StripPKCS7PaddingUniform(data, bs)
+		c.Assert(out, IsNil)
+		c.Assert(err, Equals, IncorrectPKCS7Padding)
+	}
+	out, err = StripPKCS7PaddingUniform(padded, bs)
+	c.Assert(err, IsNil)
+	c.Assert(out, DeepEquals, msg)
+}
+
 // END MOVE THIS TO crypto/ =========================================
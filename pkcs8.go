@@ -0,0 +1,394 @@
+package crypto
+
+// xlCrypto_go/pkcs8.go
+
+import (
+	cr "crypto"
+	"crypto/aes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+	"io"
+)
+
+// PKCS#8 serialization of RSA, ECDSA, and Ed25519 private keys.
+//
+// Unencrypted keys are written as DER PrivateKeyInfo or as PEM blocks
+// of type "PRIVATE KEY".  Encrypted keys are DER EncryptedPrivateKeyInfo
+// or PEM "ENCRYPTED PRIVATE KEY" blocks, using PBES2 (RFC 8018) as
+// OpenSSL does by default: PBKDF2 with HMAC-SHA256 derives an AES-256
+// key which encrypts the PrivateKeyInfo in CBC mode.  When reading,
+// PBKDF2 with HMAC-SHA1, -SHA256, -SHA384, or -SHA512 and AES-128-CBC,
+// AES-192-CBC, or AES-256-CBC are accepted.
+
+const (
+	PKCS8_PEM_TYPE           = "PRIVATE KEY"
+	ENCRYPTED_PKCS8_PEM_TYPE = "ENCRYPTED PRIVATE KEY"
+
+	PBKDF2_ITERATIONS     = 600000
+	MAX_PBKDF2_ITERATIONS = 10000000
+	PBKDF2_SALT_LEN       = 16
+)
+
+var (
+	oidPBES2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// ASN.1 structures from RFC 5958 and RFC 8018.
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// UNENCRYPTED PKCS#8 ///////////////////////////////////////////////
+
+// Serialize an RSA, ECDSA, or Ed25519 private key to PKCS#8 DER.
+func PrivateKeyToPKCS8Wire(key cr.PrivateKey) (data []byte, err error) {
+	switch k := key.(type) {
+	case nil:
+		err = NilPrivateKey
+	case *rsa.PrivateKey, ed25519.PrivateKey:
+		data, err = x509.MarshalPKCS8PrivateKey(k)
+	case *ecdsa.PrivateKey:
+		if err = checkECDSACurve(k.Curve); err == nil {
+			data, err = x509.MarshalPKCS8PrivateKey(k)
+		}
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Deserialize a private key from PKCS#8 DER.  The key returned is an
+// *rsa.PrivateKey, an *ecdsa.PrivateKey, or an ed25519.PrivateKey.
+func PrivateKeyFromPKCS8Wire(data []byte) (key cr.PrivateKey, err error) {
+	if data == nil {
+		err = NilData
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(data)
+	}
+	if err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey, ed25519.PrivateKey:
+			// ok
+		case *ecdsa.PrivateKey:
+			err = checkECDSACurve(k.Curve)
+		default:
+			err = UnsupportedKeyType
+		}
+		if err != nil {
+			key = nil
+		}
+	}
+	return
+}
+
+// Serialize a private key to a PEM "PRIVATE KEY" block.
+func PrivateKeyToPKCS8PEM(key cr.PrivateKey) (data []byte, err error) {
+	der, err := PrivateKeyToPKCS8Wire(key)
+	if err == nil {
+		block := pem.Block{Type: PKCS8_PEM_TYPE, Bytes: der}
+		data = pem.EncodeToMemory(&block)
+	}
+	return
+}
+
+// Deserialize a private key from a PEM "PRIVATE KEY" block.
+func PrivateKeyFromPKCS8PEM(data []byte) (key cr.PrivateKey, err error) {
+	block, err := decodePKCS8PEM(data, PKCS8_PEM_TYPE)
+	if err == nil {
+		key, err = PrivateKeyFromPKCS8Wire(block.Bytes)
+	}
+	return
+}
+
+// ENCRYPTED PKCS#8 /////////////////////////////////////////////////
+
+// Serialize a private key to PBES2-encrypted PKCS#8 DER.
+func PrivateKeyToEncryptedPKCS8Wire(key cr.PrivateKey, passphrase []byte) (
+	data []byte, err error) {
+
+	der, err := PrivateKeyToPKCS8Wire(key)
+	if err == nil {
+		data, err = encryptPKCS8(der, passphrase, PBKDF2_ITERATIONS)
+	}
+	return
+}
+
+// Deserialize a private key from PBES2-encrypted PKCS#8 DER.
+func PrivateKeyFromEncryptedPKCS8Wire(data, passphrase []byte) (
+	key cr.PrivateKey, err error) {
+
+	der, err := decryptPKCS8(data, passphrase)
+	if err == nil {
+		key, err = PrivateKeyFromPKCS8Wire(der)
+	}
+	return
+}
+
+// Serialize a private key to a PEM "ENCRYPTED PRIVATE KEY" block.
+func PrivateKeyToEncryptedPKCS8PEM(key cr.PrivateKey, passphrase []byte) (
+	data []byte, err error) {
+
+	der, err := PrivateKeyToEncryptedPKCS8Wire(key, passphrase)
+	if err == nil {
+		block := pem.Block{Type: ENCRYPTED_PKCS8_PEM_TYPE, Bytes: der}
+		data = pem.EncodeToMemory(&block)
+	}
+	return
+}
+
+// Deserialize a private key from a PEM "ENCRYPTED PRIVATE KEY" block.
+func PrivateKeyFromEncryptedPKCS8PEM(data, passphrase []byte) (
+	key cr.PrivateKey, err error) {
+
+	block, err := decodePKCS8PEM(data, ENCRYPTED_PKCS8_PEM_TYPE)
+	if err == nil {
+		key, err = PrivateKeyFromEncryptedPKCS8Wire(block.Bytes, passphrase)
+	}
+	return
+}
+
+// Encrypt PrivateKeyInfo DER, returning EncryptedPrivateKeyInfo DER.
+func encryptPKCS8(der, passphrase []byte, iter int) (data []byte, err error) {
+	var blob []byte
+	if passphrase == nil {
+		err = NilData
+	}
+	salt := make([]byte, PBKDF2_SALT_LEN)
+	if err == nil {
+		_, err = io.ReadFull(rand.Reader, salt)
+	}
+	if err == nil {
+		aesKey := pbkdf2.Key(passphrase, salt, iter, 32, sha256.New)
+		// AESCBCEncrypt returns IV||ciphertext
+		blob, err = AESCBCEncrypt(aesKey, der)
+	}
+	if err == nil {
+		var kdfParams, schemeParams, pbes2 []byte
+		iv, body := blob[:aes.BlockSize], blob[aes.BlockSize:]
+		kdfParams, err = asn1.Marshal(pbkdf2Params{
+			Salt:           salt,
+			IterationCount: iter,
+			PRF: pkix.AlgorithmIdentifier{
+				Algorithm:  oidHMACSHA256,
+				Parameters: asn1.NullRawValue,
+			},
+		})
+		if err == nil {
+			schemeParams, err = asn1.Marshal(iv)
+		}
+		if err == nil {
+			pbes2, err = asn1.Marshal(pbes2Params{
+				KeyDerivationFunc: pkix.AlgorithmIdentifier{
+					Algorithm:  oidPBKDF2,
+					Parameters: asn1.RawValue{FullBytes: kdfParams},
+				},
+				EncryptionScheme: pkix.AlgorithmIdentifier{
+					Algorithm:  oidAES256CBC,
+					Parameters: asn1.RawValue{FullBytes: schemeParams},
+				},
+			})
+		}
+		if err == nil {
+			data, err = asn1.Marshal(encryptedPrivateKeyInfo{
+				Algo: pkix.AlgorithmIdentifier{
+					Algorithm:  oidPBES2,
+					Parameters: asn1.RawValue{FullBytes: pbes2},
+				},
+				EncryptedData: body,
+			})
+		}
+	}
+	return
+}
+
+// Decrypt EncryptedPrivateKeyInfo DER, returning PrivateKeyInfo DER.
+func decryptPKCS8(data, passphrase []byte) (der []byte, err error) {
+	var (
+		info    encryptedPrivateKeyInfo
+		pbes2   pbes2Params
+		kdf     pbkdf2Params
+		iv      []byte
+		prf     func() hash.Hash
+		keySize int
+	)
+	if data == nil || passphrase == nil {
+		err = NilData
+	} else {
+		err = unmarshalAll(data, &info)
+	}
+	if err == nil {
+		if !info.Algo.Algorithm.Equal(oidPBES2) {
+			err = UnsupportedPBEScheme
+		} else {
+			err = unmarshalAll(info.Algo.Parameters.FullBytes, &pbes2)
+		}
+	}
+	if err == nil {
+		if !pbes2.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+			err = UnsupportedPBEScheme
+		} else {
+			err = unmarshalAll(pbes2.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+		}
+	}
+	if err == nil {
+		prf, err = pbkdf2PRF(kdf.PRF.Algorithm)
+	}
+	if err == nil {
+		keySize, err = pbes2AESKeySize(pbes2.EncryptionScheme.Algorithm)
+	}
+	if err == nil {
+		if kdf.IterationCount <= 0 || kdf.IterationCount > MAX_PBKDF2_ITERATIONS ||
+			(kdf.KeyLength != 0 && kdf.KeyLength != keySize) {
+			err = BadKDFParams
+		} else {
+			err = unmarshalAll(pbes2.EncryptionScheme.Parameters.FullBytes, &iv)
+		}
+	}
+	if err == nil {
+		if len(iv) != aes.BlockSize {
+			err = BadKDFParams
+		} else {
+			aesKey := pbkdf2.Key(passphrase, kdf.Salt, kdf.IterationCount,
+				keySize, prf)
+			blob := append(append([]byte{}, iv...), info.EncryptedData...)
+			der, err = AESCBCDecrypt(aesKey, blob)
+			if err == nil {
+				// the padding may be good by chance, so check the DER too
+				var seq asn1.RawValue
+				err = unmarshalAll(der, &seq)
+			}
+			if err != nil {
+				der, err = nil, BadPassphrase
+			}
+		}
+	}
+	return
+}
+
+// The PBKDF2 pseudo-random function; if none is given it is HMAC-SHA1.
+func pbkdf2PRF(oid asn1.ObjectIdentifier) (prf func() hash.Hash, err error) {
+	switch {
+	case len(oid) == 0 || oid.Equal(oidHMACSHA1):
+		prf = sha1.New
+	case oid.Equal(oidHMACSHA256):
+		prf = sha256.New
+	case oid.Equal(oidHMACSHA384):
+		prf = sha512.New384
+	case oid.Equal(oidHMACSHA512):
+		prf = sha512.New
+	default:
+		err = UnsupportedPBEScheme
+	}
+	return
+}
+
+func pbes2AESKeySize(oid asn1.ObjectIdentifier) (size int, err error) {
+	switch {
+	case oid.Equal(oidAES128CBC):
+		size = 16
+	case oid.Equal(oidAES192CBC):
+		size = 24
+	case oid.Equal(oidAES256CBC):
+		size = 32
+	default:
+		err = UnsupportedPBEScheme
+	}
+	return
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Unmarshal DER, treating trailing data as an error.
+func unmarshalAll(der []byte, val interface{}) (err error) {
+	rest, err := asn1.Unmarshal(der, val)
+	if err == nil && len(rest) > 0 {
+		err = X509ParseOrMarshalError
+	}
+	return
+}
+
+// Decode the first PEM block in data, which must be of the type given.
+func decodePKCS8PEM(data []byte, pemType string) (
+	block *pem.Block, err error) {
+
+	if data == nil {
+		err = NilData
+	} else if block, _ = pem.Decode(data); block == nil ||
+		block.Type != pemType {
+
+		block, err = nil, PemEncodeDecodeFailure
+	}
+	return
+}
+
+// Parse an RSA private key in either PKCS#1 or PKCS#8 DER.
+func rsaPrivateKeyFromDER(der []byte) (key *rsa.PrivateKey, err error) {
+	key, err = x509.ParsePKCS1PrivateKey(der)
+	if err != nil {
+		var (
+			k    interface{}
+			err8 error
+		)
+		if k, err8 = x509.ParsePKCS8PrivateKey(der); err8 == nil {
+			var ok bool
+			if key, ok = k.(*rsa.PrivateKey); ok {
+				err = nil
+			} else {
+				err = NotAnRSAPrivateKey
+			}
+		}
+	}
+	return
+}
+
+// Parse an ECDSA private key in either SEC1 or PKCS#8 DER.
+func ecdsaPrivateKeyFromDER(der []byte) (key *ecdsa.PrivateKey, err error) {
+	key, err = x509.ParseECPrivateKey(der)
+	if err != nil {
+		var (
+			k    interface{}
+			err8 error
+		)
+		if k, err8 = x509.ParsePKCS8PrivateKey(der); err8 == nil {
+			var ok bool
+			if key, ok = k.(*ecdsa.PrivateKey); ok {
+				err = nil
+			} else {
+				err = NotAnECDSAPrivateKey
+			}
+		}
+	}
+	return
+}
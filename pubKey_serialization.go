@@ -0,0 +1,81 @@
+package crypto
+
+// xlCrypto_go/pubKey_serialization.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"golang.org/x/crypto/ssh"
+)
+
+// These functions serialize and deserialize public keys of any type
+// supported by the library.  Keys are passed and returned as
+// crypto.PublicKey values; currently these are *rsa.PublicKey,
+// ed25519.PublicKey or *ecdsa.PublicKey.
+
+// Serialize a public key to wire format, PKIX DER.
+func PubKeyToWire(pubKey cr.PublicKey) (out []byte, err error) {
+	switch pk := pubKey.(type) {
+	case *rsa.PublicKey:
+		out, err = RSAPubKeyToWire(pk)
+	case ed25519.PublicKey:
+		out, err = Ed25519PubKeyToWire(pk)
+	case *ecdsa.PublicKey:
+		out, err = ECDSAPubKeyToWire(pk)
+	case nil:
+		err = NilPublicKey
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Deserialize a public key from wire format.
+func PubKeyFromWire(data []byte) (pubKey cr.PublicKey, err error) {
+	pk, err := x509.ParsePKIXPublicKey(data)
+	if err == nil {
+		switch t := pk.(type) {
+		case *rsa.PublicKey, ed25519.PublicKey:
+			pubKey = pk
+		case *ecdsa.PublicKey:
+			if err = checkECDSACurve(t.Curve); err == nil {
+				pubKey = pk
+			}
+		default:
+			err = UnsupportedKeyType
+		}
+	}
+	return
+}
+
+// Serialize a public key to the newline-terminated format used in SSH
+// authorized_keys files.
+func PubKeyToDisk(pubKey cr.PublicKey) (out []byte, err error) {
+	switch pk := pubKey.(type) {
+	case *ecdsa.PublicKey:
+		out, err = ECDSAPubKeyToDisk(pk)
+	case *rsa.PublicKey, ed25519.PublicKey:
+		var sshKey ssh.PublicKey
+		sshKey, err = ssh.NewPublicKey(pk)
+		if err == nil {
+			out = ssh.MarshalAuthorizedKey(sshKey)
+		}
+	case nil:
+		err = NilPublicKey
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Deserialize a public key from the format used in SSH key files.
+func PubKeyFromDisk(data []byte) (pubKey cr.PublicKey, err error) {
+	pubKey, _, _, _, ok := ParseAuthorizedKey(data)
+	if !ok {
+		err = NotAPublicKey
+	}
+	return
+}
@@ -5,6 +5,10 @@ package crypto
 import (
 	"bytes"
 	//"code.google.com/p/go.crypto/ssh"
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/binary"
@@ -30,7 +34,11 @@ func (r *rsaPublicKey) PublicKeyAlgo() string {
 // ------------------------------------------------------------------
 
 // man 8 sshd
-func ParseAuthorizedKey(in []byte) (out *rsa.PublicKey,
+//
+// The key returned is an *rsa.PublicKey, an ed25519.PublicKey, or an
+// *ecdsa.PublicKey on P-256 or P-384.  Before v0.7.0 only RSA keys
+// were parsed and the key was returned as an *rsa.PublicKey.
+func ParseAuthorizedKey(in []byte) (out cr.PublicKey,
 	comment string, options []string, rest []byte, ok bool) {
 
 	for len(in) > 0 {
@@ -65,37 +73,14 @@ func ParseAuthorizedKey(in []byte) (out *rsa.PublicKey,
 
 		// No key type recognised. Maybe there's an options field at
 		// the beginning.
-		var b byte
-		inQuote := false
-		var candidateOptions []string
-		optionStart := 0
-		for i, b = range in {
-			isEnd := !inQuote && (b == ' ' || b == '\t')
-			if (b == ',' && !inQuote) || isEnd {
-				if i-optionStart > 0 {
-					candidateOptions = append(candidateOptions,
-						string(in[optionStart:i]))
-				}
-				optionStart = i + 1
-			}
-			if isEnd {
-				break
-			}
-			if b == '"' && (i == 0 || (i > 0 && in[i-1] != '\\')) {
-				inQuote = !inQuote
-			}
-		}
-		// skip whitespace = blanks and tabs
-		for i < len(in) && (in[i] == ' ' || in[i] == '\t') {
-			i++
-		}
-		if i == len(in) {
+		candidateOptions, afterOptions, found := splitKeyOptions(in)
+		if !found {
 			// Invalid line: unmatched quote
 			in = rest
 			continue
 		}
 
-		in = in[i:]
+		in = afterOptions
 		i = bytes.IndexAny(in, " \t")
 		if i == -1 {
 			in = rest
@@ -113,11 +98,50 @@ func ParseAuthorizedKey(in []byte) (out *rsa.PublicKey,
 	return
 }
 
+// Split the options field from the front of an authorized_keys line,
+// returning the options and what follows them.  Commas and whitespace
+// within double quotes do not end an option.  found is false if the
+// options are not followed by anything, as when a quote is unmatched.
+func splitKeyOptions(in []byte) (options []string, rest []byte, found bool) {
+	var (
+		i       int
+		b       byte
+		inQuote bool
+	)
+	optionStart := 0
+	for i, b = range in {
+		isEnd := !inQuote && (b == ' ' || b == '\t')
+		if (b == ',' && !inQuote) || isEnd {
+			if i-optionStart > 0 {
+				options = append(options, string(in[optionStart:i]))
+			}
+			optionStart = i + 1
+		}
+		if isEnd {
+			found = true
+			break
+		}
+		if b == '"' && (i == 0 || (i > 0 && in[i-1] != '\\')) {
+			inQuote = !inQuote
+		}
+	}
+	// skip whitespace = blanks and tabs
+	for i < len(in) && (in[i] == ' ' || in[i] == '\t') {
+		i++
+	}
+	if found && i < len(in) {
+		rest = in[i:]
+	} else {
+		options, found = nil, false
+	}
+	return
+}
+
 // Parse a public key in OpenSSH authorized_keys format
 // (see man 8 sshd) once the options and key type fields have been
 // removed.
 func parseSSHAuthorizedKey(in []byte) (
-	out *rsa.PublicKey, comment string, ok bool) {
+	out cr.PublicKey, comment string, ok bool) {
 
 	in = bytes.TrimSpace(in)
 	i := bytes.IndexAny(in, " \t")
@@ -140,9 +164,10 @@ func parseSSHAuthorizedKey(in []byte) (
 	return
 }
 
-// ParseSSHPublicKey - see RFC 4253, section 6.6.
+// ParseSSHPublicKey - see RFC 4253, section 6.6, for Ed25519 keys
+// RFC 8709, and for ECDSA keys RFC 5656.
 func ParseSSHPublicKey(in []byte) (
-	out *rsa.PublicKey, rest []byte, ok bool) {
+	out cr.PublicKey, rest []byte, ok bool) {
 
 	algo, rest, ok := ParseLenHeadedString(in)
 	if ok {
@@ -153,13 +178,26 @@ func ParseSSHPublicKey(in []byte) (
 
 // Parse a public key of the given algorithm.
 func parsePubKeyByAlgo(in []byte, algo string) (
-	pubKey *rsa.PublicKey, rest []byte, ok bool) {
+	pubKey cr.PublicKey, rest []byte, ok bool) {
 
-	if algo == ssh.KeyAlgoRSA {
-		return ParseBareRSAPublicKey(in)
-	} else {
-		return nil, nil, false
+	switch algo {
+	case ssh.KeyAlgoRSA:
+		var key *rsa.PublicKey
+		if key, rest, ok = ParseBareRSAPublicKey(in); ok {
+			pubKey = key
+		}
+	case ssh.KeyAlgoED25519:
+		var key ed25519.PublicKey
+		if key, rest, ok = ParseBareEd25519PublicKey(in); ok {
+			pubKey = key
+		}
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384:
+		var key *ecdsa.PublicKey
+		if key, rest, ok = ParseBareECDSAPublicKey(in, algo); ok {
+			pubKey = key
+		}
 	}
+	return
 }
 
 // See RFC 4253, section 6.6.
@@ -179,6 +217,54 @@ func ParseBareRSAPublicKey(in []byte) (
 	return key, rest, ok
 }
 
+// See RFC 8709, section 4.
+func ParseBareEd25519PublicKey(in []byte) (
+	key ed25519.PublicKey, rest []byte, ok bool) {
+
+	contents, rest, ok := ParseLenHeadedString(in)
+	if ok {
+		if len(contents) == ed25519.PublicKeySize {
+			key = make(ed25519.PublicKey, ed25519.PublicKeySize)
+			copy(key, contents)
+		} else {
+			ok = false
+		}
+	}
+	return
+}
+
+// See RFC 5656, section 3.1.  The curve name must match the key
+// algorithm, and the point must be uncompressed and on the curve.
+func ParseBareECDSAPublicKey(in []byte, algo string) (
+	key *ecdsa.PublicKey, rest []byte, ok bool) {
+
+	var (
+		curve     elliptic.Curve
+		curveName string
+	)
+	switch algo {
+	case ssh.KeyAlgoECDSA256:
+		curve, curveName = elliptic.P256(), "nistp256"
+	case ssh.KeyAlgoECDSA384:
+		curve, curveName = elliptic.P384(), "nistp384"
+	default:
+		return
+	}
+	name, rest, ok := ParseLenHeadedString(in)
+	if !ok || string(name) != curveName {
+		return nil, nil, false
+	}
+	point, rest, ok := ParseLenHeadedString(rest)
+	if ok {
+		x, y := elliptic.Unmarshal(curve, point)
+		if x == nil {
+			return nil, nil, false
+		}
+		key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	}
+	return
+}
+
 var BIG_ONE = big.NewInt(1)
 
 func parseInt(in []byte) (out *big.Int, rest []byte, ok bool) {
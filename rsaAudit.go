@@ -0,0 +1,393 @@
+package crypto
+
+// xlCrypto_go/rsaAudit.go
+
+import (
+	cr "crypto"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// An audit of RSA public keys gathered from authorized_keys files,
+// BuildList signers, and the like.  Each key is checked on its own for
+//
+//   - a modulus shorter than AUDIT_MIN_MODULUS_BITS, or one so short
+//     (below AUDIT_WEAK_MODULUS_BITS) that it can be factored
+//   - a small prime factor, including an even modulus
+//   - a public exponent other than 65537: even, or less than 3, which
+//     is invalid; small, which is risky; or merely unusual
+//   - the structure of the primes generated by Infineon's RSALib, which
+//     makes the key open to the ROCA attack (CVE-2017-15361)
+//
+// and the moduli of all the keys are then checked against each other by
+// batch GCD.  Two moduli with a prime factor in common are both factored
+// by taking their greatest common divisor, so both private keys are
+// exposed.  Keys whose moduli are identical are reported but not treated
+// as sharing a factor.
+
+const (
+	AUDIT_MIN_MODULUS_BITS  = 2048
+	AUDIT_WEAK_MODULUS_BITS = 1024
+	AUDIT_TRIAL_DIVISION    = 10000 // trial division by primes below this
+
+	// the checks
+	AUDIT_SMALL_MODULUS = "small-modulus"
+	AUDIT_SMALL_FACTOR  = "small-factor"
+	AUDIT_EXPONENT      = "exponent"
+	AUDIT_ROCA          = "roca"
+	AUDIT_SHARED_FACTOR = "shared-factor"
+	AUDIT_DUPLICATE_KEY = "duplicate-key"
+
+	// how serious a finding is
+	AUDIT_INFO     = "info"
+	AUDIT_WARNING  = "warning"
+	AUDIT_CRITICAL = "critical"
+)
+
+// A single problem found with a key.
+type RSAFinding struct {
+	Index       int    // the key's position in the audit, from zero
+	Source      string // where the key came from, as given to the audit
+	Fingerprint string // the key's SHA256 fingerprint
+	Check       string // one of the AUDIT_* checks
+	Severity    string // AUDIT_INFO, AUDIT_WARNING, or AUDIT_CRITICAL
+	Detail      string
+	Related     []int // other keys involved: sharing a factor or duplicates
+}
+
+func (f *RSAFinding) String() string {
+	s := f.Severity + " " + f.Check + " " + f.Fingerprint
+	if f.Source != "" {
+		s += " (" + f.Source + ")"
+	}
+	return s + ": " + f.Detail
+}
+
+// Keys are added to an audit only through AddKey and the like, which
+// check them, so that Run need not.
+type RSAAudit struct {
+	keys []rsaAuditKey
+}
+
+// A key in an audit, with where it came from.
+type rsaAuditKey struct {
+	key    *rsa.PublicKey
+	source string
+}
+
+func NewRSAAudit() *RSAAudit {
+	return &RSAAudit{}
+}
+
+// Return the keys in the audit, in the order added.
+func (a *RSAAudit) Keys() (keys []*rsa.PublicKey) {
+	for _, k := range a.keys {
+		keys = append(keys, k.key)
+	}
+	return
+}
+
+// Return where each key in the audit came from, in the order added.
+func (a *RSAAudit) Sources() (sources []string) {
+	for _, k := range a.keys {
+		sources = append(sources, k.source)
+	}
+	return
+}
+
+// Add a key to the audit, with a description of where it came from.
+// Keys other than RSA keys are ignored; the result is whether the key
+// was added.
+func (a *RSAAudit) AddKey(key cr.PublicKey, source string) (added bool) {
+	if rsaKey, ok := key.(*rsa.PublicKey); ok && rsaKey != nil &&
+		rsaKey.N != nil && rsaKey.N.Sign() > 0 {
+
+		a.keys = append(a.keys, rsaAuditKey{key: rsaKey, source: source})
+		added = true
+	}
+	return
+}
+
+// Add the RSA keys in an authorized_keys file, each with a source of
+// the form "SOURCE:LINE", returning the number added.
+func (a *RSAAudit) AddAuthorizedKeys(f *AuthorizedKeysFile,
+	source string) (count int) {
+
+	for _, ent := range f.Keys() {
+		if a.AddKey(ent.Key, source+":"+strconv.Itoa(ent.LineNo)) {
+			count++
+		}
+	}
+	return
+}
+
+// Run every check, returning the findings ordered by key.  A key with
+// no findings passed them all.
+func (a *RSAAudit) Run() (findings []*RSAFinding) {
+	for i, k := range a.keys {
+		for _, f := range auditRSAKey(k.key) {
+			f.Index = i
+			findings = append(findings, f)
+		}
+	}
+	findings = append(findings, a.crossCheck()...)
+	for _, f := range findings {
+		f.Source = a.keys[f.Index].source
+		f.Fingerprint, _ = SSHFingerprintSHA256(a.keys[f.Index].key)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Index < findings[j].Index
+	})
+	return
+}
+
+// Check a single key, returning the findings.  A nil key, or one
+// without a positive modulus, is not an RSA key and has no findings.
+func AuditRSAKey(key *rsa.PublicKey) (findings []*RSAFinding) {
+	if key == nil || key.N == nil || key.N.Sign() <= 0 {
+		return
+	}
+	findings = auditRSAKey(key)
+	for _, f := range findings {
+		f.Fingerprint, _ = SSHFingerprintSHA256(key)
+	}
+	return
+}
+
+func auditRSAKey(key *rsa.PublicKey) (findings []*RSAFinding) {
+	add := func(check, severity, detail string) {
+		findings = append(findings, &RSAFinding{
+			Check: check, Severity: severity, Detail: detail})
+	}
+	bits := key.N.BitLen()
+	if bits < AUDIT_WEAK_MODULUS_BITS {
+		add(AUDIT_SMALL_MODULUS, AUDIT_CRITICAL,
+			fmt.Sprintf("%d-bit modulus can be factored", bits))
+	} else if bits < AUDIT_MIN_MODULUS_BITS {
+		add(AUDIT_SMALL_MODULUS, AUDIT_WARNING,
+			fmt.Sprintf("%d-bit modulus is below the %d-bit minimum",
+				bits, AUDIT_MIN_MODULUS_BITS))
+	}
+	if p := smallFactor(key.N); p != 0 {
+		add(AUDIT_SMALL_FACTOR, AUDIT_CRITICAL,
+			fmt.Sprintf("modulus is divisible by %d", p))
+	}
+	e := key.E
+	if e < 3 || e&1 == 0 {
+		add(AUDIT_EXPONENT, AUDIT_CRITICAL,
+			fmt.Sprintf("public exponent %d is invalid", e))
+	} else if e < 65537 {
+		add(AUDIT_EXPONENT, AUDIT_WARNING,
+			fmt.Sprintf("public exponent %d is small", e))
+	} else if e > 65537 {
+		add(AUDIT_EXPONENT, AUDIT_INFO,
+			fmt.Sprintf("public exponent %d is unusual", e))
+	}
+	if IsROCAVulnerable(key) {
+		add(AUDIT_ROCA, AUDIT_CRITICAL,
+			"modulus has the structure of Infineon RSALib keys (ROCA)")
+	}
+	return
+}
+
+// Look for duplicate keys and then for moduli with common factors.
+func (a *RSAAudit) crossCheck() (findings []*RSAFinding) {
+	// index the moduli, each distinct modulus once
+	first := make(map[string]int)
+	var moduli []*big.Int
+	var owners [][]int
+	for i, k := range a.keys {
+		n := string(k.key.N.Bytes())
+		if j, seen := first[n]; seen {
+			owners[j] = append(owners[j], i)
+		} else {
+			first[n] = len(moduli)
+			moduli = append(moduli, k.key.N)
+			owners = append(owners, []int{i})
+		}
+	}
+	for _, keys := range owners {
+		for _, i := range keys {
+			if len(keys) > 1 {
+				findings = append(findings, &RSAFinding{
+					Index:    i,
+					Check:    AUDIT_DUPLICATE_KEY,
+					Severity: AUDIT_INFO,
+					Detail: fmt.Sprintf("modulus appears %d times in the audit",
+						len(keys)),
+					Related: without(keys, i),
+				})
+			}
+		}
+	}
+
+	// then the moduli sharing factors; where a modulus shares both its
+	// factors, the GCD is the modulus itself, so the partners are found
+	// pairwise among the suspects
+	var suspects []int
+	for j, g := range BatchGCD(moduli) {
+		if g.Cmp(bigOne) > 0 {
+			suspects = append(suspects, j)
+		}
+	}
+	for _, j := range suspects {
+		var partners []int
+		for _, k := range suspects {
+			if k != j && new(big.Int).GCD(nil, nil, moduli[j], moduli[k]).Cmp(bigOne) > 0 {
+				partners = append(partners, owners[k]...)
+			}
+		}
+		sort.Ints(partners)
+		for _, i := range owners[j] {
+			findings = append(findings, &RSAFinding{
+				Index:    i,
+				Check:    AUDIT_SHARED_FACTOR,
+				Severity: AUDIT_CRITICAL,
+				Detail: fmt.Sprintf(
+					"modulus shares a prime factor with %d other key(s); "+
+						"the private key can be recovered", len(partners)),
+				Related: partners,
+			})
+		}
+	}
+	return
+}
+
+// Return the elements of the list other than i.
+func without(list []int, i int) (out []int) {
+	for _, j := range list {
+		if j != i {
+			out = append(out, j)
+		}
+	}
+	return
+}
+
+// BATCH GCD ////////////////////////////////////////////////////////
+
+var bigOne = big.NewInt(1)
+
+// For each modulus, return the GCD of it and the product of all the
+// others, using Bernstein's product and remainder trees, which take
+// quasi-linear rather than quadratic time.  A result other than 1 means
+// that the modulus shares a factor with another; a result equal to the
+// modulus means it shares both.  Moduli must be distinct and positive.
+func BatchGCD(moduli []*big.Int) (gcds []*big.Int) {
+	gcds = make([]*big.Int, len(moduli))
+	if len(moduli) < 2 {
+		for i := range gcds {
+			gcds[i] = big.NewInt(1)
+		}
+		return
+	}
+	// the product tree, leaves first
+	tree := [][]*big.Int{moduli}
+	for level := moduli; len(level) > 1; {
+		next := make([]*big.Int, (len(level)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(level) {
+				next[i] = new(big.Int).Mul(level[2*i], level[2*i+1])
+			} else {
+				next[i] = level[2*i]
+			}
+		}
+		tree = append(tree, next)
+		level = next
+	}
+	// the remainder tree: reduce the product modulo the square of each
+	// node on the way down
+	rems := tree[len(tree)-1]
+	for d := len(tree) - 2; d >= 0; d-- {
+		level := tree[d]
+		next := make([]*big.Int, len(level))
+		for i, node := range level {
+			sq := new(big.Int).Mul(node, node)
+			next[i] = new(big.Int).Mod(rems[i/2], sq)
+		}
+		rems = next
+	}
+	for i, n := range moduli {
+		q := new(big.Int).Quo(rems[i], n)
+		gcds[i] = q.GCD(nil, nil, q, n)
+	}
+	return
+}
+
+// SMALL FACTORS ////////////////////////////////////////////////////
+
+var (
+	smallPrimes     []int
+	smallPrimesOnce sync.Once
+)
+
+// Return the smallest prime below AUDIT_TRIAL_DIVISION dividing n, or 0
+// if there is none.  A prime which is n itself is not counted.
+func smallFactor(n *big.Int) int {
+	smallPrimesOnce.Do(func() {
+		sieve := make([]bool, AUDIT_TRIAL_DIVISION)
+		for p := 2; p < AUDIT_TRIAL_DIVISION; p++ {
+			if !sieve[p] {
+				smallPrimes = append(smallPrimes, p)
+				for m := p * p; m < AUDIT_TRIAL_DIVISION; m += p {
+					sieve[m] = true
+				}
+			}
+		}
+	})
+	var m big.Int
+	for _, p := range smallPrimes {
+		bigP := big.NewInt(int64(p))
+		if m.Mod(n, bigP).Sign() == 0 && n.Cmp(bigP) != 0 {
+			return p
+		}
+	}
+	return 0
+}
+
+// ROCA /////////////////////////////////////////////////////////////
+
+// The primes RSALib generates are of the form k*M + (65537^a mod M),
+// where M is a primorial, so modulo each small prime p dividing M the
+// modulus lies in the subgroup generated by 65537.  Testing for this
+// modulo the primes below, as the detector published by the discoverers
+// does, recognizes such keys with a negligible rate of false positives.
+var rocaPrimes = []int{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67,
+	71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139,
+	149, 151, 157, 163, 167}
+
+var (
+	rocaSubgroups     [][]bool
+	rocaSubgroupsOnce sync.Once
+)
+
+// Whether the key's modulus has the structure of those generated by
+// Infineon's RSALib, which can be factored by the ROCA attack
+// (CVE-2017-15361).
+func IsROCAVulnerable(key *rsa.PublicKey) bool {
+	rocaSubgroupsOnce.Do(func() {
+		rocaSubgroups = make([][]bool, len(rocaPrimes))
+		for i, p := range rocaPrimes {
+			member := make([]bool, p)
+			g := 65537 % p
+			for x := 1; !member[x]; x = x * g % p {
+				member[x] = true
+			}
+			rocaSubgroups[i] = member
+		}
+	})
+	if key == nil || key.N == nil || key.N.Sign() <= 0 {
+		return false
+	}
+	var m big.Int
+	for i, p := range rocaPrimes {
+		if !rocaSubgroups[i][m.Mod(key.N, big.NewInt(int64(p))).Int64()] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package crypto
+
+// xlCrypto_go/rsaAudit_test.go
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	. "gopkg.in/check.v1"
+	"math/big"
+	"strings"
+)
+
+// Return the checks found, in order.
+func auditChecks(findings []*RSAFinding) (checks []string) {
+	for _, f := range findings {
+		checks = append(checks, f.Check+"/"+f.Severity)
+	}
+	return
+}
+
+// Return the findings for the check given.
+func findingsFor(findings []*RSAFinding, check string) (out []*RSAFinding) {
+	for _, f := range findings {
+		if f.Check == check {
+			out = append(out, f)
+		}
+	}
+	return
+}
+
+func (s *XLSuite) TestAuditRSAKey(c *C) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	pub := &key.PublicKey
+	c.Assert(AuditRSAKey(pub), HasLen, 0)
+	c.Assert(IsROCAVulnerable(pub), Equals, false)
+
+	// exponents
+	for e, expected := range map[int]string{
+		1:     "exponent/critical",
+		4:     "exponent/critical",
+		3:     "exponent/warning",
+		65539: "exponent/info",
+	} {
+		odd := &rsa.PublicKey{N: pub.N, E: e}
+		c.Assert(auditChecks(AuditRSAKey(odd)), DeepEquals, []string{expected})
+	}
+
+	// short moduli, one with a small factor
+	key, err = rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	findings := AuditRSAKey(&key.PublicKey)
+	c.Assert(auditChecks(findings), DeepEquals, []string{"small-modulus/warning"})
+	fp, err := SSHFingerprintSHA256(&key.PublicKey)
+	c.Assert(err, IsNil)
+	c.Assert(findings[0].Fingerprint, Equals, fp)
+	c.Assert(strings.Contains(findings[0].String(), "1024-bit"), Equals, true)
+
+	n := new(big.Int).Mul(big.NewInt(7919), big.NewInt(1000003))
+	findings = AuditRSAKey(&rsa.PublicKey{N: n, E: 65537})
+	c.Assert(auditChecks(findings), DeepEquals,
+		[]string{"small-modulus/critical", "small-factor/critical"})
+	c.Assert(findings[1].Detail, Equals, "modulus is divisible by 7919")
+
+	// keys which are not keys
+	c.Assert(AuditRSAKey(nil), HasLen, 0)
+	c.Assert(AuditRSAKey(&rsa.PublicKey{E: 65537}), HasLen, 0)
+	c.Assert(AuditRSAKey(&rsa.PublicKey{N: new(big.Int), E: 65537}), HasLen, 0)
+}
+
+// Generate a prime of the form RSALib uses, k*M + (65537^a mod M).
+func (s *XLSuite) rocaPrime(c *C, m *big.Int) *big.Int {
+	g := big.NewInt(65537)
+	for {
+		a, err := rand.Int(rand.Reader, m)
+		c.Assert(err, IsNil)
+		k, err := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, 300))
+		c.Assert(err, IsNil)
+		p := new(big.Int).Mul(k, m)
+		p.Add(p, new(big.Int).Exp(g, a, m))
+		if p.ProbablyPrime(20) {
+			return p
+		}
+	}
+}
+
+func (s *XLSuite) TestROCA(c *C) {
+	m := big.NewInt(1)
+	for _, p := range rocaPrimes {
+		m.Mul(m, big.NewInt(int64(p)))
+	}
+	n := new(big.Int).Mul(s.rocaPrime(c, m), s.rocaPrime(c, m))
+	pub := &rsa.PublicKey{N: n, E: 65537}
+	c.Assert(IsROCAVulnerable(pub), Equals, true)
+	roca := findingsFor(AuditRSAKey(pub), AUDIT_ROCA)
+	c.Assert(roca, HasLen, 1)
+	c.Assert(roca[0].Severity, Equals, AUDIT_CRITICAL)
+
+	// a modulus of the right structure for all but one of the primes:
+	// stepping by M/167 changes only the residue modulo 167, which must
+	// soon leave the subgroup
+	step := m.Div(m, big.NewInt(167))
+	for i := 0; i < 167 && IsROCAVulnerable(pub); i++ {
+		n.Add(n, step)
+	}
+	c.Assert(IsROCAVulnerable(pub), Equals, false)
+
+	c.Assert(IsROCAVulnerable(nil), Equals, false)
+}
+
+func (s *XLSuite) TestBatchGCD(c *C) {
+	moduli := []*big.Int{big.NewInt(6), big.NewInt(15), big.NewInt(77),
+		big.NewInt(221), big.NewInt(35)}
+	gcds := BatchGCD(moduli)
+	var got []int64
+	for _, g := range gcds {
+		got = append(got, g.Int64())
+	}
+	c.Assert(got, DeepEquals, []int64{3, 15, 7, 1, 35})
+
+	c.Assert(BatchGCD(moduli[:1])[0].Int64(), Equals, int64(1))
+	c.Assert(BatchGCD(nil), HasLen, 0)
+}
+
+func (s *XLSuite) TestRSAAudit(c *C) {
+	primes := make([]*big.Int, 6)
+	for i := range primes {
+		p, err := rand.Prime(rand.Reader, 512)
+		c.Assert(err, IsNil)
+		primes[i] = p
+	}
+	mul := func(i, j int) *rsa.PublicKey {
+		return &rsa.PublicKey{N: new(big.Int).Mul(primes[i], primes[j]), E: 65537}
+	}
+	key0 := mul(0, 1) // shares 0 with key1 and 1 with key4
+	key1 := mul(0, 2)
+	key2 := mul(3, 4) // sound, and duplicated
+	key4 := mul(1, 5)
+
+	audit := NewRSAAudit()
+	c.Assert(audit.AddKey(key0, "key0"), Equals, true)
+	c.Assert(audit.AddKey(key1, "key1"), Equals, true)
+	pubEd, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	c.Assert(audit.AddKey(pubEd, "ed"), Equals, false)
+
+	// an authorized_keys file holding key2 and then an Ed25519 key,
+	// followed by key2 again and key4
+	var text []string
+	for _, k := range []interface{}{key2, pubEd} {
+		line, err := PubKeyToDisk(k)
+		c.Assert(err, IsNil)
+		text = append(text, strings.TrimSpace(string(line)))
+	}
+	f := ParseAuthorizedKeysFile([]byte(strings.Join(text, "\n") + "\n"))
+	c.Assert(audit.AddAuthorizedKeys(f, "authorized_keys"), Equals, 1)
+	c.Assert(audit.AddKey(key2, "buildlist"), Equals, true)
+	c.Assert(audit.AddKey(key4, "key4"), Equals, true)
+	c.Assert(audit.Sources(), DeepEquals, []string{
+		"key0", "key1", "authorized_keys:1", "buildlist", "key4"})
+	c.Assert(audit.Keys(), DeepEquals,
+		[]*rsa.PublicKey{key0, key1, key2, key2, key4})
+
+	findings := audit.Run()
+	for i := 1; i < len(findings); i++ {
+		c.Assert(findings[i-1].Index <= findings[i].Index, Equals, true)
+	}
+	// all are 1024-bit keys
+	c.Assert(findingsFor(findings, AUDIT_SMALL_MODULUS), HasLen, 5)
+
+	shared := findingsFor(findings, AUDIT_SHARED_FACTOR)
+	c.Assert(shared, HasLen, 3)
+	c.Assert(shared[0].Index, Equals, 0)
+	c.Assert(shared[0].Related, DeepEquals, []int{1, 4})
+	c.Assert(shared[0].Source, Equals, "key0")
+	c.Assert(shared[1].Index, Equals, 1)
+	c.Assert(shared[1].Related, DeepEquals, []int{0})
+	c.Assert(shared[2].Index, Equals, 4)
+	c.Assert(shared[2].Related, DeepEquals, []int{0})
+	fp, err := SSHFingerprintSHA256(key4)
+	c.Assert(err, IsNil)
+	c.Assert(shared[2].Fingerprint, Equals, fp)
+
+	dups := findingsFor(findings, AUDIT_DUPLICATE_KEY)
+	c.Assert(dups, HasLen, 2)
+	c.Assert(dups[0].Index, Equals, 2)
+	c.Assert(dups[0].Related, DeepEquals, []int{3})
+	c.Assert(dups[0].Severity, Equals, AUDIT_INFO)
+	c.Assert(dups[1].Source, Equals, "buildlist")
+
+	// an empty audit finds nothing
+	c.Assert(NewRSAAudit().Run(), HasLen, 0)
+	var nilKey *rsa.PublicKey
+	c.Assert(NewRSAAudit().AddKey(nilKey, "nil"), Equals, false)
+}
@@ -0,0 +1,216 @@
+package crypto
+
+// xlCrypto_go/rsaKey.go
+
+import (
+	cr "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"hash"
+	"strings"
+)
+
+const (
+	RSA_ALGORITHM = "RSA"
+	SHA1_WITH_RSA = "SHA1withRSA"
+)
+
+// -- RSAKey --------------------------------------------------------
+
+// An RSAKey wraps an RSA private key so that it can be used through
+// KeyI.  Its signers and verifiers use SHA1withRSA, PKCS#1 v1.5, as
+// does SigVerify.
+type RSAKey struct {
+	privKey *rsa.PrivateKey
+}
+
+func NewRSAKey(privKey *rsa.PrivateKey) (key *RSAKey, err error) {
+	if privKey == nil {
+		err = NilPrivateKey
+	} else {
+		key = &RSAKey{privKey: privKey}
+	}
+	return
+}
+
+// Generate a new RSA key with a modulus of the given size in bits.
+func GenerateRSAKey(bits int) (key *RSAKey, err error) {
+	privKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err == nil {
+		key, err = NewRSAKey(privKey)
+	}
+	return
+}
+
+func (k *RSAKey) Algorithm() string {
+	return RSA_ALGORITHM
+}
+
+// Return the underlying RSA private key.
+func (k *RSAKey) GetPrivateKey() *rsa.PrivateKey {
+	return k.privKey
+}
+
+func (k *RSAKey) GetPublicKey() PublicKeyI {
+	return &RSAPubKey{key: &k.privKey.PublicKey}
+}
+
+// Return a new signer using this key.  Each call returns a fresh
+// signer with its own digest.
+func (k *RSAKey) GetSigner() DigSignerI {
+	signer, _ := NewRSASigner(k.privKey)
+	return signer
+}
+
+// Describe the key.  Only public information is included.
+func (k *RSAKey) String() string {
+	return RSA_ALGORITHM + " key " + k.GetPublicKey().String()
+}
+
+// -- RSAPubKey -----------------------------------------------------
+
+// An RSAPubKey wraps an RSA public key so that it can be used through
+// PublicKeyI.
+type RSAPubKey struct {
+	key *rsa.PublicKey
+}
+
+func NewRSAPubKey(pubKey *rsa.PublicKey) (pk *RSAPubKey, err error) {
+	if pubKey == nil {
+		err = NilPublicKey
+	} else {
+		pk = &RSAPubKey{key: pubKey}
+	}
+	return
+}
+
+// Return the underlying RSA public key.
+func (p *RSAPubKey) GetRSAPublicKey() *rsa.PublicKey {
+	return p.key
+}
+
+// Two keys are equal if they have the same modulus and exponent.  The
+// parameter may be either an *RSAPubKey or an *rsa.PublicKey.
+func (p *RSAPubKey) Equal(any interface{}) bool {
+	var other *rsa.PublicKey
+	switch t := any.(type) {
+	case *RSAPubKey:
+		if t != nil {
+			other = t.key
+		}
+	case *rsa.PublicKey:
+		other = t
+	}
+	if p == nil || p.key == nil || other == nil {
+		return false
+	}
+	return p.key.E == other.E && p.key.N.Cmp(other.N) == 0
+}
+
+// Return the key in the single-line format used in SSH
+// authorized_keys files, without the terminating newline.
+func (p *RSAPubKey) String() string {
+	ser, err := RSAPubKeyToDisk(p.key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(ser))
+}
+
+// -- RSASigner -----------------------------------------------------
+
+// An RSASigner accumulates data through Update() and then produces a
+// SHA1withRSA digital signature over it.
+type RSASigner struct {
+	privKey *rsa.PrivateKey
+	digest  hash.Hash
+}
+
+func NewRSASigner(privKey *rsa.PrivateKey) (s *RSASigner, err error) {
+	if privKey == nil {
+		err = NilPrivateKey
+	} else {
+		s = &RSASigner{
+			privKey: privKey,
+			digest:  sha1.New(),
+		}
+	}
+	return
+}
+
+func (s *RSASigner) Algorithm(any interface{}) string {
+	return SHA1_WITH_RSA
+}
+
+// Return the length in bytes of the signature, the size of the modulus.
+func (s *RSASigner) Length() int {
+	return s.privKey.Size()
+}
+
+func (s *RSASigner) Update(data []byte) {
+	s.digest.Write(data)
+}
+
+// Sign everything passed to Update() since the last call to Sign() and
+// reset the digest.  Returns nil if signing fails.
+func (s *RSASigner) Sign() []byte {
+	hash := s.digest.Sum(nil)
+	s.digest.Reset()
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.privKey, cr.SHA1, hash)
+	if err != nil {
+		return nil
+	}
+	return sig
+}
+
+func (s *RSASigner) String() string {
+	return SHA1_WITH_RSA
+}
+
+// -- RSASigVerifier ------------------------------------------------
+
+// An RSASigVerifier checks SHA1withRSA signatures such as those
+// produced by RSASigner.  Init() must be called with an *RSAPubKey
+// before Verify() can succeed.
+type RSASigVerifier struct {
+	pubKey *rsa.PublicKey
+	digest hash.Hash
+}
+
+func NewRSASigVerifier() *RSASigVerifier {
+	return &RSASigVerifier{digest: sha1.New()}
+}
+
+func (v *RSASigVerifier) GetAlgorithm() string {
+	return SHA1_WITH_RSA
+}
+
+// Set the public key and reset the digest.  If the key is not an
+// *RSAPubKey, subsequent verifications fail.
+func (v *RSASigVerifier) Init(pk PublicKeyI) {
+	v.pubKey = nil
+	if rsaPK, ok := pk.(*RSAPubKey); ok && rsaPK != nil {
+		v.pubKey = rsaPK.key
+	}
+	v.digest.Reset()
+}
+
+func (v *RSASigVerifier) Update(data []byte) {
+	v.digest.Write(data)
+}
+
+// Check the signature against everything passed to Update() since
+// the last call to Init() or Verify(), and reset the digest.
+func (v *RSASigVerifier) Verify(sig []byte) bool {
+	hash := v.digest.Sum(nil)
+	v.digest.Reset()
+	if v.pubKey == nil || sig == nil {
+		return false
+	}
+	return rsa.VerifyPKCS1v15(v.pubKey, cr.SHA1, hash, sig) == nil
+}
+
+func (v *RSASigVerifier) String() string {
+	return SHA1_WITH_RSA
+}
@@ -0,0 +1,79 @@
+package crypto
+
+// xlCrypto_go/rsaKey_test.go
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+)
+
+func (s *XLSuite) TestRSAKeyInterfaces(c *C) {
+	var (
+		_ KeyI         = &RSAKey{}
+		_ PublicKeyI   = &RSAPubKey{}
+		_ DigSignerI   = &RSASigner{}
+		_ SigVerifierI = &RSASigVerifier{}
+	)
+	_, err := NewRSAKey(nil)
+	c.Assert(err, Equals, NilPrivateKey)
+	_, err = NewRSAPubKey(nil)
+	c.Assert(err, Equals, NilPublicKey)
+}
+
+func (s *XLSuite) TestRSAKeySignVerify(c *C) {
+	rng := xr.MakeSimpleRNG()
+
+	key, err := GenerateRSAKey(1024)
+	c.Assert(err, IsNil)
+	c.Assert(key.Algorithm(), Equals, RSA_ALGORITHM)
+
+	pubKey := key.GetPublicKey()
+	c.Assert(pubKey.Equal(pubKey), Equals, true)
+	c.Assert(pubKey.Equal(&key.GetPrivateKey().PublicKey), Equals, true)
+	c.Assert(pubKey.String(), Not(Equals), "")
+
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	c.Assert(pubKey.Equal(&other.PublicKey), Equals, false)
+	c.Assert(pubKey.Equal("not a key"), Equals, false)
+
+	msg := make([]byte, 128+rng.Intn(1024))
+	rng.NextBytes(msg)
+
+	// sign the message in two pieces
+	signer := key.GetSigner()
+	c.Assert(signer.Length(), Equals, 128)
+	signer.Update(msg[:64])
+	signer.Update(msg[64:])
+	sig := signer.Sign()
+	c.Assert(len(sig), Equals, signer.Length())
+
+	// the signature is an ordinary SHA1withRSA signature
+	c.Assert(SigVerify(key.GetPrivateKey().Public().(*rsa.PublicKey),
+		msg, sig), IsNil)
+
+	verifier := NewRSASigVerifier()
+	verifier.Init(pubKey)
+	verifier.Update(msg)
+	c.Assert(verifier.Verify(sig), Equals, true)
+
+	// the digest was reset by Verify()
+	verifier.Update(msg[1:])
+	c.Assert(verifier.Verify(sig), Equals, false)
+
+	// a verifier initialized with the wrong key fails
+	otherPK, err := NewRSAPubKey(&other.PublicKey)
+	c.Assert(err, IsNil)
+	verifier.Init(otherPK)
+	verifier.Update(msg)
+	c.Assert(verifier.Verify(sig), Equals, false)
+
+	// the signer was reset by Sign()
+	signer.Update(msg)
+	sig2 := signer.Sign()
+	verifier.Init(pubKey)
+	verifier.Update(msg)
+	c.Assert(verifier.Verify(sig2), Equals, true)
+}
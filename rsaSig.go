@@ -4,22 +4,95 @@ package crypto
 
 import (
 	cr "crypto"
+	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha1"
 	"errors"
 )
 
 // XXX CHANGE IN SPEC: Rather than panicking, we just
 // return err, and then interpret a nil value as meaning "OK".
 
+// Check a SHA1withRSA signature.  SHA1 no longer passes review, but it
+// stays the digest here because SigVerify checks signatures made by the
+// Java implementation and by RSAKey, which are all SHA1withRSA; new
+// code should use SigVerifyWithHash with one of STRONG_SIG_DIGESTS.
 func SigVerify(pubkey *rsa.PublicKey, msg []byte, sig []byte) error {
+	return SigVerifyWithHash(pubkey, cr.SHA1, msg, sig)
+}
+
+// As SigVerify, but the message digest is one of SIG_DIGESTS rather
+// than SHA1.
+func SigVerifyWithHash(pubkey *rsa.PublicKey, h cr.Hash,
+	msg []byte, sig []byte) error {
+
 	// presumably a rare error, so let's just complain
 	if pubkey == nil || msg == nil || sig == nil {
 		return errors.New("IllegalArgument: nil parameter")
 	}
-	d := sha1.New()
-	d.Write(msg)
-	hash := d.Sum(nil)
+	hash, err := DigestMessage(h, msg)
+	if err != nil {
+		return err
+	}
+	return rsa.VerifyPKCS1v15(pubkey, h, hash, sig)
+}
+
+// Sign a message PKCS#1 v1.5 using the digest specified, which must be
+// one of SIG_DIGESTS.  The signature can be checked with
+// SigVerifyWithHash.
+func SignWithHash(privKey *rsa.PrivateKey, h cr.Hash, msg []byte) (
+	sig []byte, err error) {
+
+	if privKey == nil {
+		err = NilPrivateKey
+	} else if msg == nil {
+		err = NilData
+	} else {
+		var hash []byte
+		hash, err = DigestMessage(h, msg)
+		if err == nil {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, privKey, h, hash)
+		}
+	}
+	return
+}
+
+// RSASSA-PSS ///////////////////////////////////////////////////////
 
-	return rsa.VerifyPKCS1v15(pubkey, cr.SHA1, hash, sig)
+// Sign a message RSASSA-PSS using the digest specified, which must be
+// one of SIG_DIGESTS.  saltLen is the length of the salt in bytes, or
+// rsa.PSSSaltLengthEqualsHash or rsa.PSSSaltLengthAuto.
+func SignPSS(privKey *rsa.PrivateKey, h cr.Hash, saltLen int, msg []byte) (
+	sig []byte, err error) {
+
+	if privKey == nil {
+		err = NilPrivateKey
+	} else if msg == nil {
+		err = NilData
+	} else {
+		var hash []byte
+		hash, err = DigestMessage(h, msg)
+		if err == nil {
+			opts := &rsa.PSSOptions{SaltLength: saltLen, Hash: h}
+			sig, err = rsa.SignPSS(rand.Reader, privKey, h, hash, opts)
+		}
+	}
+	return
+}
+
+// The RSASSA-PSS counterpart to SigVerifyWithHash.  saltLen must match
+// that used in signing unless it is rsa.PSSSaltLengthAuto, in which
+// case the salt length is detected.  Returns nil if the signature is
+// good.
+func SigVerifyPSS(pubkey *rsa.PublicKey, h cr.Hash, saltLen int,
+	msg []byte, sig []byte) error {
+
+	if pubkey == nil || msg == nil || sig == nil {
+		return errors.New("IllegalArgument: nil parameter")
+	}
+	hash, err := DigestMessage(h, msg)
+	if err != nil {
+		return err
+	}
+	opts := &rsa.PSSOptions{SaltLength: saltLen, Hash: h}
+	return rsa.VerifyPSS(pubkey, h, hash, sig, opts)
 }
@@ -0,0 +1,78 @@
+package crypto
+
+// xlCrypto_go/rsaSig_test.go
+
+import (
+	cr "crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+)
+
+func (s *XLSuite) TestRSAPSS(c *C) {
+	rng := xr.MakeSimpleRNG()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	pub := &key.PublicKey
+
+	msg := make([]byte, 200)
+	rng.NextBytes(msg)
+
+	saltLens := []int{rsa.PSSSaltLengthEqualsHash, 0, 20}
+	for _, h := range SIG_DIGESTS {
+		if h == cr.SHA512 || h == cr.SHA3_512 {
+			continue // too big for a 1024-bit key with salt
+		}
+		for _, saltLen := range saltLens {
+			sig, err := SignPSS(key, h, saltLen, msg)
+			c.Assert(err, IsNil)
+			c.Assert(SigVerifyPSS(pub, h, saltLen, msg, sig), IsNil)
+			c.Assert(SigVerifyPSS(pub, h, rsa.PSSSaltLengthAuto, msg, sig),
+				IsNil)
+
+			// a PSS signature is not a PKCS#1 v1.5 signature
+			c.Assert(SigVerifyWithHash(pub, h, msg, sig), NotNil)
+		}
+	}
+
+	// the salt length is checked if given
+	sig, err := SignPSS(key, cr.SHA256, 10, msg)
+	c.Assert(err, IsNil)
+	c.Assert(SigVerifyPSS(pub, cr.SHA256, 20, msg, sig), NotNil)
+
+	msg[0] ^= 1
+	c.Assert(SigVerifyPSS(pub, cr.SHA256, 10, msg, sig), NotNil)
+}
+
+func (s *XLSuite) TestPSSDigestSignatures(c *C) {
+	rng := xr.MakeSimpleRNG()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+
+	digest := make([]byte, cr.SHA256.Size())
+	rng.NextBytes(digest)
+
+	opts := &rsa.PSSOptions{SaltLength: 32, Hash: cr.SHA256}
+	c.Assert(SigSchemeName(opts), Equals, SIG_SCHEME_PSS)
+	c.Assert(SigSchemeName(cr.SHA256), Equals, "")
+
+	sig, err := SignDigestWithOpts(key, opts, digest)
+	c.Assert(err, IsNil)
+
+	vOpts, err := VerifierOpts(SIG_SCHEME_PSS, cr.SHA256)
+	c.Assert(err, IsNil)
+	c.Assert(VerifyDigestWithOpts(&key.PublicKey, vOpts, digest, sig), IsNil)
+	c.Assert(VerifyDigestWithOpts(&key.PublicKey, cr.SHA256, digest, sig),
+		NotNil)
+
+	_, err = VerifierOpts("XYZ", cr.SHA256)
+	c.Assert(err, Equals, UnsupportedSigScheme)
+
+	// PSS is for RSA keys only
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	_, err = SignDigestWithOpts(edKey, opts, digest)
+	c.Assert(err, Equals, UnsupportedSigScheme)
+}
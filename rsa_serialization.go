@@ -37,9 +37,10 @@ func RSAPrivateKeyToWire(privKey *rsa.PrivateKey) (data []byte, err error) {
 	return
 }
 
-// Deserialize an RSA private key from wire format
+// Deserialize an RSA private key from wire format, either PKCS#1 or
+// unencrypted PKCS#8 DER.
 func RSAPrivateKeyFromWire(data []byte) (key *rsa.PrivateKey, err error) {
-	return x509.ParsePKCS1PrivateKey(data)
+	return rsaPrivateKeyFromDER(data)
 }
 
 // CONVERSION TO AND FROM SSH FORMAT ////////////////////////////////
@@ -59,12 +60,12 @@ func RSAPubKeyToDisk(rsaPubKey *rsa.PublicKey) (out []byte, err error) {
 func RSAPubKeyFromDisk(data []byte) (*rsa.PublicKey, error) {
 	// out, _, _, _, ok := ssh.ParseAuthorizedKey(data)
 	out, _, _, _, ok := ParseAuthorizedKey(data)
-	_ = out // DEBUG
 	if ok {
-		return out, nil
-	} else {
-		return nil, NotAnRSAPublicKey
+		if pub, isRSA := out.(*rsa.PublicKey); isRSA {
+			return pub, nil
+		}
 	}
+	return nil, NotAnRSAPublicKey
 }
 
 // DEPRECATED ///////////////////////////////////////////////////////
@@ -95,7 +96,9 @@ func RSAPrivateKeyToPEM(
 	return
 }
 
-// Deserialize an RSA private key from disk format
+// Deserialize an RSA private key from disk format.  The PEM block may
+// be PKCS#1 or PKCS#8; encrypted keys must be read with
+// RSAPrivateKeyFromEncryptedPEM.
 func RSAPrivateKeyFromPEM(data []byte) (
 	key *rsa.PrivateKey, err error) {
 
@@ -105,8 +108,10 @@ func RSAPrivateKeyFromPEM(data []byte) (
 		block, _ := pem.Decode(data)
 		if block == nil {
 			err = PemEncodeDecodeFailure
+		} else if IsEncryptedPEMBlock(block) {
+			err = PassphraseRequired
 		} else {
-			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+			key, err = rsaPrivateKeyFromDER(block.Bytes)
 		}
 	}
 	return
@@ -0,0 +1,312 @@
+package crypto
+
+// xlCrypto_go/signerKey.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"hash"
+	"strings"
+)
+
+const (
+	ED25519_ALGORITHM = "Ed25519"
+	ECDSA_ALGORITHM   = "ECDSA"
+)
+
+// -- SignerKey -----------------------------------------------------
+
+// A SignerKey wraps an RSA, Ed25519, or ECDSA private key so that it
+// can be used through KeyI.  Its signers hash what is passed to
+// Update() with the key's digest and sign the digest as
+// SignDigestWithOpts does, RSA keys PKCS#1 v1.5; its verifiers check
+// such signatures.  Unlike RSAKey's, these never use SHA1 unless it is
+// asked for.
+type SignerKey struct {
+	privKey cr.Signer
+	hash    cr.Hash
+}
+
+// Wrap the private key, which will sign digests made with h, one of
+// SIG_DIGESTS.  If h is zero the digest is SHA512 for Ed25519 keys and
+// SHA256 for others.  Ed25519 keys sign only 512-bit digests.
+func NewSignerKey(privKey cr.Signer, h cr.Hash) (key *SignerKey, err error) {
+	var algo string
+	if isNilKey(privKey) {
+		err = NilPrivateKey
+	} else if algo, err = signerKeyAlgorithm(privKey.Public()); err == nil {
+		if h == 0 {
+			h = defaultSigDigest(privKey.Public())
+		}
+		if err = CheckSigDigest(h); err == nil &&
+			algo == ED25519_ALGORITHM && h.Size() < ED25519_DIGEST_LEN {
+
+			err = UnsupportedDigest
+		}
+	}
+	if err == nil {
+		key = &SignerKey{privKey: privKey, hash: h}
+	}
+	return
+}
+
+// Return RSA_ALGORITHM, ED25519_ALGORITHM, or ECDSA_ALGORITHM.
+func (k *SignerKey) Algorithm() string {
+	algo, _ := signerKeyAlgorithm(k.privKey.Public())
+	return algo
+}
+
+// Return the digest the key's signers use.
+func (k *SignerKey) GetDigestAlgo() cr.Hash {
+	return k.hash
+}
+
+// Return the underlying private key.
+func (k *SignerKey) GetPrivateKey() cr.Signer {
+	return k.privKey
+}
+
+func (k *SignerKey) GetPublicKey() PublicKeyI {
+	return &SignerPubKey{key: k.privKey.Public()}
+}
+
+// Return a new signer using this key.  Each call returns a fresh
+// signer with its own digest.
+func (k *SignerKey) GetSigner() DigSignerI {
+	return &DigestSigner{
+		privKey: k.privKey,
+		algo:    sigAlgorithmName(k.hash, k.Algorithm()),
+		hash:    k.hash,
+		digest:  k.hash.New(),
+	}
+}
+
+// Describe the key.  Only public information is included.
+func (k *SignerKey) String() string {
+	return k.Algorithm() + " key " + k.GetPublicKey().String()
+}
+
+// -- SignerPubKey --------------------------------------------------
+
+// A SignerPubKey wraps an RSA, Ed25519, or ECDSA public key so that it
+// can be used through PublicKeyI.
+type SignerPubKey struct {
+	key cr.PublicKey
+}
+
+func NewSignerPubKey(pubKey cr.PublicKey) (pk *SignerPubKey, err error) {
+	if isNilKey(pubKey) {
+		err = NilPublicKey
+	} else if _, err = signerKeyAlgorithm(pubKey); err == nil {
+		pk = &SignerPubKey{key: pubKey}
+	}
+	return
+}
+
+// Return the underlying public key.
+func (p *SignerPubKey) GetPublicKey() cr.PublicKey {
+	return p.key
+}
+
+// Two keys are equal if they are the same key.  The parameter may be
+// a *SignerPubKey, an *RSAPubKey, or a key of the kind wrapped.
+func (p *SignerPubKey) Equal(any interface{}) bool {
+	var other cr.PublicKey
+	switch t := any.(type) {
+	case *SignerPubKey:
+		if t != nil {
+			other = t.key
+		}
+	case *RSAPubKey:
+		if t != nil && t.key != nil {
+			other = t.key
+		}
+	default:
+		other = t
+	}
+	if p == nil || p.key == nil || other == nil {
+		return false
+	}
+	pk, ok := p.key.(interface{ Equal(cr.PublicKey) bool })
+	return ok && pk.Equal(other)
+}
+
+// Return the key in the single-line format used in SSH
+// authorized_keys files, without the terminating newline.
+func (p *SignerPubKey) String() string {
+	ser, err := PubKeyToDisk(p.key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(ser))
+}
+
+// -- DigestSigner --------------------------------------------------
+
+// A DigestSigner accumulates data through Update() and then signs its
+// digest.  DigestSigners are obtained from SignerKey.GetSigner().
+type DigestSigner struct {
+	privKey cr.Signer
+	algo    string
+	hash    cr.Hash
+	digest  hash.Hash
+}
+
+// Return the name of the signature algorithm, for example
+// "SHA256withECDSA".
+func (s *DigestSigner) Algorithm(any interface{}) string {
+	return s.algo
+}
+
+// Return the length in bytes of the signature.  ECDSA signatures vary
+// in length, so for ECDSA keys this is the longest possible.
+func (s *DigestSigner) Length() int {
+	switch pk := s.privKey.Public().(type) {
+	case *rsa.PublicKey:
+		return pk.Size()
+	case ed25519.PublicKey:
+		return ed25519.SignatureSize
+	case *ecdsa.PublicKey:
+		// a SEQUENCE of two INTEGERs, each perhaps with a leading zero
+		n := 2 * (2 + (pk.Curve.Params().N.BitLen()+7)/8 + 1)
+		if n < 128 {
+			return 2 + n
+		}
+		return 3 + n
+	}
+	return 0
+}
+
+func (s *DigestSigner) Update(data []byte) {
+	s.digest.Write(data)
+}
+
+// Sign everything passed to Update() since the last call to Sign() and
+// reset the digest.  Returns nil if signing fails.
+func (s *DigestSigner) Sign() []byte {
+	digest := s.digest.Sum(nil)
+	s.digest.Reset()
+	sig, err := SignDigestWithOpts(s.privKey, s.hash, digest)
+	if err != nil {
+		return nil
+	}
+	return sig
+}
+
+func (s *DigestSigner) String() string {
+	return s.algo
+}
+
+// -- DigestSigVerifier ---------------------------------------------
+
+// A DigestSigVerifier checks signatures such as those produced by a
+// DigestSigner using the same digest.  Init() must be called with a
+// *SignerPubKey or an *RSAPubKey before Verify() can succeed.
+type DigestSigVerifier struct {
+	pubKey cr.PublicKey
+	hash   cr.Hash
+	digest hash.Hash
+}
+
+// Return a verifier for signatures over digests made with h, one of
+// SIG_DIGESTS.
+func NewDigestSigVerifier(h cr.Hash) (v *DigestSigVerifier, err error) {
+	if err = CheckSigDigest(h); err == nil {
+		v = &DigestSigVerifier{hash: h, digest: h.New()}
+	}
+	return
+}
+
+// Return the name of the signature algorithm, for example
+// "SHA256withRSA", or just the digest's name before Init().
+func (v *DigestSigVerifier) GetAlgorithm() string {
+	algo, _ := signerKeyAlgorithm(v.pubKey)
+	return sigAlgorithmName(v.hash, algo)
+}
+
+// Set the public key and reset the digest.  If the key is not a
+// *SignerPubKey or an *RSAPubKey, subsequent verifications fail.
+func (v *DigestSigVerifier) Init(pk PublicKeyI) {
+	v.pubKey = nil
+	switch t := pk.(type) {
+	case *SignerPubKey:
+		if t != nil {
+			v.pubKey = t.key
+		}
+	case *RSAPubKey:
+		if t != nil && t.key != nil {
+			v.pubKey = t.key
+		}
+	}
+	v.digest.Reset()
+}
+
+func (v *DigestSigVerifier) Update(data []byte) {
+	v.digest.Write(data)
+}
+
+// Check the signature against everything passed to Update() since
+// the last call to Init() or Verify(), and reset the digest.
+func (v *DigestSigVerifier) Verify(sig []byte) bool {
+	digest := v.digest.Sum(nil)
+	v.digest.Reset()
+	if v.pubKey == nil || sig == nil {
+		return false
+	}
+	return VerifyDigestWithOpts(v.pubKey, v.hash, digest, sig) == nil
+}
+
+func (v *DigestSigVerifier) String() string {
+	return v.GetAlgorithm()
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Return whether a key parameter is missing, either because it is a
+// nil interface or because it holds a nil RSA, Ed25519 or ECDSA key.
+func isNilKey(key interface{}) bool {
+	switch k := key.(type) {
+	case nil:
+		return true
+	case *rsa.PublicKey:
+		return k == nil
+	case *rsa.PrivateKey:
+		return k == nil
+	case ed25519.PublicKey:
+		return k == nil
+	case ed25519.PrivateKey:
+		return k == nil
+	case *ecdsa.PublicKey:
+		return k == nil
+	case *ecdsa.PrivateKey:
+		return k == nil
+	}
+	return false
+}
+
+// Name the kind of a public key, or return UnsupportedKeyType.
+func signerKeyAlgorithm(pubKey cr.PublicKey) (algo string, err error) {
+	switch pubKey.(type) {
+	case *rsa.PublicKey:
+		algo = RSA_ALGORITHM
+	case ed25519.PublicKey:
+		algo = ED25519_ALGORITHM
+	case *ecdsa.PublicKey:
+		algo = ECDSA_ALGORITHM
+	default:
+		err = UnsupportedKeyType
+	}
+	return
+}
+
+// Name a signature algorithm in the Java style: "SHA256withRSA",
+// "SHA3-256withECDSA", and so on.
+func sigAlgorithmName(h cr.Hash, keyAlgo string) string {
+	name := strings.Replace(h.String(), "SHA-", "SHA", 1)
+	if keyAlgo != "" {
+		name += "with" + keyAlgo
+	}
+	return name
+}
@@ -0,0 +1,125 @@
+package crypto
+
+// xlCrypto_go/signerKey_test.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	xr "github.com/jddixon/rnglib_go"
+	. "gopkg.in/check.v1"
+)
+
+func (s *XLSuite) TestSignerKeyInterfaces(c *C) {
+	var (
+		_ KeyI         = &SignerKey{}
+		_ PublicKeyI   = &SignerPubKey{}
+		_ DigSignerI   = &DigestSigner{}
+		_ SigVerifierI = &DigestSigVerifier{}
+	)
+	var nilKey *ecdsa.PrivateKey
+	_, err := NewSignerKey(nil, 0)
+	c.Assert(err, Equals, NilPrivateKey)
+	_, err = NewSignerKey(nilKey, 0)
+	c.Assert(err, Equals, NilPrivateKey)
+	_, err = NewSignerPubKey(nil)
+	c.Assert(err, Equals, NilPublicKey)
+	_, err = NewSignerPubKey("not a key")
+	c.Assert(err, Equals, UnsupportedKeyType)
+	_, err = NewDigestSigVerifier(cr.MD5)
+	c.Assert(err, Equals, UnsupportedDigest)
+
+	// Ed25519 keys sign only 512-bit digests
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	_, err = NewSignerKey(edKey, cr.SHA256)
+	c.Assert(err, Equals, UnsupportedDigest)
+}
+
+func (s *XLSuite) TestSignerKeySignVerify(c *C) {
+	rng := xr.MakeSimpleRNG()
+	msg := make([]byte, 128+rng.Intn(1024))
+	rng.NextBytes(msg)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+
+	for i, privKey := range []cr.Signer{rsaKey, edKey, ecKey} {
+		key, err := NewSignerKey(privKey, 0)
+		c.Assert(err, IsNil)
+		c.Assert(key.Algorithm(), Equals,
+			[]string{RSA_ALGORITHM, ED25519_ALGORITHM, ECDSA_ALGORITHM}[i])
+		h := key.GetDigestAlgo()
+		c.Assert(h, Equals, []cr.Hash{cr.SHA256, cr.SHA512, cr.SHA256}[i])
+
+		pubKey := key.GetPublicKey()
+		c.Assert(pubKey.Equal(pubKey), Equals, true)
+		c.Assert(pubKey.Equal(privKey.Public()), Equals, true)
+		c.Assert(pubKey.Equal("not a key"), Equals, false)
+		c.Assert(pubKey.String(), Not(Equals), "")
+
+		// sign the message in two pieces
+		signer := key.GetSigner()
+		c.Assert(signer.Algorithm(nil), Equals,
+			[]string{"SHA256withRSA", "SHA512withEd25519",
+				"SHA256withECDSA"}[i])
+		signer.Update(msg[:64])
+		signer.Update(msg[64:])
+		sig := signer.Sign()
+		c.Assert(sig, NotNil)
+		c.Assert(len(sig) <= signer.Length(), Equals, true)
+
+		// the signature is over the digest of the message
+		digest, err := DigestMessage(h, msg)
+		c.Assert(err, IsNil)
+		c.Assert(VerifyDigestWithOpts(privKey.Public(), h, digest, sig), IsNil)
+
+		verifier, err := NewDigestSigVerifier(h)
+		c.Assert(err, IsNil)
+		verifier.Init(pubKey)
+		c.Assert(verifier.GetAlgorithm(), Equals, signer.Algorithm(nil))
+		verifier.Update(msg)
+		c.Assert(verifier.Verify(sig), Equals, true)
+
+		// the digest was reset by Verify()
+		verifier.Update(msg[1:])
+		c.Assert(verifier.Verify(sig), Equals, false)
+
+		// the signer was reset by Sign()
+		signer.Update(msg)
+		sig2 := signer.Sign()
+		verifier.Update(msg)
+		c.Assert(verifier.Verify(sig2), Equals, true)
+	}
+
+	// an RSAPubKey may be used with the verifier
+	key, err := NewSignerKey(rsaKey, cr.SHA3_256)
+	c.Assert(err, IsNil)
+	signer := key.GetSigner()
+	c.Assert(signer.Algorithm(nil), Equals, "SHA3-256withRSA")
+	c.Assert(signer.Length(), Equals, 128)
+	signer.Update(msg)
+	sig := signer.Sign()
+	rsaPub, err := NewRSAPubKey(&rsaKey.PublicKey)
+	c.Assert(err, IsNil)
+	c.Assert(key.GetPublicKey().Equal(rsaPub), Equals, true)
+	verifier, err := NewDigestSigVerifier(cr.SHA3_256)
+	c.Assert(err, IsNil)
+	verifier.Init(rsaPub)
+	verifier.Update(msg)
+	c.Assert(verifier.Verify(sig), Equals, true)
+
+	// a verifier initialized with the wrong key fails
+	otherPK, err := NewSignerPubKey(ecKey.Public())
+	c.Assert(err, IsNil)
+	verifier.Init(otherPK)
+	verifier.Update(msg)
+	c.Assert(verifier.Verify(sig), Equals, false)
+}
@@ -0,0 +1,269 @@
+package crypto
+
+// xlCrypto_go/sshCert.go
+
+import (
+	cr "crypto"
+	"encoding/base64"
+	"golang.org/x/crypto/ssh"
+	"math"
+	"net"
+	"strings"
+	"time"
+)
+
+// OpenSSH certificates, as described in PROTOCOL.certkeys in the
+// OpenSSH sources: a public key together with principals, a validity
+// window, critical options, and extensions, all signed by a CA key.
+// Certificates may certify, and be signed by, RSA, Ed25519, or ECDSA
+// P-256 or P-384 keys; their types are ssh-rsa-cert-v01@openssh.com,
+// ssh-ed25519-cert-v01@openssh.com, and so on.
+
+const (
+	SSH_USER_CERT = ssh.UserCert
+	SSH_HOST_CERT = ssh.HostCert
+
+	// the critical options OpenSSH defines
+	CERT_FORCE_COMMAND   = "force-command"
+	CERT_SOURCE_ADDRESS  = "source-address"
+	CERT_VERIFY_REQUIRED = "verify-required"
+)
+
+// The exported fields are copies made when the certificate is parsed,
+// for information; changing them changes neither the certificate nor
+// what Validate, CheckSourceAddress, and ForceCommand decide, as these
+// read the signed certificate itself.
+type SSHCertificate struct {
+	Key             cr.PublicKey // the certified key
+	Serial          uint64
+	CertType        uint32 // SSH_USER_CERT or SSH_HOST_CERT
+	KeyId           string
+	Principals      []string
+	ValidAfter      time.Time
+	ValidBefore     time.Time // zero if the certificate never expires
+	CriticalOptions map[string]string
+	Extensions      map[string]string
+	SignatureKey    cr.PublicKey // the CA key
+	cert            *ssh.Certificate
+}
+
+// Return the SSH type of the certificate, for example
+// "ssh-ed25519-cert-v01@openssh.com".
+func (sc *SSHCertificate) Type() string {
+	return sc.cert.Type()
+}
+
+// Return the certificate in SSH wire format.
+func (sc *SSHCertificate) Marshal() []byte {
+	return sc.cert.Marshal()
+}
+
+// Parse a certificate in SSH wire format.
+func ParseSSHCertificate(blob []byte) (sc *SSHCertificate, err error) {
+	var (
+		pub     ssh.PublicKey
+		cert    *ssh.Certificate
+		key, ca cr.PublicKey
+		isCert  bool
+	)
+	if blob == nil {
+		err = NilData
+	} else if pub, err = ssh.ParsePublicKey(blob); err != nil {
+		err = NotACertificate
+	} else if cert, isCert = pub.(*ssh.Certificate); !isCert {
+		err = NotACertificate
+	}
+	if err == nil {
+		key, err = cryptoKeyFromSSH(cert.Key)
+	}
+	if err == nil {
+		ca, err = cryptoKeyFromSSH(cert.SignatureKey)
+	}
+	if err == nil {
+		sc = &SSHCertificate{
+			Key:             key,
+			Serial:          cert.Serial,
+			CertType:        cert.CertType,
+			KeyId:           cert.KeyId,
+			Principals:      append([]string(nil), cert.ValidPrincipals...),
+			ValidAfter:      certTime(cert.ValidAfter),
+			ValidBefore:     certTime(cert.ValidBefore),
+			CriticalOptions: copyCertOptions(cert.CriticalOptions),
+			Extensions:      copyCertOptions(cert.Extensions),
+			SignatureKey:    ca,
+			cert:            cert,
+		}
+		if cert.ValidBefore == ssh.CertTimeInfinity {
+			sc.ValidBefore = time.Time{}
+		}
+	}
+	return
+}
+
+// Parse a certificate in the format of an OpenSSH *-cert.pub file,
+// "type base64-blob [comment]".
+func SSHCertificateFromDisk(data []byte) (
+	sc *SSHCertificate, comment string, err error) {
+
+	var blob []byte
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		err = NotACertificate
+	} else if blob, err = base64.StdEncoding.DecodeString(fields[1]); err != nil {
+		err = NotACertificate
+	} else if sc, err = ParseSSHCertificate(blob); err == nil {
+		if sc.Type() != fields[0] {
+			sc, err = nil, NotACertificate
+		} else {
+			comment = strings.Join(fields[2:], " ")
+		}
+	}
+	return
+}
+
+// Serialize the certificate in the format of an OpenSSH *-cert.pub
+// file.  The output is newline-terminated.
+func (sc *SSHCertificate) ToDisk() []byte {
+	return ssh.MarshalAuthorizedKey(sc.cert)
+}
+
+// VALIDATION ///////////////////////////////////////////////////////
+
+// Check the certificate as sshd or ssh would before accepting it:
+//
+//   - it must be of the type expected, SSH_USER_CERT or SSH_HOST_CERT
+//   - it must be signed by one of the trusted CA keys, and the
+//     signature must be good; RSA CAs must sign with SHA-2, as
+//     rsa-sha2-256 or rsa-sha2-512, not SHA1 ssh-rsa
+//   - the principal, a user or host name, must be listed in it; an
+//     empty principal skips this check, but a certificate listing no
+//     principals is never accepted
+//   - the time given must lie within the validity window
+//   - it must carry no critical options other than those OpenSSH
+//     defines
+//
+// The source-address option is checked separately, by
+// CheckSourceAddress.
+func (sc *SSHCertificate) Validate(trustedCAs []cr.PublicKey,
+	certType uint32, principal string, when time.Time) (err error) {
+
+	cert := sc.cert
+	if cert.CertType != certType {
+		err = CertWrongType
+	} else {
+		caBlob := cert.SignatureKey.Marshal()
+		trusted := false
+		for _, ca := range trustedCAs {
+			if ca != nil && sameKey(ca, caBlob) {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			err = UntrustedCA
+		}
+	}
+	if err == nil {
+		err = sc.verifySignature()
+	}
+	if err == nil && (len(cert.ValidPrincipals) == 0 ||
+		(principal != "" && !sc.hasPrincipal(principal))) {
+
+		err = CertWrongPrincipal
+	}
+	if err == nil {
+		if when.Before(certTime(cert.ValidAfter)) {
+			err = CertNotYetValid
+		} else if cert.ValidBefore != ssh.CertTimeInfinity &&
+			!when.Before(certTime(cert.ValidBefore)) {
+
+			err = CertExpired
+		}
+	}
+	for name := range cert.CriticalOptions {
+		if err == nil && name != CERT_FORCE_COMMAND &&
+			name != CERT_SOURCE_ADDRESS && name != CERT_VERIFY_REQUIRED {
+
+			err = UnsupportedCertOption
+		}
+	}
+	return
+}
+
+// Check the client address against the certificate's source-address
+// critical option, a comma-separated list of addresses and CIDR
+// ranges.  If there is no such option, any address is allowed.
+func (sc *SSHCertificate) CheckSourceAddress(ip net.IP) (err error) {
+	list, present := sc.cert.CriticalOptions[CERT_SOURCE_ADDRESS]
+	if present && (ip == nil ||
+		matchAddrList(ip, strings.Split(list, ",")) <= 0) {
+
+		err = SourceNotPermitted
+	}
+	return
+}
+
+// Return the forced command, or the empty string if there is none.
+func (sc *SSHCertificate) ForceCommand() string {
+	return sc.cert.CriticalOptions[CERT_FORCE_COMMAND]
+}
+
+func (sc *SSHCertificate) hasPrincipal(principal string) bool {
+	for _, p := range sc.cert.ValidPrincipals {
+		if p == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify the CA's signature, which covers everything in the wire form
+// of the certificate up to the signature itself.
+func (sc *SSHCertificate) verifySignature() (err error) {
+	cert := sc.cert
+	if _, isCert := cert.SignatureKey.(*ssh.Certificate); isCert ||
+		cert.Signature == nil {
+
+		return SigVerificationFailure
+	}
+	if !sshSigFormatAllowed(cert.SignatureKey.Type(), cert.Signature.Format) {
+		return UnsupportedSigScheme
+	}
+	blob := cert.Marshal()
+	sigLen := 4 + len(ssh.Marshal(cert.Signature))
+	if cert.SignatureKey.Verify(blob[:len(blob)-sigLen], cert.Signature) != nil {
+		err = SigVerificationFailure
+	}
+	return
+}
+
+// UTILITIES ////////////////////////////////////////////////////////
+
+// Convert a key parsed by the ssh package to one of the key types the
+// library supports.
+func cryptoKeyFromSSH(pub ssh.PublicKey) (key cr.PublicKey, err error) {
+	var ok bool
+	if key, _, ok = ParseSSHPublicKey(pub.Marshal()); !ok {
+		key, err = nil, UnsupportedKeyType
+	}
+	return
+}
+
+// Return a copy of a certificate's critical options or extensions.
+func copyCertOptions(opts map[string]string) (out map[string]string) {
+	if opts != nil {
+		out = make(map[string]string, len(opts))
+		for name, value := range opts {
+			out[name] = value
+		}
+	}
+	return
+}
+
+// Convert a certificate time, seconds since the epoch, to a time.Time.
+func certTime(t uint64) time.Time {
+	if t > math.MaxInt64 {
+		t = math.MaxInt64
+	}
+	return time.Unix(int64(t), 0)
+}
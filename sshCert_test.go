@@ -0,0 +1,223 @@
+package crypto
+
+// xlCrypto_go/sshCert_test.go
+
+import (
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"golang.org/x/crypto/ssh"
+	. "gopkg.in/check.v1"
+	"net"
+	"time"
+)
+
+// A user certificate made by ssh-keygen -s ca -I alice-2026 -z 42
+// -n alice,deploy -V 20200101:20991231 -O force-command=/usr/bin/deploy
+// -O source-address=10.0.0.0/8, and the CA which signed it.
+const (
+	SSH_KEYGEN_CA_PUB    = `ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIG9ziFiKvUivMkWpZhPiJQ+yiEyeeI1Hbc7yeElPQ2z4 fleet-ca`
+	SSH_KEYGEN_USER_CERT = `ecdsa-sha2-nistp256-cert-v01@openssh.com AAAAKGVjZHNhLXNoYTItbmlzdHAyNTYtY2VydC12MDFAb3BlbnNzaC5jb20AAAAgWQn/jlkaCA3/6GfOTVDz74U78B3A5x0bozqrLz81hxYAAAAIbmlzdHAyNTYAAABBBPOF/GKX/NImRBfFucpp7JaW3RRNxWMrjHZhcx5MOm6y2KAjZ4mpBFBciANbVe2KTrcx4wbntWFlsZgNM87izJsAAAAAAAAAKgAAAAEAAAAKYWxpY2UtMjAyNgAAABMAAAAFYWxpY2UAAAAGZGVwbG95AAAAAF4L4QAAAAAA9IUFgAAAAEwAAAANZm9yY2UtY29tbWFuZAAAABMAAAAPL3Vzci9iaW4vZGVwbG95AAAADnNvdXJjZS1hZGRyZXNzAAAADgAAAAoxMC4wLjAuMC84AAAAggAAABVwZXJtaXQtWDExLWZvcndhcmRpbmcAAAAAAAAAF3Blcm1pdC1hZ2VudC1mb3J3YXJkaW5nAAAAAAAAABZwZXJtaXQtcG9ydC1mb3J3YXJkaW5nAAAAAAAAAApwZXJtaXQtcHR5AAAAAAAAAA5wZXJtaXQtdXNlci1yYwAAAAAAAAAAAAAAMwAAAAtzc2gtZWQyNTUxOQAAACBvc4hYir1IrzJFqWYT4iUPsohMnniNR23O8nhJT0Ns+AAAAFMAAAALc3NoLWVkMjU1MTkAAABAJcZNnKklaqItSCnAehLjxjwbskofDZnuGuTLWlPkUhj3uGnqqfNUdSe+gEbvM0zIwopZCA7aq4BZN9SS84ihCg== alice@laptop`
+)
+
+// Sign a certificate for the key with the CA's private key.
+func (s *XLSuite) signSSHCert(c *C, key cr.PublicKey, ca cr.Signer,
+	certType uint32, principals []string, after, before time.Time,
+	options map[string]string) *SSHCertificate {
+
+	sshKey, err := ssh.NewPublicKey(key)
+	c.Assert(err, IsNil)
+	signer, err := ssh.NewSignerFromSigner(ca)
+	c.Assert(err, IsNil)
+	cert := &ssh.Certificate{
+		Key:             sshKey,
+		Serial:          7,
+		CertType:        certType,
+		KeyId:           "test",
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(after.Unix()),
+		ValidBefore:     ssh.CertTimeInfinity,
+		Permissions: ssh.Permissions{
+			CriticalOptions: options,
+			Extensions:      map[string]string{"permit-pty": ""},
+		},
+	}
+	if !before.IsZero() {
+		cert.ValidBefore = uint64(before.Unix())
+	}
+	c.Assert(cert.SignCert(rand.Reader, signer), IsNil)
+	sc, err := ParseSSHCertificate(cert.Marshal())
+	c.Assert(err, IsNil)
+	return sc
+}
+
+func (s *XLSuite) TestSSHKeygenCertificate(c *C) {
+	ca, err := PubKeyFromDisk([]byte(SSH_KEYGEN_CA_PUB))
+	c.Assert(err, IsNil)
+	sc, comment, err := SSHCertificateFromDisk([]byte(SSH_KEYGEN_USER_CERT))
+	c.Assert(err, IsNil)
+	c.Assert(comment, Equals, "alice@laptop")
+	c.Assert(sc.Type(), Equals, "ecdsa-sha2-nistp256-cert-v01@openssh.com")
+	c.Assert(sc.CertType, Equals, uint32(SSH_USER_CERT))
+	c.Assert(sc.Serial, Equals, uint64(42))
+	c.Assert(sc.KeyId, Equals, "alice-2026")
+	c.Assert(sc.Principals, DeepEquals, []string{"alice", "deploy"})
+	c.Assert(sc.ValidAfter.Equal(
+		time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(sc.ValidBefore.Equal(
+		time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(sc.ForceCommand(), Equals, "/usr/bin/deploy")
+	_, present := sc.Extensions["permit-pty"]
+	c.Assert(present, Equals, true)
+	_, isEC := sc.Key.(*ecdsa.PublicKey)
+	c.Assert(isEC, Equals, true)
+	c.Assert(sc.SignatureKey.(ed25519.PublicKey).Equal(ca), Equals, true)
+	c.Assert(string(sc.ToDisk()), Equals, SSH_KEYGEN_USER_CERT[:len(
+		SSH_KEYGEN_USER_CERT)-len(" alice@laptop")]+"\n")
+
+	trusted := []cr.PublicKey{s.newEd25519Pub(c), ca}
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "deploy", now), IsNil)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "", now), IsNil)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "root", now),
+		Equals, CertWrongPrincipal)
+	c.Assert(sc.Validate(trusted, SSH_HOST_CERT, "alice", now),
+		Equals, CertWrongType)
+	c.Assert(sc.Validate(trusted[:1], SSH_USER_CERT, "alice", now),
+		Equals, UntrustedCA)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "alice",
+		time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)), Equals, CertNotYetValid)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "alice", sc.ValidBefore),
+		Equals, CertExpired)
+
+	c.Assert(sc.CheckSourceAddress(net.ParseIP("10.1.2.3")), IsNil)
+	c.Assert(sc.CheckSourceAddress(net.ParseIP("192.168.1.1")),
+		Equals, SourceNotPermitted)
+	c.Assert(sc.CheckSourceAddress(nil), Equals, SourceNotPermitted)
+}
+
+func (s *XLSuite) TestSSHCertificate(c *C) {
+	caPub, caPriv, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	hostPriv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	c.Assert(err, IsNil)
+	hostKey := &hostPriv.PublicKey
+	trusted := []cr.PublicKey{caPub}
+	now := time.Now()
+
+	// a host certificate which never expires
+	sc := s.signSSHCert(c, hostKey, caPriv, SSH_HOST_CERT,
+		[]string{"build.example.com"}, now.Add(-time.Hour), time.Time{}, nil)
+	c.Assert(sc.Type(), Equals, "ecdsa-sha2-nistp384-cert-v01@openssh.com")
+	c.Assert(sc.ValidBefore.IsZero(), Equals, true)
+	c.Assert(sc.Key.(*ecdsa.PublicKey).Equal(hostKey), Equals, true)
+	c.Assert(sc.Validate(trusted, SSH_HOST_CERT, "build.example.com",
+		now.AddDate(100, 0, 0)), IsNil)
+	c.Assert(sc.CheckSourceAddress(nil), IsNil)
+	c.Assert(sc.ForceCommand(), Equals, "")
+
+	// the wire form survives a round trip
+	sc2, err := ParseSSHCertificate(sc.Marshal())
+	c.Assert(err, IsNil)
+	c.Assert(sc2.Marshal(), DeepEquals, sc.Marshal())
+
+	// a certificate listing no principals is not accepted
+	sc = s.signSSHCert(c, hostKey, caPriv, SSH_USER_CERT, nil,
+		now.Add(-time.Hour), now.Add(time.Hour), nil)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "", now),
+		Equals, CertWrongPrincipal)
+
+	// nor one with a critical option we do not understand
+	sc = s.signSSHCert(c, hostKey, caPriv, SSH_USER_CERT, []string{"bob"},
+		now.Add(-time.Hour), now.Add(time.Hour),
+		map[string]string{"no-such-option": ""})
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "bob", now),
+		Equals, UnsupportedCertOption)
+
+	// expired
+	sc = s.signSSHCert(c, hostKey, caPriv, SSH_USER_CERT, []string{"bob"},
+		now.Add(-2*time.Hour), now.Add(-time.Hour), nil)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "bob", now),
+		Equals, CertExpired)
+
+	// tampering with the certificate breaks the signature
+	sc = s.signSSHCert(c, hostKey, caPriv, SSH_USER_CERT, []string{"bob"},
+		now.Add(-time.Hour), now.Add(time.Hour), nil)
+	sc.cert.ValidPrincipals = []string{"root"}
+	sc.Principals = sc.cert.ValidPrincipals
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "root", now),
+		Equals, SigVerificationFailure)
+
+	// changing the exported copies changes nothing
+	sc = s.signSSHCert(c, hostKey, caPriv, SSH_USER_CERT, []string{"bob"},
+		now.Add(-time.Hour), now.Add(time.Hour),
+		map[string]string{CERT_SOURCE_ADDRESS: "10.0.0.0/8"})
+	sc.Principals = []string{"root"}
+	sc.CertType = SSH_HOST_CERT
+	sc.ValidBefore = time.Time{}
+	sc.SignatureKey = hostKey
+	delete(sc.CriticalOptions, CERT_SOURCE_ADDRESS)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "bob", now), IsNil)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "root", now),
+		Equals, CertWrongPrincipal)
+	c.Assert(sc.Validate(trusted, SSH_HOST_CERT, "bob", now),
+		Equals, CertWrongType)
+	c.Assert(sc.Validate([]cr.PublicKey{hostKey}, SSH_USER_CERT, "bob", now),
+		Equals, UntrustedCA)
+	c.Assert(sc.Validate(trusted, SSH_USER_CERT, "bob", now.Add(2*time.Hour)),
+		Equals, CertExpired)
+	c.Assert(sc.CheckSourceAddress(net.ParseIP("192.0.2.1")),
+		Equals, SourceNotPermitted)
+
+	// plain keys are not certificates
+	blob, err := sshKeyBlob(hostKey)
+	c.Assert(err, IsNil)
+	_, err = ParseSSHCertificate(blob)
+	c.Assert(err, Equals, NotACertificate)
+	_, _, err = SSHCertificateFromDisk([]byte(s.makeDiskKey(c, hostKey)))
+	c.Assert(err, Equals, NotACertificate)
+	_, err = ParseSSHCertificate(nil)
+	c.Assert(err, Equals, NilData)
+}
+
+func (s *XLSuite) TestSSHCertificateRSACA(c *C) {
+	caPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	userPub, _, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	sshKey, err := ssh.NewPublicKey(userPub)
+	c.Assert(err, IsNil)
+	trusted := []cr.PublicKey{&caPriv.PublicKey}
+	now := time.Now()
+
+	signer, err := ssh.NewSignerFromSigner(caPriv)
+	c.Assert(err, IsNil)
+	for _, algo := range []string{ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512,
+		ssh.KeyAlgoRSA} {
+
+		algoSigner, err := ssh.NewSignerWithAlgorithms(
+			signer.(ssh.AlgorithmSigner), []string{algo})
+		c.Assert(err, IsNil)
+		cert := &ssh.Certificate{
+			Key:             sshKey,
+			CertType:        SSH_USER_CERT,
+			ValidPrincipals: []string{"alice"},
+			ValidAfter:      uint64(now.Add(-time.Hour).Unix()),
+			ValidBefore:     ssh.CertTimeInfinity,
+		}
+		c.Assert(cert.SignCert(rand.Reader, algoSigner), IsNil)
+		c.Assert(cert.Signature.Format, Equals, algo)
+		sc, err := ParseSSHCertificate(cert.Marshal())
+		c.Assert(err, IsNil)
+		err = sc.Validate(trusted, SSH_USER_CERT, "alice", now)
+		if algo == ssh.KeyAlgoRSA {
+			// SHA1 signatures are refused
+			c.Assert(err, Equals, UnsupportedSigScheme)
+		} else {
+			c.Assert(err, IsNil)
+		}
+	}
+}
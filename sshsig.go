@@ -0,0 +1,295 @@
+package crypto
+
+// xlCrypto_go/sshsig.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/pem"
+	"golang.org/x/crypto/ssh"
+	"hash"
+	"io"
+)
+
+// Detached signatures in the SSHSIG format of PROTOCOL.sshsig in the
+// OpenSSH sources, as made and checked by ssh-keygen -Y sign and
+// ssh-keygen -Y verify.  The signer signs, with an ordinary SSH
+// signature, a blob holding the namespace, which keeps signatures made
+// for one purpose from being accepted for another, the name of a hash
+// algorithm, and the hash of the message.  The signature is armored as
+//
+//     -----BEGIN SSH SIGNATURE-----
+//     base64, in lines of 70 characters
+//     -----END SSH SIGNATURE-----
+//
+// Keys may be RSA, Ed25519, or ECDSA P-256 or P-384 keys, and may be
+// presented with an OpenSSH certificate.  RSA signatures are made with
+// rsa-sha2-512, as ssh-keygen makes them; SHA1 signatures are refused.
+
+const (
+	SSHSIG_MAGIC    = "SSHSIG"
+	SSHSIG_VERSION  = 1
+	SSHSIG_PEM_TYPE = "SSH SIGNATURE"
+	SSHSIG_SHA256   = "sha256"
+	SSHSIG_SHA512   = "sha512" // the default, as with ssh-keygen
+
+	SSHSIG_LINE_LEN = 70 // length of base64 lines in armored signatures
+)
+
+type SSHSignature struct {
+	PublicKey     cr.PublicKey    // the key which signed
+	Certificate   *SSHCertificate // nil unless the key came with one
+	Namespace     string
+	HashAlgorithm string // SSHSIG_SHA256 or SSHSIG_SHA512
+	sig           *ssh.Signature
+	pubBlob       []byte // the key or certificate in SSH wire format
+}
+
+// The signature blob as serialized, following the magic preamble.
+type sshSigBlob struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// What is actually signed, following the magic preamble.
+type sshSigSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// Options for SSHSignWithOpts.
+type SSHSignOpts struct {
+	HashAlgorithm string          // SSHSIG_SHA512 if empty
+	Certificate   *SSHCertificate // if not nil, sent in place of the key
+}
+
+// SIGNING //////////////////////////////////////////////////////////
+
+// Sign the message read from r for the namespace given, hashing it with
+// SHA-512.  The namespace must not be empty; ssh-keygen uses "file" for
+// files.
+func SSHSign(key cr.Signer, namespace string, r io.Reader) (
+	sig *SSHSignature, err error) {
+
+	return SSHSignWithOpts(key, namespace, r, nil)
+}
+
+// Sign the message read from r, with the hash algorithm and any
+// certificate given in opts, which may be nil.  The certificate must
+// certify the key.
+func SSHSignWithOpts(key cr.Signer, namespace string, r io.Reader,
+	opts *SSHSignOpts) (sig *SSHSignature, err error) {
+
+	var (
+		signer   ssh.Signer
+		digest   []byte
+		sshSig   *ssh.Signature
+		cert     *SSHCertificate
+		pubBlob  []byte
+		hashAlgo = SSHSIG_SHA512
+	)
+	if opts != nil {
+		cert = opts.Certificate
+		if opts.HashAlgorithm != "" {
+			hashAlgo = opts.HashAlgorithm
+		}
+	}
+	if key == nil {
+		err = NilPrivateKey
+	} else if namespace == "" {
+		err = WrongSigNamespace
+	} else if r == nil {
+		err = NilData
+	} else if signer, err = ssh.NewSignerFromSigner(key); err == nil {
+		pubBlob = signer.PublicKey().Marshal()
+		if cert != nil {
+			if !sameKey(cert.Key, pubBlob) {
+				err = KeyCertMismatch
+			} else {
+				pubBlob = cert.Marshal()
+			}
+		}
+	}
+	if err == nil {
+		digest, err = sshSigHash(hashAlgo, r)
+	}
+	if err == nil {
+		data := sshSigData(namespace, hashAlgo, digest)
+		if _, isRSA := key.Public().(*rsa.PublicKey); isRSA {
+			sshSig, err = signer.(ssh.AlgorithmSigner).SignWithAlgorithm(
+				rand.Reader, data, ssh.KeyAlgoRSASHA512)
+		} else {
+			sshSig, err = signer.Sign(rand.Reader, data)
+		}
+	}
+	if err == nil {
+		sig = &SSHSignature{
+			PublicKey:     key.Public(),
+			Certificate:   cert,
+			Namespace:     namespace,
+			HashAlgorithm: hashAlgo,
+			sig:           sshSig,
+			pubBlob:       pubBlob,
+		}
+	}
+	return
+}
+
+// Hash the message with the algorithm named.
+func sshSigHash(hashAlgo string, r io.Reader) (digest []byte, err error) {
+	var h hash.Hash
+	switch hashAlgo {
+	case SSHSIG_SHA256:
+		h = sha256.New()
+	case SSHSIG_SHA512:
+		h = sha512.New()
+	default:
+		err = UnsupportedDigest
+	}
+	if err == nil {
+		if _, err = io.Copy(h, r); err == nil {
+			digest = h.Sum(nil)
+		}
+	}
+	return
+}
+
+// Return the data which is signed.
+func sshSigData(namespace, hashAlgo string, digest []byte) []byte {
+	return append([]byte(SSHSIG_MAGIC), ssh.Marshal(&sshSigSignedData{
+		Namespace:     namespace,
+		HashAlgorithm: hashAlgo,
+		Hash:          digest,
+	})...)
+}
+
+// SERIALIZATION ////////////////////////////////////////////////////
+
+// Return the signature blob, unarmored.
+func (sig *SSHSignature) Marshal() []byte {
+	return append([]byte(SSHSIG_MAGIC), ssh.Marshal(&sshSigBlob{
+		Version:       SSHSIG_VERSION,
+		PublicKey:     sig.pubBlob,
+		Namespace:     sig.Namespace,
+		HashAlgorithm: sig.HashAlgorithm,
+		Signature:     ssh.Marshal(sig.sig),
+	})...)
+}
+
+// Return the signature armored as ssh-keygen writes it to a .sig file.
+// The output is newline-terminated.
+func (sig *SSHSignature) Armor() []byte {
+	var buf bytes.Buffer
+	encoded := base64.StdEncoding.EncodeToString(sig.Marshal())
+	buf.WriteString("-----BEGIN " + SSHSIG_PEM_TYPE + "-----\n")
+	for len(encoded) > SSHSIG_LINE_LEN {
+		buf.WriteString(encoded[:SSHSIG_LINE_LEN] + "\n")
+		encoded = encoded[SSHSIG_LINE_LEN:]
+	}
+	buf.WriteString(encoded + "\n")
+	buf.WriteString("-----END " + SSHSIG_PEM_TYPE + "-----\n")
+	return buf.Bytes()
+}
+
+// Parse a signature, armored or not.
+func ParseSSHSignature(data []byte) (sig *SSHSignature, err error) {
+	var (
+		blob   sshSigBlob
+		sshSig ssh.Signature
+		pub    ssh.PublicKey
+	)
+	if data == nil {
+		err = NilData
+	} else if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != SSHSIG_PEM_TYPE {
+			err = IllFormedSSHSig
+		} else {
+			data = block.Bytes
+		}
+	}
+	if err == nil {
+		if !bytes.HasPrefix(data, []byte(SSHSIG_MAGIC)) ||
+			ssh.Unmarshal(data[len(SSHSIG_MAGIC):], &blob) != nil ||
+			ssh.Unmarshal(blob.Signature, &sshSig) != nil {
+
+			err = IllFormedSSHSig
+		} else if blob.Version != SSHSIG_VERSION {
+			err = UnknownSSHSigVersion
+		} else if pub, err = ssh.ParsePublicKey(blob.PublicKey); err != nil {
+			err = IllFormedSSHSig
+		}
+	}
+	if err == nil {
+		sig = &SSHSignature{
+			Namespace:     blob.Namespace,
+			HashAlgorithm: blob.HashAlgorithm,
+			sig:           &sshSig,
+			pubBlob:       blob.PublicKey,
+		}
+		if _, isCert := pub.(*ssh.Certificate); isCert {
+			sig.Certificate, err = ParseSSHCertificate(blob.PublicKey)
+			if err == nil {
+				sig.PublicKey = sig.Certificate.Key
+			}
+		} else {
+			sig.PublicKey, err = cryptoKeyFromSSH(pub)
+		}
+		if err != nil {
+			sig = nil
+		}
+	}
+	return
+}
+
+// VERIFICATION /////////////////////////////////////////////////////
+
+// Check the signature over the message read from r, which must have
+// been made for the namespace given.  This shows only that whoever
+// holds the signature's key signed the message; whether that key is to
+// be trusted is for the caller to decide, for example with
+// VerifyAllowed.
+func (sig *SSHSignature) Verify(r io.Reader, namespace string) (err error) {
+	var (
+		digest []byte
+		pub    ssh.PublicKey
+	)
+	if sig.Namespace != namespace {
+		err = WrongSigNamespace
+	} else if r == nil {
+		err = NilData
+	} else if pub, err = ssh.NewPublicKey(sig.PublicKey); err == nil {
+		if !sshSigFormatAllowed(pub.Type(), sig.sig.Format) {
+			err = UnsupportedSigScheme
+		} else {
+			digest, err = sshSigHash(sig.HashAlgorithm, r)
+		}
+	}
+	if err == nil {
+		data := sshSigData(sig.Namespace, sig.HashAlgorithm, digest)
+		if pub.Verify(data, sig.sig) != nil {
+			err = SigVerificationFailure
+		}
+	}
+	return
+}
+
+// Whether a signature format may be used with a key of the type given:
+// RSA keys must sign with SHA-2, other keys in their only format.
+func sshSigFormatAllowed(keyType, format string) bool {
+	if keyType == ssh.KeyAlgoRSA {
+		return format == ssh.KeyAlgoRSASHA256 || format == ssh.KeyAlgoRSASHA512
+	}
+	return format == keyType
+}
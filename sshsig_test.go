@@ -0,0 +1,289 @@
+package crypto
+
+// xlCrypto_go/sshsig_test.go
+
+import (
+	"bytes"
+	cr "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	. "gopkg.in/check.v1"
+	"strings"
+	"time"
+)
+
+// Signatures over SSH_KEYGEN_SIG_MSG made by ssh-keygen -Y sign -n file
+// with an Ed25519 key, an RSA key, and the Ed25519 key presented with a
+// certificate for release@example.com valid from 2020 to 2040, and an
+// allowed_signers file listing the Ed25519 key and the CA.
+const (
+	SSH_KEYGEN_SIG_MSG = "The quick brown fox jumps over the lazy dog\n"
+	SSH_KEYGEN_SIG_ED  = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAg6aO1nqPPGefFjjcjefeF3IQhE/
+vTHnjBA3lhODiPEuAAAAAEZmlsZQAAAAAAAAAGc2hhNTEyAAAAUwAAAAtzc2gtZWQyNTUx
+OQAAAEDHT9iiE82/He1qVfw+a1GBzQmtmp2awD8foXzaa94huQpJ7iFsGbUCdSl0nQORfD
+mHAyrr1KS8D6s4fJzUYLQD
+-----END SSH SIGNATURE-----
+`
+	SSH_KEYGEN_SIG_RSA = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAARcAAAAHc3NoLXJzYQAAAAMBAAEAAAEBAJRqO+a6sZa64HSg9/2+xf
+jm806kpqiKKNkBN1l78RQmLBEm7aLRG+LkQbq09vvRb5TM9CLLrVQthwBrLF8RwvDeh2Ia
++L+EJCCPqeraWN+nIy8MrvbB4ehNm3kQTB+MYgSypP3Qt69vPiykQCOjGFOnE/FT0fRZrP
+8Wr8KOXKXGHX9SkrTADH9CP9LNkkXXGn6w65k7AGlyrI4g+7q7FQbywVEEzUujbWOKAITA
+VtvQciUiVEfLaWH2WG0gMs3P3V0fyf6Xd0jtXBdhfFAvjqesB+aPJ5Rvf/GYeow31ixLIY
+uFRe9i9Sc5ugMSddzNNv7g/9EFFhTQK5J+OmFGeiUAAAAEZmlsZQAAAAAAAAAGc2hhNTEy
+AAABFAAAAAxyc2Etc2hhMi01MTIAAAEAd2AMIWMHcCfxkbt4qqaq3EOD8CkbLWxc7RKoUV
+A+inKLtHHhRoXXxgIGU1yFCTsNeUwp9M62syZCgPQyDl/T9feG0WY9q7Cwq6etoPLEBfIH
+CnVo4GR82HmKs/dwjRvKnu09fkHpH9AnfjK8eXuDN3SdVenJTS5Gawf5bAV9otggHn7KJp
+Pkc/f/QfI8N2fTNiq1x9qUOSZH0fqWnR5b2j5Eb73zXoCi2nuocV4MGYGGiAJ4Pzpe265L
+lu8j8notP9D37SwvIx0rgYgC9HrMmCyvaijGxaB0hRdc+vdggXdTHr3YV1O3HZHAj8yhD4
+mnDVAy05M60DzFcJb/o/JfuA==
+-----END SSH SIGNATURE-----
+`
+	SSH_KEYGEN_SIG_CERT = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAAc8AAAAgc3NoLWVkMjU1MTktY2VydC12MDFAb3BlbnNzaC5jb20AAA
+AgPTdJ1rSKzBynmdrariu1iUo1uUJVa6NOZhNX906SnwAAAAAg6aO1nqPPGefFjjcjefeF
+3IQhE/vTHnjBA3lhODiPEuAAAAAAAAAAAAAAAAEAAAAMcmVsZWFzZS1jZXJ0AAAAFwAAAB
+NyZWxlYXNlQGV4YW1wbGUuY29tAAAAAF4L4QAAAAAAg6p+gAAAAAAAAACCAAAAFXBlcm1p
+dC1YMTEtZm9yd2FyZGluZwAAAAAAAAAXcGVybWl0LWFnZW50LWZvcndhcmRpbmcAAAAAAA
+AAFnBlcm1pdC1wb3J0LWZvcndhcmRpbmcAAAAAAAAACnBlcm1pdC1wdHkAAAAAAAAADnBl
+cm1pdC11c2VyLXJjAAAAAAAAAAAAAAAzAAAAC3NzaC1lZDI1NTE5AAAAINxBkOJrxrD3e0
+wBhPxgD8eWAxlY07xe67DuTWxwLCPEAAAAUwAAAAtzc2gtZWQyNTUxOQAAAECf1hwwmWH+
+fvUf5Fz2SIhVMfQnyv/LmU39kDo4ksd8FOwZoqXIAE78ih11I1Zpy5ZOTh3xDEAEj/HHQE
+Dpw2MOAAAABGZpbGUAAAAAAAAABnNoYTUxMgAAAFMAAAALc3NoLWVkMjU1MTkAAABAx0/Y
+ohPNvx3talX8PmtRgc0JrZqdmsA/H6F82mveIbkKSe4hbBm1AnUpdJ0DkXw5hwMq69SkvA
++rOHyc1GC0Aw==
+-----END SSH SIGNATURE-----
+`
+	SSH_KEYGEN_SIG_RSA_PUB     = `ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQCUajvmurGWuuB0oPf9vsX45vNOpKaoiijZATdZe/EUJiwRJu2i0Rvi5EG6tPb70W+UzPQiy61ULYcAayxfEcLw3odiGvi/hCQgj6nq2ljfpyMvDK72weHoTZt5EEwfjGIEsqT90Levbz4spEAjoxhTpxPxU9H0Waz/Fq/Cjlylxh1/UpK0wAx/Qj/SzZJF1xp+sOuZOwBpcqyOIPu6uxUG8sFRBM1Lo21jigCEwFbb0HIlIlRHy2lh9lhtIDLNz91dH8n+l3dI7VwXYXxQL46nrAfmjyeUb3/xmHqMN9YsSyGLhUXvYvUnOboDEnXczTb+4P/RBRYU0CuSfjphRnol rsa@example.com`
+	SSH_KEYGEN_ALLOWED_SIGNERS = `# release signers
+release@example.com namespaces="file" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOmjtZ6jzxnnxY43I3n3hdyEIRP70x54wQN5YTg4jxLg
+ops@example.com,rel*@example.com cert-authority ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAINxBkOJrxrD3e0wBhPxgD8eWAxlY07xe67DuTWxwLCPE
+`
+)
+
+func (s *XLSuite) TestSSHKeygenSignatures(c *C) {
+	signers := ParseAllowedSigners([]byte(SSH_KEYGEN_ALLOWED_SIGNERS))
+	c.Assert(signers.Entries, HasLen, 2)
+	c.Assert(signers.Entries[0].LineNo, Equals, 2)
+	c.Assert(signers.Entries[0].Namespaces, DeepEquals, []string{"file"})
+	c.Assert(signers.Entries[1].CertAuthority, Equals, true)
+	c.Assert(signers.Entries[1].Principals, DeepEquals,
+		[]string{"ops@example.com", "rel*@example.com"})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	msg := func() *strings.Reader { return strings.NewReader(SSH_KEYGEN_SIG_MSG) }
+
+	// the plain Ed25519 signature
+	sig, err := ParseSSHSignature([]byte(SSH_KEYGEN_SIG_ED))
+	c.Assert(err, IsNil)
+	c.Assert(sig.Namespace, Equals, "file")
+	c.Assert(sig.HashAlgorithm, Equals, SSHSIG_SHA512)
+	c.Assert(sig.Certificate, IsNil)
+	c.Assert(string(sig.Armor()), Equals, SSH_KEYGEN_SIG_ED)
+	c.Assert(sig.Verify(msg(), "file"), IsNil)
+	signer, err := sig.VerifyAllowed(msg(), "file", "release@example.com",
+		signers, now)
+	c.Assert(err, IsNil)
+	c.Assert(signer, Equals, signers.Entries[0])
+	_, err = sig.VerifyAllowed(msg(), "file", "ops@example.com", signers, now)
+	c.Assert(err, Equals, NotAnAllowedSigner)
+	c.Assert(sig.FindPrincipals(signers, now), DeepEquals,
+		[]string{"release@example.com"})
+	_, err = sig.VerifyAllowed(msg(), "git", "release@example.com", signers, now)
+	c.Assert(err, Equals, NotAnAllowedSigner)
+	c.Assert(sig.Verify(msg(), "git"), Equals, WrongSigNamespace)
+	c.Assert(sig.Verify(strings.NewReader("The quick brown fox"), "file"),
+		Equals, SigVerificationFailure)
+
+	// the RSA signature, made with rsa-sha2-512
+	rsaPub, err := PubKeyFromDisk([]byte(SSH_KEYGEN_SIG_RSA_PUB))
+	c.Assert(err, IsNil)
+	sig, err = ParseSSHSignature([]byte(SSH_KEYGEN_SIG_RSA))
+	c.Assert(err, IsNil)
+	c.Assert(sig.PublicKey.(*rsa.PublicKey).Equal(rsaPub), Equals, true)
+	c.Assert(sig.Verify(msg(), "file"), IsNil)
+	c.Assert(sig.FindPrincipals(signers, now), HasLen, 0)
+	withRSA := ParseAllowedSigners([]byte(SSH_KEYGEN_ALLOWED_SIGNERS +
+		`"rsa@example.com" ` + SSH_KEYGEN_SIG_RSA_PUB + "\n"))
+	signer, err = sig.VerifyAllowed(msg(), "file", "rsa@example.com",
+		withRSA, now)
+	c.Assert(err, IsNil)
+	c.Assert(signer.LineNo, Equals, 4)
+	c.Assert(signer.Comment, Equals, "rsa@example.com")
+
+	// the signature made with the certificate, trusted through the CA
+	sig, err = ParseSSHSignature([]byte(SSH_KEYGEN_SIG_CERT))
+	c.Assert(err, IsNil)
+	c.Assert(sig.Certificate, NotNil)
+	c.Assert(sig.Certificate.Principals, DeepEquals,
+		[]string{"release@example.com"})
+	_, isEd := sig.PublicKey.(ed25519.PublicKey)
+	c.Assert(isEd, Equals, true)
+	signer, err = sig.VerifyAllowed(msg(), "file", "release@example.com",
+		signers, now)
+	c.Assert(err, IsNil)
+	c.Assert(signer, Equals, signers.Entries[1])
+	_, err = sig.VerifyAllowed(msg(), "file", "ops@example.com", signers, now)
+	c.Assert(err, Equals, NotAnAllowedSigner)
+	c.Assert(sig.FindPrincipals(signers, now), DeepEquals,
+		[]string{"release@example.com"})
+	// the certificate has expired by 2041
+	_, err = sig.VerifyAllowed(msg(), "file", "release@example.com", signers,
+		time.Date(2041, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(err, Equals, NotAnAllowedSigner)
+
+	// unarmored signatures parse too
+	raw := sig.Marshal()
+	sig, err = ParseSSHSignature(raw)
+	c.Assert(err, IsNil)
+	c.Assert(sig.Verify(msg(), "file"), IsNil)
+}
+
+func (s *XLSuite) TestSSHSignRoundTrip(c *C) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	msg := []byte("a message of no great length")
+	now := time.Now()
+
+	for _, key := range []cr.Signer{rsaKey, ecKey, edKey} {
+		for _, hashAlgo := range []string{SSHSIG_SHA256, SSHSIG_SHA512} {
+			sig, err := SSHSignWithOpts(key, "file", bytes.NewReader(msg),
+				&SSHSignOpts{HashAlgorithm: hashAlgo})
+			c.Assert(err, IsNil)
+			armored := sig.Armor()
+			for _, line := range strings.Split(string(armored), "\n") {
+				c.Assert(len(line) <= SSHSIG_LINE_LEN, Equals, true)
+			}
+			parsed, err := ParseSSHSignature(armored)
+			c.Assert(err, IsNil)
+			c.Assert(parsed.HashAlgorithm, Equals, hashAlgo)
+			c.Assert(parsed.Verify(bytes.NewReader(msg), "file"), IsNil)
+			c.Assert(parsed.Verify(bytes.NewReader(msg[1:]), "file"),
+				Equals, SigVerificationFailure)
+
+			line, err := PubKeyToDisk(key.Public())
+			c.Assert(err, IsNil)
+			signers := ParseAllowedSigners(append([]byte("*@example.com,!mallory@example.com "), line...))
+			c.Assert(signers.Entries[0].Err, IsNil)
+			_, err = parsed.VerifyAllowed(bytes.NewReader(msg), "file",
+				"alice@example.com", signers, now)
+			c.Assert(err, IsNil)
+			_, err = parsed.VerifyAllowed(bytes.NewReader(msg), "file",
+				"mallory@example.com", signers, now)
+			c.Assert(err, Equals, NotAnAllowedSigner)
+		}
+	}
+
+	// SHA-512 is the default
+	sig, err := SSHSign(edKey, "git", bytes.NewReader(msg))
+	c.Assert(err, IsNil)
+	c.Assert(sig.HashAlgorithm, Equals, SSHSIG_SHA512)
+
+	_, err = SSHSign(edKey, "", bytes.NewReader(msg))
+	c.Assert(err, Equals, WrongSigNamespace)
+	_, err = SSHSign(nil, "file", bytes.NewReader(msg))
+	c.Assert(err, Equals, NilPrivateKey)
+	_, err = SSHSignWithOpts(edKey, "file", bytes.NewReader(msg),
+		&SSHSignOpts{HashAlgorithm: "md5"})
+	c.Assert(err, Equals, UnsupportedDigest)
+}
+
+func (s *XLSuite) TestSSHSignWithCertificate(c *C) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	userKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	now := time.Now()
+	cert := s.signSSHCert(c, userKey.Public(), caKey, SSH_USER_CERT,
+		[]string{"alice", "deploy"}, now.Add(-time.Hour), now.Add(time.Hour), nil)
+	msg := []byte("signed with a certificate")
+
+	sig, err := SSHSignWithOpts(userKey, "file", bytes.NewReader(msg),
+		&SSHSignOpts{Certificate: cert})
+	c.Assert(err, IsNil)
+	parsed, err := ParseSSHSignature(sig.Armor())
+	c.Assert(err, IsNil)
+	c.Assert(parsed.Certificate.Principals, DeepEquals,
+		[]string{"alice", "deploy"})
+
+	caLine, err := PubKeyToDisk(caKey.Public())
+	c.Assert(err, IsNil)
+	signers := ParseAllowedSigners(append(
+		[]byte(`alice,deploy cert-authority,namespaces="file,git" `), caLine...))
+	c.Assert(signers.Entries[0].Err, IsNil)
+	_, err = parsed.VerifyAllowed(bytes.NewReader(msg), "file", "deploy",
+		signers, now)
+	c.Assert(err, IsNil)
+	_, err = parsed.VerifyAllowed(bytes.NewReader(msg), "mail", "deploy",
+		signers, now)
+	c.Assert(err, Equals, NotAnAllowedSigner)
+	c.Assert(parsed.FindPrincipals(signers, now), DeepEquals,
+		[]string{"alice", "deploy"})
+	// the certificate doesn't make the key itself trusted
+	userLine, err := PubKeyToDisk(userKey.Public())
+	c.Assert(err, IsNil)
+	plain := ParseAllowedSigners(append([]byte("alice "), userLine...))
+	_, err = parsed.VerifyAllowed(bytes.NewReader(msg), "file", "alice",
+		plain, now)
+	c.Assert(err, Equals, NotAnAllowedSigner)
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	_, err = SSHSignWithOpts(otherKey, "file", bytes.NewReader(msg),
+		&SSHSignOpts{Certificate: cert})
+	c.Assert(err, Equals, KeyCertMismatch)
+}
+
+func (s *XLSuite) TestAllowedSignersParsing(c *C) {
+	text := `# comment
+
+"alice@example.com,bob@example.com" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOmjtZ6jzxnnxY43I3n3hdyEIRP70x54wQN5YTg4jxLg alice
+carol@example.com valid-after="20250101",valid-before="20260101Z" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOmjtZ6jzxnnxY43I3n3hdyEIRP70x54wQN5YTg4jxLg
+dave@example.com no-such-option ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOmjtZ6jzxnnxY43I3n3hdyEIRP70x54wQN5YTg4jxLg
+"eve@example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOmjtZ6jzxnnxY43I3n3hdyEIRP70x54wQN5YTg4jxLg
+frank@example.com
+`
+	signers := ParseAllowedSigners([]byte(text))
+	c.Assert(signers.Entries, HasLen, 5)
+	alice := signers.Entries[0]
+	c.Assert(alice.LineNo, Equals, 3)
+	c.Assert(alice.Err, IsNil)
+	c.Assert(alice.Principals, DeepEquals,
+		[]string{"alice@example.com", "bob@example.com"})
+	c.Assert(alice.Comment, Equals, "alice")
+	c.Assert(alice.Namespaces, IsNil)
+
+	carol := signers.Entries[1]
+	c.Assert(carol.Err, IsNil)
+	c.Assert(carol.ValidBefore.Equal(
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(carol.ValidAfter.IsZero(), Equals, false)
+
+	for _, ent := range signers.Entries[2:] {
+		c.Assert(ent.Err, Equals, IllFormedAllowedSigner)
+	}
+
+	// carol's key is trusted only in 2025
+	sig, err := ParseSSHSignature([]byte(SSH_KEYGEN_SIG_ED))
+	c.Assert(err, IsNil)
+	in2025 := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	in2026 := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	c.Assert(carol.Permits(sig, "carol@example.com", "file", in2025), Equals, true)
+	c.Assert(carol.Permits(sig, "carol@example.com", "file", in2026), Equals, false)
+	// principals match case-sensitively
+	c.Assert(alice.Permits(sig, "Alice@example.com", "file", in2025), Equals, false)
+	c.Assert(sig.FindPrincipals(signers, in2026), DeepEquals,
+		[]string{"alice@example.com", "bob@example.com"})
+
+	_, err = ParseSSHSignature([]byte("-----BEGIN SSH SIGNATURE-----\nAAAA\n-----END SSH SIGNATURE-----\n"))
+	c.Assert(err, Equals, IllFormedSSHSig)
+	_, err = ParseSSHSignature(nil)
+	c.Assert(err, Equals, NilData)
+}
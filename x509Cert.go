@@ -0,0 +1,270 @@
+package crypto
+
+// xlCrypto_go/x509Cert.go
+
+import (
+	cr "crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"time"
+)
+
+// X.509 certificates for library keys, so that a key can be trusted
+// through an existing PKI rather than distributed out of band.
+// Certificates are issued self-signed, as for a root CA, or signed by
+// a CA's certificate and key.  Chains are exchanged as concatenated PEM
+// CERTIFICATE blocks, leaf first, as TLS servers present them.
+
+const (
+	X509_PEM_TYPE         = "CERTIFICATE"
+	X509_DEFAULT_VALIDITY = 365 * 24 * time.Hour
+	X509_SERIAL_BITS      = 127 // random serials, positive, in 16 bytes
+)
+
+// What a certificate is to say about its subject.  Zero values get the
+// defaults noted.
+type CertOpts struct {
+	CommonName     string
+	Organization   []string
+	DNSNames       []string
+	EmailAddresses []string
+	NotBefore      time.Time // now if zero
+	NotAfter       time.Time // NotBefore + X509_DEFAULT_VALIDITY if zero
+	SerialNumber   *big.Int  // random if nil
+
+	// A CA certificate may sign certificates and CRLs.  MaxPathLen and
+	// MaxPathLenZero limit the intermediate CAs below it, as in
+	// x509.Certificate.
+	IsCA           bool
+	MaxPathLen     int
+	MaxPathLenZero bool
+
+	// If nil, code signing for end entities and none for CAs.
+	ExtKeyUsage []x509.ExtKeyUsage
+}
+
+// ISSUING CERTIFICATES /////////////////////////////////////////////
+
+// Issue a certificate for the key, signed by the key itself.
+func NewSelfSignedCert(key cr.Signer, opts *CertOpts) (
+	cert *x509.Certificate, err error) {
+
+	if key == nil {
+		err = NilPrivateKey
+	} else {
+		cert, err = issueCert(key.Public(), opts, nil, key)
+	}
+	return
+}
+
+// Issue a certificate for the public key, signed by the CA whose
+// certificate and private key are given.
+func NewCASignedCert(pubKey cr.PublicKey, opts *CertOpts,
+	caCert *x509.Certificate, caKey cr.Signer) (
+	cert *x509.Certificate, err error) {
+
+	if pubKey == nil {
+		err = NilPublicKey
+	} else if caCert == nil {
+		err = NilCertificate
+	} else if caKey == nil {
+		err = NilPrivateKey
+	} else if !caCert.IsCA || (caCert.KeyUsage != 0 &&
+		caCert.KeyUsage&x509.KeyUsageCertSign == 0) {
+
+		err = NotACACertificate
+	} else if !CertCertifiesKey(caCert, caKey.Public()) {
+		err = KeyCertMismatch
+	} else {
+		cert, err = issueCert(pubKey, opts, caCert, caKey)
+	}
+	return
+}
+
+// Issue the certificate, self-signed if the parent is nil.
+func issueCert(pubKey cr.PublicKey, opts *CertOpts,
+	parent *x509.Certificate, signer cr.Signer) (
+	cert *x509.Certificate, err error) {
+
+	var (
+		spki, skid, der []byte
+		serial          *big.Int
+	)
+	if opts == nil {
+		opts = &CertOpts{}
+	}
+	// PubKeyToWire refuses keys the library doesn't support
+	if spki, err = PubKeyToWire(pubKey); err == nil {
+		skid, err = subjectKeyId(spki)
+	}
+	if err == nil {
+		serial = opts.SerialNumber
+		if serial == nil {
+			serial, err = rand.Int(rand.Reader,
+				new(big.Int).Lsh(bigOne, X509_SERIAL_BITS))
+		}
+	}
+	if err == nil {
+		notBefore, notAfter := opts.NotBefore, opts.NotAfter
+		if notBefore.IsZero() {
+			notBefore = time.Now()
+		}
+		if notAfter.IsZero() {
+			notAfter = notBefore.Add(X509_DEFAULT_VALIDITY)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: serial,
+			Subject: pkix.Name{
+				CommonName:   opts.CommonName,
+				Organization: opts.Organization,
+			},
+			DNSNames:              opts.DNSNames,
+			EmailAddresses:        opts.EmailAddresses,
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			KeyUsage:              x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:           opts.ExtKeyUsage,
+			BasicConstraintsValid: true,
+			IsCA:                  opts.IsCA,
+			MaxPathLen:            opts.MaxPathLen,
+			MaxPathLenZero:        opts.MaxPathLenZero,
+			SubjectKeyId:          skid,
+		}
+		if opts.IsCA {
+			tmpl.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		} else if tmpl.ExtKeyUsage == nil {
+			tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+		}
+		if parent == nil {
+			parent = tmpl
+		}
+		der, err = x509.CreateCertificate(rand.Reader, tmpl, parent,
+			pubKey, signer)
+	}
+	if err == nil {
+		cert, err = x509.ParseCertificate(der)
+	}
+	return
+}
+
+// Return the key identifier of RFC 5280 section 4.2.1.2, method (1):
+// the SHA-1 hash of the subjectPublicKey bit string in the PKIX DER.
+func subjectKeyId(spki []byte) (id []byte, err error) {
+	var info struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err = asn1.Unmarshal(spki, &info); err == nil {
+		digest := sha1.Sum(info.PublicKey.Bytes)
+		id = digest[:]
+	} else {
+		err = X509ParseOrMarshalError
+	}
+	return
+}
+
+// Whether the certificate is for the public key given.
+func CertCertifiesKey(cert *x509.Certificate, pubKey cr.PublicKey) bool {
+	if cert == nil {
+		return false
+	}
+	certKey, ok := cert.PublicKey.(interface {
+		Equal(cr.PublicKey) bool
+	})
+	return ok && certKey.Equal(pubKey)
+}
+
+// PEM CHAINS ///////////////////////////////////////////////////////
+
+// Serialize certificates as concatenated PEM blocks, in the order given.
+func CertsToPEM(certs ...*x509.Certificate) (out []byte, err error) {
+	for _, cert := range certs {
+		if cert == nil {
+			err = NilCertificate
+			out = nil
+			break
+		}
+		out = append(out, pem.EncodeToMemory(
+			&pem.Block{Type: X509_PEM_TYPE, Bytes: cert.Raw})...)
+	}
+	return
+}
+
+// Parse the certificates in PEM data, in order.  Blocks of other types,
+// such as keys kept in the same file, are skipped.
+func ParseCertChainPEM(data []byte) (chain []*x509.Certificate, err error) {
+	var (
+		block *pem.Block
+		cert  *x509.Certificate
+	)
+	for err == nil {
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type == X509_PEM_TYPE {
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				chain = append(chain, cert)
+			}
+		}
+	}
+	if err == nil && len(chain) == 0 {
+		err = NoCertificates
+	}
+	if err != nil {
+		chain = nil
+	}
+	return
+}
+
+// Read and parse a file of PEM certificates.
+func ReadCertChain(path string) (chain []*x509.Certificate, err error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		chain, err = ParseCertChainPEM(data)
+	}
+	return
+}
+
+// VERIFICATION /////////////////////////////////////////////////////
+
+// Check that the chain, a leaf certificate followed by any intermediate
+// CA certificates, leads to one of the roots at the time given, or now
+// if that is zero.  The leaf must be usable for digital signatures; its
+// extended key usage is not checked, as PKIs differ over what signing
+// certificates carry.  The chains built are returned, each ending in a
+// root.
+func VerifyCertChain(chain []*x509.Certificate, roots *x509.CertPool,
+	when time.Time) (chains [][]*x509.Certificate, err error) {
+
+	if len(chain) == 0 {
+		err = NoCertificates
+	} else if roots == nil {
+		err = NilCertPool
+	} else if chain[0] == nil {
+		err = NilCertificate
+	} else if chain[0].KeyUsage != 0 &&
+		chain[0].KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+
+		err = CertNotForSigning
+	} else {
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			if cert != nil {
+				intermediates.AddCert(cert)
+			}
+		}
+		chains, err = chain[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   when,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+	}
+	return
+}
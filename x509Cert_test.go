@@ -0,0 +1,198 @@
+package crypto
+
+// xlCrypto_go/x509Cert_test.go
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	. "gopkg.in/check.v1"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (s *XLSuite) TestSelfSignedCert(c *C) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	cert, err := NewSelfSignedCert(key, &CertOpts{
+		CommonName:     "build signer",
+		Organization:   []string{"XLattice"},
+		EmailAddresses: []string{"builds@example.com"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cert.Subject.CommonName, Equals, "build signer")
+	c.Assert(cert.Issuer.CommonName, Equals, "build signer")
+	c.Assert(cert.EmailAddresses, DeepEquals, []string{"builds@example.com"})
+	c.Assert(cert.IsCA, Equals, false)
+	c.Assert(cert.ExtKeyUsage, DeepEquals,
+		[]x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning})
+	c.Assert(cert.KeyUsage, Equals, x509.KeyUsageDigitalSignature)
+	c.Assert(cert.SubjectKeyId, HasLen, 20)
+	c.Assert(cert.SerialNumber.Sign(), Equals, 1)
+	c.Assert(cert.NotAfter.Sub(cert.NotBefore), Equals, X509_DEFAULT_VALIDITY)
+	c.Assert(CertCertifiesKey(cert, key.Public()), Equals, true)
+	c.Assert(cert.CheckSignature(cert.SignatureAlgorithm,
+		cert.RawTBSCertificate, cert.Signature), IsNil)
+
+	// a self-signed certificate is its own root
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	_, err = VerifyCertChain([]*x509.Certificate{cert}, roots, time.Time{})
+	c.Assert(err, IsNil)
+	_, err = VerifyCertChain([]*x509.Certificate{cert}, x509.NewCertPool(),
+		time.Time{})
+	c.Assert(err, NotNil)
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	c.Assert(CertCertifiesKey(cert, other.Public()), Equals, false)
+	c.Assert(CertCertifiesKey(nil, key.Public()), Equals, false)
+
+	_, err = NewSelfSignedCert(nil, nil)
+	c.Assert(err, Equals, NilPrivateKey)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	c.Assert(err, IsNil)
+	_, err = NewSelfSignedCert(p521, nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *XLSuite) TestCASignedCertChain(c *C) {
+	_, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	interKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	now := time.Now()
+	root, err := NewSelfSignedCert(rootKey, &CertOpts{
+		CommonName: "XLattice root",
+		IsCA:       true,
+		NotBefore:  now.Add(-time.Hour),
+		NotAfter:   now.Add(10 * X509_DEFAULT_VALIDITY),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(root.KeyUsage&x509.KeyUsageCertSign, Not(Equals), x509.KeyUsage(0))
+	c.Assert(root.ExtKeyUsage, HasLen, 0)
+	inter, err := NewCASignedCert(interKey.Public(), &CertOpts{
+		CommonName:     "XLattice builds",
+		IsCA:           true,
+		MaxPathLenZero: true,
+		NotBefore:      now.Add(-time.Hour),
+	}, root, rootKey)
+	c.Assert(err, IsNil)
+	c.Assert(inter.AuthorityKeyId, DeepEquals, root.SubjectKeyId)
+	c.Assert(inter.MaxPathLenZero, Equals, true)
+	leaf, err := NewCASignedCert(leafKey.Public(), &CertOpts{
+		CommonName:   "release signer",
+		SerialNumber: big.NewInt(1234),
+		NotBefore:    now.Add(-time.Hour),
+	}, inter, interKey)
+	c.Assert(err, IsNil)
+	c.Assert(leaf.SerialNumber.Int64(), Equals, int64(1234))
+	c.Assert(leaf.Issuer.CommonName, Equals, "XLattice builds")
+	c.Assert(leaf.AuthorityKeyId, DeepEquals, inter.SubjectKeyId)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	chain := []*x509.Certificate{leaf, inter}
+	chains, err := VerifyCertChain(chain, roots, time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(chains, HasLen, 1)
+	c.Assert(chains[0], HasLen, 3)
+	c.Assert(chains[0][2].Equal(root), Equals, true)
+
+	// the intermediate is needed, and the certificates must be valid
+	_, err = VerifyCertChain(chain[:1], roots, time.Time{})
+	c.Assert(err, NotNil)
+	_, err = VerifyCertChain(chain, roots, now.Add(2*X509_DEFAULT_VALIDITY))
+	c.Assert(err, NotNil)
+	_, err = VerifyCertChain(chain, roots, now.Add(-2*time.Hour))
+	c.Assert(err, NotNil)
+	// the intermediate may not certify another CA
+	sub, err := NewCASignedCert(leafKey.Public(), &CertOpts{IsCA: true},
+		inter, interKey)
+	c.Assert(err, IsNil)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	subLeaf, err := NewCASignedCert(edKey.Public(), nil, sub, leafKey)
+	c.Assert(err, IsNil)
+	_, err = VerifyCertChain([]*x509.Certificate{subLeaf, sub, inter}, roots,
+		time.Time{})
+	c.Assert(err, NotNil)
+
+	_, err = NewCASignedCert(leafKey.Public(), nil, leaf, leafKey)
+	c.Assert(err, Equals, NotACACertificate)
+	_, err = NewCASignedCert(leafKey.Public(), nil, inter, rootKey)
+	c.Assert(err, Equals, KeyCertMismatch)
+	_, err = NewCASignedCert(nil, nil, inter, interKey)
+	c.Assert(err, Equals, NilPublicKey)
+	_, err = NewCASignedCert(leafKey.Public(), nil, nil, interKey)
+	c.Assert(err, Equals, NilCertificate)
+	_, err = VerifyCertChain(nil, roots, time.Time{})
+	c.Assert(err, Equals, NoCertificates)
+	_, err = VerifyCertChain(chain, nil, time.Time{})
+	c.Assert(err, Equals, NilCertPool)
+
+	// a leaf which may not sign
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(5),
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, inter,
+		leafKey.Public(), interKey)
+	c.Assert(err, IsNil)
+	encipher, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+	_, err = VerifyCertChain([]*x509.Certificate{encipher, inter}, roots,
+		time.Time{})
+	c.Assert(err, Equals, CertNotForSigning)
+}
+
+func (s *XLSuite) TestCertChainPEM(c *C) {
+	_, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	root, err := NewSelfSignedCert(rootKey, &CertOpts{IsCA: true})
+	c.Assert(err, IsNil)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	c.Assert(err, IsNil)
+	leaf, err := NewCASignedCert(leafKey.Public(), nil, root, rootKey)
+	c.Assert(err, IsNil)
+
+	data, err := CertsToPEM(leaf, root)
+	c.Assert(err, IsNil)
+	block, _ := pem.Decode(data)
+	c.Assert(block.Type, Equals, X509_PEM_TYPE)
+	c.Assert(block.Bytes, DeepEquals, leaf.Raw)
+
+	// a key block among the certificates is skipped
+	keyPEM, err := ECDSAPrivateKeyToPEM(leafKey)
+	c.Assert(err, IsNil)
+	path := filepath.Join(c.MkDir(), "chain.pem")
+	c.Assert(os.WriteFile(path, append(keyPEM, data...), 0644), IsNil)
+	chain, err := ReadCertChain(path)
+	c.Assert(err, IsNil)
+	c.Assert(chain, HasLen, 2)
+	c.Assert(chain[0].Equal(leaf), Equals, true)
+	c.Assert(chain[1].Equal(root), Equals, true)
+
+	_, err = ParseCertChainPEM(keyPEM)
+	c.Assert(err, Equals, NoCertificates)
+	_, err = ParseCertChainPEM(nil)
+	c.Assert(err, Equals, NoCertificates)
+	bad := pem.EncodeToMemory(&pem.Block{Type: X509_PEM_TYPE,
+		Bytes: bytes.Repeat([]byte{0x30}, 8)})
+	_, err = ParseCertChainPEM(append(data, bad...))
+	c.Assert(err, NotNil)
+	_, err = CertsToPEM(leaf, nil)
+	c.Assert(err, Equals, NilCertificate)
+}